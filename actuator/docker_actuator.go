@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"sync"
-	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
@@ -46,14 +45,22 @@ type dockerActuator struct {
 
 	runningTask sync.Map // ContainerName -> containerId 映射
 	datatMap    sync.Map // ContainerName -> interface{} 映射
+
+	clock framework.Clock
 }
 
 func MakeDockerActuator(initFunc InitFunction) *dockerActuator {
 	return &dockerActuator{
 		initFunc: initFunc,
+		clock:    framework.RealClock,
 	}
 }
 
+// SetClock 设置执行器使用的时钟
+func (dc *dockerActuator) SetClock(clock framework.Clock) {
+	dc.clock = framework.ClockOrReal(clock)
+}
+
 // SetCallbackChannel 任务配置回调 channel
 func (dc *dockerActuator) SetCallbackChannel(callbackChannel chan framework.Task) {
 	dc.callbackChannel = callbackChannel
@@ -71,13 +78,9 @@ func (dc *dockerActuator) Init(ctx context.Context, task *framework.Task) (
 // Start 执行任务
 func (dc *dockerActuator) Start(ctx context.Context, ftask *framework.Task) (
 	newTask *framework.Task, ignoreErr bool, err error) {
-	var task DockerTask
-	if v, ok := ftask.TaskItem.(DockerTask); ok {
-		task = v
-	} else if v, ok := ftask.TaskItem.(*DockerTask); ok {
-		task = *v
-	} else {
-		return ftask, false, fmt.Errorf("TaskItem is not configured as DockerTask")
+	task, err := framework.ItemOf[DockerTask](ftask)
+	if err != nil {
+		return ftask, false, err
 	}
 	cli, err := dockerclient.NewClientWithOpts(dockerclient.WithAPIVersionNegotiation())
 	if err != nil {
@@ -123,7 +126,7 @@ func (dc *dockerActuator) Start(ctx context.Context, ftask *framework.Task) (
 	}
 
 	ftask.TaskStatus = framework.TASK_STATUS_RUNNING
-	ftask.TaskStartTime = time.Now()
+	ftask.TaskStartTime = dc.clock.Now()
 	task.ContainerId = resp.ID
 	ftask.TaskItem = task
 	dc.runningTask.Store(task.ContainerName, resp.ID)
@@ -133,13 +136,9 @@ func (dc *dockerActuator) Start(ctx context.Context, ftask *framework.Task) (
 
 // Stop 停止任务
 func (dc *dockerActuator) Stop(ctx context.Context, ftask *framework.Task) error {
-	var task DockerTask
-	if v, ok := ftask.TaskItem.(DockerTask); ok {
-		task = v
-	} else if v, ok := ftask.TaskItem.(*DockerTask); ok {
-		task = *v
-	} else {
-		return fmt.Errorf("TaskItem is not configured as DockerTask")
+	task, err := framework.ItemOf[DockerTask](ftask)
+	if err != nil {
+		return err
 	}
 
 	cli, err := dockerclient.NewClientWithOpts(dockerclient.WithAPIVersionNegotiation())
@@ -162,13 +161,9 @@ func (dc *dockerActuator) Stop(ctx context.Context, ftask *framework.Task) error
 func (fc *dockerActuator) GetAsyncTaskStatus(ctx context.Context, ftasks []framework.Task) (
 	status []framework.AsyncTaskStatus, err error) {
 	for i, ftask := range ftasks {
-		var task DockerTask
-		if v, ok := ftask.TaskItem.(DockerTask); ok {
-			task = v
-		} else if v, ok := ftask.TaskItem.(*DockerTask); ok {
-			task = *v
-		} else {
-			return nil, fmt.Errorf("tasks[%d].TaskItem is not configured as DockerTask", i)
+		task, err := framework.ItemOf[DockerTask](&ftask)
+		if err != nil {
+			return nil, fmt.Errorf("tasks[%d]: %v", i, err)
 		}
 		cli, err := dockerclient.NewClientWithOpts(dockerclient.WithAPIVersionNegotiation())
 		if err != nil {
@@ -180,7 +175,6 @@ func (fc *dockerActuator) GetAsyncTaskStatus(ctx context.Context, ftasks []frame
 			status = append(status, framework.AsyncTaskStatus{
 				TaskStatus:   framework.TASK_STATUS_FAILED,
 				FailedReason: errors.New("同步任务未找到"),
-				Progress:     float32(0.0),
 			})
 		} else {
 			id := v.(string)
@@ -190,9 +184,10 @@ func (fc *dockerActuator) GetAsyncTaskStatus(ctx context.Context, ftasks []frame
 			}
 			st := framework.AsyncTaskStatus{
 				TaskStatus: framework.TASK_STATUS_RUNNING,
-				Progress:   float32(0.0),
 			}
-			if stats.State.Status != "running" {
+			if stats.State.Status == "running" {
+				st.LastHeartbeat = fc.clock.Now() // 容器运行中即存活
+			} else {
 				if stats.State.ExitCode == 0 {
 					st.TaskStatus = framework.TASK_STATUS_SUCCESS
 				} else {
@@ -213,13 +208,9 @@ func (fc *dockerActuator) GetAsyncTaskStatus(ctx context.Context, ftasks []frame
 // GetOutput ...
 func (dc *dockerActuator) GetOutput(ctx context.Context, ftask *framework.Task) (
 	data interface{}, err error) {
-	var task DockerTask
-	if v, ok := ftask.TaskItem.(DockerTask); ok {
-		task = v
-	} else if v, ok := ftask.TaskItem.(*DockerTask); ok {
-		task = *v
-	} else {
-		return nil, fmt.Errorf("TaskItem is not configured as DockerTask")
+	task, err := framework.ItemOf[DockerTask](ftask)
+	if err != nil {
+		return nil, err
 	}
 	if v, ok := dc.datatMap.LoadAndDelete(task.ContainerName); ok {
 		return v, nil
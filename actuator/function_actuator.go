@@ -19,11 +19,14 @@ type fucntionActuator struct {
 	initFunc        InitFunction        // 初始函数
 	callbackChannel chan framework.Task // 回调队列
 
-	runningTask *cache.Cache // taskId -> [framework.AsyncTaskStatus, cancel function] 映射
-	datatMap    *cache.Cache // taskId -> interface{} 映射
-
+	runningTask *cache.Cache  // taskId -> [framework.AsyncTaskStatus, cancel function] 映射
+	datatMap    *cache.Cache  // taskId -> interface{} 映射
+	expiration  time.Duration // 任务状态和结果的缓存时间
+	clock       framework.Clock
 }
 
+const defaultFunctionCacheExpiration = 5 * 24 * time.Hour
+
 // RunFunction 执行函数的定义，实现该函数的时候，建议使用传入 ctx 进行超时和退出处理
 // data 任务执行完成返回的数据
 type RunFunction func(ctx context.Context, task *framework.Task) (data interface{}, err error)
@@ -31,6 +34,33 @@ type RunFunction func(ctx context.Context, task *framework.Task) (data interface
 // InitFunction 任务执行前的初始工作
 type InitFunction func(ctx context.Context, task *framework.Task) (newTask *framework.Task, err error)
 
+type checkpointSaverKey struct{}
+
+// SaveCheckpoint 在 RunFunction 中保存任务的检查点，调度器会通过执行器状态获取并保存检查点，
+// 任务重试的时候，RunFunction 可以通过 task.TaskCheckpoint 获取最新的检查点，从检查点继续执行
+// ctx 必须是 RunFunction 传入的 ctx
+func SaveCheckpoint(ctx context.Context, checkpoint []byte) error {
+	saver, ok := ctx.Value(checkpointSaverKey{}).(func(checkpoint []byte))
+	if !ok {
+		return fmt.Errorf("ctx is not from fucntionActuator RunFunction")
+	}
+	saver(checkpoint)
+	return nil
+}
+
+type progressReporterKey struct{}
+
+// ReportProgress 在 RunFunction 中上报任务的执行进度，调度器轮询的时候会把进度保存到任务容器
+// 没有设置 ETA 的时候，根据任务开始时间和完成百分比估算，ctx 必须是 RunFunction 传入的 ctx
+func ReportProgress(ctx context.Context, progress framework.TaskProgress) error {
+	reporter, ok := ctx.Value(progressReporterKey{}).(func(progress framework.TaskProgress))
+	if !ok {
+		return fmt.Errorf("ctx is not from fucntionActuator RunFunction")
+	}
+	reporter(progress)
+	return nil
+}
+
 // runFunc 待调度的执行函数，注意实现该函数的时候，需要使用传入 ctx 进行超时和退出处理，框架否则无法控制超时时间
 // callbackChannel 用来执行器进行任务回调，返回已经完成的任务，如果不需要回调，传入 nil 即可
 func MakeFucntionActuator(runFunc RunFunction, initFunc InitFunction) (*fucntionActuator, error) {
@@ -40,11 +70,24 @@ func MakeFucntionActuator(runFunc RunFunction, initFunc InitFunction) (*fucntion
 	return &fucntionActuator{
 		runFunc:     runFunc,
 		initFunc:    initFunc,
-		runningTask: cache.New(5*24*time.Hour, 24*time.Hour), // 缓存5天
-		datatMap:    cache.New(5*24*time.Hour, 24*time.Hour), // 缓存5天
+		runningTask: cache.New(defaultFunctionCacheExpiration, time.Hour),
+		datatMap:    cache.New(defaultFunctionCacheExpiration, time.Hour),
+		expiration:  defaultFunctionCacheExpiration, // 缓存5天
+		clock:       framework.RealClock,
 	}, nil
 }
 
+// SetClock 设置执行器使用的时钟，任务开始、结束、心跳和进度的时间都从该时钟获取
+func (fc *fucntionActuator) SetClock(clock framework.Clock) {
+	fc.clock = framework.ClockOrReal(clock)
+}
+
+// SetExpiration 设置任务状态和结果在执行器中的缓存时间，默认 5 天，只对之后缓存的数据生效
+// 结果被 GetOutput 取走或者任务被清理以后会立即删除，缓存时间只用来兜底没有被取走的数据
+func (fc *fucntionActuator) SetExpiration(expiration time.Duration) {
+	fc.expiration = expiration
+}
+
 // SetCallbackChannel 任务配置回调 channel
 func (fc *fucntionActuator) SetCallbackChannel(callbackChannel chan framework.Task) {
 	fc.callbackChannel = callbackChannel
@@ -69,17 +112,33 @@ func (fc *fucntionActuator) Start(ctx context.Context, ftask *framework.Task) (
 			return ftask, false, fmt.Errorf("task %s is running", ftask.TaskId)
 		}
 	}
+	ftask.TaskStatus = framework.TASK_STATUS_RUNNING
+	ftask.TaskStartTime = fc.clock.Now()
+	// 执行过程中使用任务的副本，调用方之后对 ftask 的修改（比如任务版本）不会影响执行和回调
+	task := *ftask
 	runCtx, cancel := context.WithCancel(ctx)
+	runCtx = context.WithValue(runCtx, checkpointSaverKey{}, func(checkpoint []byte) {
+		fc.updateRunningStatus(task.TaskId, func(status *framework.AsyncTaskStatus) {
+			status.Checkpoint = checkpoint
+		})
+	})
+	runCtx = context.WithValue(runCtx, progressReporterKey{}, func(progress framework.TaskProgress) {
+		now := fc.clock.Now()
+		progress.UpdateTime = now
+		if progress.ETA.IsZero() {
+			progress.ETA = progress.EstimateETA(task.TaskStartTime, now)
+		}
+		fc.updateRunningStatus(task.TaskId, func(status *framework.AsyncTaskStatus) {
+			status.TaskProgress = progress
+		})
+	})
 
-	ftask.TaskStatus = framework.TASK_STATUS_RUNNING
-	ftask.TaskStartTime = time.Now()
-	fc.datatMap.Delete(ftask.TaskId)
-	fc.runningTask.Set(ftask.TaskId,
+	fc.datatMap.Delete(task.TaskId)
+	fc.runningTask.Set(task.TaskId,
 		[]interface{}{
 			framework.AsyncTaskStatus{
 				TaskStatus: framework.TASK_STATUS_RUNNING,
-				Progress:   0.0,
-			}, cancel}, cache.DefaultExpiration)
+			}, cancel}, fc.expiration)
 
 	go func() {
 		data, err := func() (data interface{}, err error) {
@@ -90,9 +149,9 @@ func (fc *fucntionActuator) Start(ctx context.Context, ftask *framework.Task) (
 					err = fmt.Errorf("panic: %v, stacktrace: %s", p, debug.Stack())
 				}
 			}()
-			return fc.runFunc(runCtx, ftask)
+			return fc.runFunc(runCtx, &task)
 		}()
-		st, ok := fc.runningTask.Get(ftask.TaskId)
+		st, ok := fc.runningTask.Get(task.TaskId)
 		if !ok {
 			// 任务可能因为超时被删除，或者手动暂停、不处理
 			return
@@ -104,25 +163,26 @@ func (fc *fucntionActuator) Start(ctx context.Context, ftask *framework.Task) (
 		if err != nil {
 			newStatus = framework.AsyncTaskStatus{
 				TaskStatus:   framework.TASK_STATUS_FAILED,
-				Progress:     0.0,
 				FailedReason: err,
+				Checkpoint:   st.([]interface{})[0].(framework.AsyncTaskStatus).Checkpoint, // 保留失败前的检查点
 			}
 		} else {
 			newStatus = framework.AsyncTaskStatus{
-				TaskStatus: framework.TASK_STATUS_SUCCESS,
-				Progress:   100.0,
+				TaskStatus:   framework.TASK_STATUS_SUCCESS,
+				TaskProgress: framework.TaskProgress{Percent: 100},
 			}
-			fc.datatMap.Set(ftask.TaskId, data, cache.DefaultExpiration) // 先存结果
+			fc.datatMap.Set(task.TaskId, data, fc.expiration) // 先存结果
 		}
-		fc.runningTask.Set(ftask.TaskId, []interface{}{newStatus, nil}, cache.DefaultExpiration)
+		fc.runningTask.Set(task.TaskId, []interface{}{newStatus, nil}, fc.expiration)
 		if fc.callbackChannel != nil {
 			// 如果需要回调
-			callbackTask := *ftask
+			callbackTask := task
 			callbackTask.TaskStatus = newStatus.TaskStatus
-			callbackTask.TaskEnbTime = time.Now()
+			callbackTask.TaskEnbTime = fc.clock.Now()
 			if newStatus.FailedReason != nil {
 				callbackTask.FailedReason = newStatus.FailedReason
 			}
+			callbackTask.TaskCheckpoint = newStatus.Checkpoint
 			fc.callbackChannel <- callbackTask
 		}
 	}()
@@ -130,6 +190,20 @@ func (fc *fucntionActuator) Start(ctx context.Context, ftask *framework.Task) (
 	return ftask, false, nil
 }
 
+// updateRunningStatus 更新执行中任务的状态
+func (fc *fucntionActuator) updateRunningStatus(taskId string, update func(status *framework.AsyncTaskStatus)) {
+	st, ok := fc.runningTask.Get(taskId)
+	if !ok {
+		return
+	}
+	status := st.([]interface{})[0].(framework.AsyncTaskStatus)
+	if status.TaskStatus != framework.TASK_STATUS_RUNNING {
+		return
+	}
+	update(&status)
+	fc.runningTask.Set(taskId, []interface{}{status, st.([]interface{})[1]}, fc.expiration)
+}
+
 func (fc *fucntionActuator) clear(taskId string) {
 	fc.runningTask.Delete(taskId)
 	fc.datatMap.Delete(taskId)
@@ -159,12 +233,13 @@ func (fc *fucntionActuator) GetAsyncTaskStatus(ctx context.Context, ftasks []fra
 			status = append(status, framework.AsyncTaskStatus{
 				TaskStatus:   framework.TASK_STATUS_FAILED,
 				FailedReason: errors.New("同步任务未找到"),
-				Progress:     float32(0.0),
 			})
 		} else {
 			st := fstatus.([]interface{})[0].(framework.AsyncTaskStatus)
 			if st.TaskStatus != framework.TASK_STATUS_RUNNING {
 				fc.runningTask.Delete(ftask.TaskId) // delete task status after query if task finished
+			} else {
+				st.LastHeartbeat = fc.clock.Now() // 函数在本进程中执行，执行中即存活
 			}
 			status = append(status, st)
 		}
@@ -182,3 +257,9 @@ func (fc *fucntionActuator) GetOutput(ctx context.Context, ftask *framework.Task
 	fc.clear(ftask.TaskId)
 	return res, nil
 }
+
+// DeleteOutput 删除执行器中缓存的任务结果
+func (fc *fucntionActuator) DeleteOutput(ctx context.Context, ftask *framework.Task) error {
+	fc.datatMap.Delete(ftask.TaskId)
+	return nil
+}
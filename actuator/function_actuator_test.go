@@ -0,0 +1,55 @@
+package actuator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	framework "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuator"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+)
+
+// runItem 测试任务的执行方式，通过 Task.TaskItem 传给执行函数
+type runItem struct {
+	duration time.Duration // 为 0 的时候一直执行，直到 ctx 被取消
+	err      error
+}
+
+func run(ctx context.Context, task *framework.Task) (interface{}, error) {
+	item := task.TaskItem.(runItem)
+	if item.duration == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	select {
+	case <-time.After(item.duration):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return task.TaskId, item.err
+}
+
+func TestFunctionActuatorSuite(t *testing.T) {
+	actuatortest.RunSuite(t, actuatortest.Suite{
+		NewActuator: func(t *testing.T) framework.TaskActuator {
+			a, err := actuator.MakeFucntionActuator(run, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return a
+		},
+		NewSuccessTask: func(taskId string) framework.Task {
+			return framework.Task{TaskId: taskId, TaskItem: runItem{duration: 10 * time.Millisecond}}
+		},
+		NewFailedTask: func(taskId string) framework.Task {
+			return framework.Task{TaskId: taskId,
+				TaskItem: runItem{duration: 10 * time.Millisecond, err: errors.New("run error")}}
+		},
+		NewLongTask: func(taskId string) framework.Task {
+			return framework.Task{TaskId: taskId, TaskItem: runItem{}}
+		},
+		WaitTimeout: 5 * time.Second,
+	})
+}
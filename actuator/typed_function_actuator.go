@@ -0,0 +1,30 @@
+// 类型安全的函数执行器
+
+package actuator
+
+import (
+	"context"
+	"fmt"
+
+	framework "github.com/memory-overflow/light-task-scheduler"
+)
+
+// TypedRunFunction 类型安全的执行函数，item 为 In 类型的任务对象，返回 Out 类型的执行结果
+type TypedRunFunction[In, Out any] func(ctx context.Context, task *framework.Task, item In) (data Out, err error)
+
+// MakeTypedFucntionActuator 使用类型安全的执行函数构建函数执行器，任务对象的类型在执行前统一检查，
+// 执行器的其他行为和 MakeFucntionActuator 一致
+func MakeTypedFucntionActuator[In, Out any](runFunc TypedRunFunction[In, Out], initFunc InitFunction) (
+	*fucntionActuator, error) {
+	if runFunc == nil {
+		return nil, fmt.Errorf("runFunc is nil")
+	}
+	return MakeFucntionActuator(
+		func(ctx context.Context, task *framework.Task) (data interface{}, err error) {
+			item, err := framework.ItemOf[In](task)
+			if err != nil {
+				return nil, err
+			}
+			return runFunc(ctx, task, item)
+		}, initFunc)
+}
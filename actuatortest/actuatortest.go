@@ -0,0 +1,350 @@
+// Package actuatortest 任务执行器的一致性测试套件和可以配置执行脚本的假执行器，
+// 自己实现的任务执行器可以在测试中调用 RunSuite，校验执行器的行为是否满足调度器的要求
+
+package actuatortest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+)
+
+// Factory 构造一个新的任务执行器，每个测试用例都会调用一次
+type Factory func(t *testing.T) lighttaskscheduler.TaskActuator
+
+// TaskFactory 构造测试用的任务
+type TaskFactory func(taskId string) lighttaskscheduler.Task
+
+// CallbackActuator 支持回调的执行器，框架提供的执行器都通过 SetCallbackChannel 配置回调
+type CallbackActuator interface {
+	SetCallbackChannel(callbackChannel chan lighttaskscheduler.Task)
+}
+
+// Suite 测试套件的配置
+type Suite struct {
+	// NewActuator 构造一个新的任务执行器，必填
+	NewActuator Factory
+
+	// NewSuccessTask 构造一个会很快执行成功的任务，必填
+	NewSuccessTask TaskFactory
+
+	// NewFailedTask 构造一个会很快执行失败的任务，为 nil 的时候跳过失败相关的测试
+	NewFailedTask TaskFactory
+
+	// NewLongTask 构造一个执行时间远大于 WaitTimeout 的任务，为 nil 的时候跳过 Start 不阻塞和 Stop 相关的测试
+	NewLongTask TaskFactory
+
+	// StartTimeout Start 最长的执行时间，超过认为 Start 阻塞，默认 1 秒
+	StartTimeout time.Duration
+
+	// WaitTimeout 等待任务结束的最长时间，默认 10 秒
+	WaitTimeout time.Duration
+
+	// Advance 等待任务结束的时候每次轮询之前调用，执行器使用手动时钟的时候用来推进时间，默认 sleep 10 毫秒
+	Advance func()
+}
+
+// RunSuite 运行测试套件
+// 校验的规则：Start 不阻塞；GetAsyncTaskStatus 返回的状态和输入的任务顺序、长度一致，未知的任务返回失败；
+// 结束状态被查询以后执行器不再维护任务状态；成功的任务可以通过 GetOutput 获取结果；Stop 以后任务不再运行，重复 Stop 不报错；
+// 执行器实现了 CallbackActuator 的时候，结束的任务需要回调；并发调用安全
+func RunSuite(t *testing.T, s Suite) {
+	if s.NewActuator == nil || s.NewSuccessTask == nil {
+		t.Fatal("Suite.NewActuator and Suite.NewSuccessTask are required")
+	}
+	if s.StartTimeout <= 0 {
+		s.StartTimeout = time.Second
+	}
+	if s.WaitTimeout <= 0 {
+		s.WaitTimeout = 10 * time.Second
+	}
+	if s.Advance == nil {
+		s.Advance = func() { time.Sleep(10 * time.Millisecond) }
+	}
+	cases := []struct {
+		name string
+		f    func(t *testing.T, s Suite)
+	}{
+		{"Init", testInit},
+		{"StartNotBlock", testStartNotBlock},
+		{"Success", testSuccess},
+		{"Failed", testFailed},
+		{"StatusOrder", testStatusOrder},
+		{"UnknownTask", testUnknownTask},
+		{"Stop", testStop},
+		{"Callback", testCallback},
+		{"Concurrent", testConcurrent},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.f(t, s)
+		})
+	}
+}
+
+func start(t *testing.T, a lighttaskscheduler.TaskActuator, task lighttaskscheduler.Task) lighttaskscheduler.Task {
+	t.Helper()
+	newTask, err := a.Init(context.Background(), &task)
+	if err != nil {
+		t.Fatalf("Init error: %v", err)
+	}
+	newTask, _, err = a.Start(context.Background(), newTask)
+	if err != nil {
+		t.Fatalf("Start error: %v", err)
+	}
+	if newTask == nil || newTask.TaskId != task.TaskId {
+		t.Fatalf("Start must return the task %s, got %v", task.TaskId, newTask)
+	}
+	return *newTask
+}
+
+func queryOne(t *testing.T, a lighttaskscheduler.TaskActuator,
+	task lighttaskscheduler.Task) lighttaskscheduler.AsyncTaskStatus {
+	t.Helper()
+	status, err := a.GetAsyncTaskStatus(context.Background(), []lighttaskscheduler.Task{task})
+	if err != nil {
+		t.Fatalf("GetAsyncTaskStatus error: %v", err)
+	}
+	if len(status) != 1 {
+		t.Fatalf("GetAsyncTaskStatus with 1 task returns %d status", len(status))
+	}
+	return status[0]
+}
+
+// waitFinished 轮询直到任务结束，返回结束状态
+func waitFinished(t *testing.T, s Suite, a lighttaskscheduler.TaskActuator,
+	task lighttaskscheduler.Task) lighttaskscheduler.AsyncTaskStatus {
+	t.Helper()
+	deadline := time.Now().Add(s.WaitTimeout)
+	for time.Now().Before(deadline) {
+		s.Advance()
+		if st := queryOne(t, a, task); st.TaskStatus != lighttaskscheduler.TASK_STATUS_RUNNING {
+			return st
+		}
+	}
+	t.Fatalf("task %s is not finished in %v", task.TaskId, s.WaitTimeout)
+	return lighttaskscheduler.AsyncTaskStatus{}
+}
+
+func testInit(t *testing.T, s Suite) {
+	a := s.NewActuator(t)
+	task := s.NewSuccessTask("task-init")
+	newTask, err := a.Init(context.Background(), &task)
+	if err != nil {
+		t.Fatalf("Init error: %v", err)
+	}
+	if newTask == nil || newTask.TaskId != task.TaskId {
+		t.Fatalf("Init must keep the task id %s, got %v", task.TaskId, newTask)
+	}
+}
+
+func testStartNotBlock(t *testing.T, s Suite) {
+	if s.NewLongTask == nil {
+		t.Skip("Suite.NewLongTask is not set")
+	}
+	a := s.NewActuator(t)
+	begin := time.Now()
+	task := start(t, a, s.NewLongTask("task-long"))
+	if cost := time.Since(begin); cost > s.StartTimeout {
+		t.Fatalf("Start blocks for %v, must return in %v", cost, s.StartTimeout)
+	}
+	if st := queryOne(t, a, task); st.TaskStatus != lighttaskscheduler.TASK_STATUS_RUNNING {
+		t.Fatalf("long task status want RUNNING, got %v", st.TaskStatus)
+	}
+	a.Stop(context.Background(), &task)
+}
+
+func testSuccess(t *testing.T, s Suite) {
+	a := s.NewActuator(t)
+	task := start(t, a, s.NewSuccessTask("task-success"))
+	if st := waitFinished(t, s, a, task); st.TaskStatus != lighttaskscheduler.TASK_STATUS_SUCCESS {
+		t.Fatalf("success task status want SUCCESS, got %v, reason %v", st.TaskStatus, st.FailedReason)
+	}
+	// 结束状态被查询以后，执行器不再维护任务状态，不能重复返回成功，否则任务会被重复导出
+	if st := queryOne(t, a, task); st.TaskStatus == lighttaskscheduler.TASK_STATUS_SUCCESS ||
+		st.TaskStatus == lighttaskscheduler.TASK_STATUS_RUNNING {
+		t.Fatalf("status after SUCCESS has been queried must be forgotten, got %v", st.TaskStatus)
+	}
+	if _, err := a.GetOutput(context.Background(), &task); err != nil {
+		t.Fatalf("GetOutput after SUCCESS error: %v", err)
+	}
+}
+
+func testFailed(t *testing.T, s Suite) {
+	if s.NewFailedTask == nil {
+		t.Skip("Suite.NewFailedTask is not set")
+	}
+	a := s.NewActuator(t)
+	task := start(t, a, s.NewFailedTask("task-failed"))
+	st := waitFinished(t, s, a, task)
+	if st.TaskStatus != lighttaskscheduler.TASK_STATUS_FAILED {
+		t.Fatalf("failed task status want FAILED, got %v", st.TaskStatus)
+	}
+	if st.FailedReason == nil {
+		t.Errorf("FAILED status must have FailedReason")
+	}
+	// 失败以后可以重新 Start，用于重试
+	task.TaskAttemptsTime++
+	if _, _, err := a.Start(context.Background(), &task); err != nil {
+		t.Fatalf("restart failed task error: %v", err)
+	}
+	a.Stop(context.Background(), &task)
+}
+
+func testStatusOrder(t *testing.T, s Suite) {
+	a := s.NewActuator(t)
+	tasks := []lighttaskscheduler.Task{start(t, a, s.NewSuccessTask("task-order-0"))}
+	if s.NewLongTask != nil {
+		tasks = append(tasks, start(t, a, s.NewLongTask("task-order-1")))
+	}
+	tasks = append(tasks, lighttaskscheduler.Task{TaskId: "task-order-unknown"})
+	deadline := time.Now().Add(s.WaitTimeout)
+	for {
+		s.Advance()
+		status, err := a.GetAsyncTaskStatus(context.Background(), tasks)
+		if err != nil {
+			t.Fatalf("GetAsyncTaskStatus error: %v", err)
+		}
+		if len(status) != len(tasks) {
+			t.Fatalf("GetAsyncTaskStatus with %d tasks returns %d status", len(tasks), len(status))
+		}
+		if last := status[len(status)-1]; last.TaskStatus != lighttaskscheduler.TASK_STATUS_FAILED {
+			t.Fatalf("status of unknown task at the last position want FAILED, got %v", last.TaskStatus)
+		}
+		if s.NewLongTask != nil && status[1].TaskStatus != lighttaskscheduler.TASK_STATUS_RUNNING {
+			t.Fatalf("status of long task at position 1 want RUNNING, got %v", status[1].TaskStatus)
+		}
+		if status[0].TaskStatus == lighttaskscheduler.TASK_STATUS_SUCCESS {
+			break
+		}
+		if status[0].TaskStatus != lighttaskscheduler.TASK_STATUS_RUNNING {
+			t.Fatalf("status of success task at position 0 want RUNNING or SUCCESS, got %v", status[0].TaskStatus)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("task %s is not finished in %v", tasks[0].TaskId, s.WaitTimeout)
+		}
+	}
+	if s.NewLongTask != nil {
+		a.Stop(context.Background(), &tasks[1])
+	}
+}
+
+func testUnknownTask(t *testing.T, s Suite) {
+	a := s.NewActuator(t)
+	task := lighttaskscheduler.Task{TaskId: "task-unknown"}
+	st := queryOne(t, a, task)
+	// 执行器重启以后丢失的任务必须返回失败，调度器才能重试
+	if st.TaskStatus != lighttaskscheduler.TASK_STATUS_FAILED {
+		t.Fatalf("status of unknown task want FAILED, got %v", st.TaskStatus)
+	}
+	if err := a.Stop(context.Background(), &task); err != nil {
+		t.Fatalf("Stop unknown task error: %v", err)
+	}
+}
+
+func testStop(t *testing.T, s Suite) {
+	if s.NewLongTask == nil {
+		t.Skip("Suite.NewLongTask is not set")
+	}
+	a := s.NewActuator(t)
+	task := start(t, a, s.NewLongTask("task-stop"))
+	if err := a.Stop(context.Background(), &task); err != nil {
+		t.Fatalf("Stop error: %v", err)
+	}
+	if st := queryOne(t, a, task); st.TaskStatus == lighttaskscheduler.TASK_STATUS_RUNNING {
+		t.Fatalf("stopped task must not be RUNNING")
+	}
+	if err := a.Stop(context.Background(), &task); err != nil {
+		t.Fatalf("Stop twice error: %v", err)
+	}
+}
+
+func testCallback(t *testing.T, s Suite) {
+	a := s.NewActuator(t)
+	callbackActuator, ok := a.(CallbackActuator)
+	if !ok {
+		t.Skip("actuator does not implement CallbackActuator")
+	}
+	callbackChannel := make(chan lighttaskscheduler.Task, 10)
+	callbackActuator.SetCallbackChannel(callbackChannel)
+	task := start(t, a, s.NewSuccessTask("task-callback"))
+	deadline := time.Now().Add(s.WaitTimeout)
+	for time.Now().Before(deadline) {
+		s.Advance()
+		if deliverer, ok := a.(interface {
+			DeliverCallbacks(ctx context.Context) (int, error)
+		}); ok {
+			deliverer.DeliverCallbacks(context.Background())
+		}
+		select {
+		case callback := <-callbackChannel:
+			if callback.TaskId != task.TaskId {
+				t.Fatalf("callback want task %s, got %s", task.TaskId, callback.TaskId)
+			}
+			if callback.TaskStatus != lighttaskscheduler.TASK_STATUS_SUCCESS {
+				t.Fatalf("callback status want SUCCESS, got %v", callback.TaskStatus)
+			}
+			return
+		default:
+		}
+	}
+	t.Fatalf("no callback of task %s in %v", task.TaskId, s.WaitTimeout)
+}
+
+func testConcurrent(t *testing.T, s Suite) {
+	a := s.NewActuator(t)
+	n := 50
+	tasks := make([]lighttaskscheduler.Task, n)
+	wg := sync.WaitGroup{}
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			task := s.NewSuccessTask(fmt.Sprintf("task-concurrent-%d", i))
+			newTask, _, err := a.Start(context.Background(), &task)
+			if err != nil {
+				t.Errorf("concurrent Start error: %v", err)
+				return
+			}
+			tasks[i] = *newTask
+		}(i)
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+	finished := make([]bool, n)
+	deadline := time.Now().Add(s.WaitTimeout)
+	for left := n; left > 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d tasks are not finished in %v", left, s.WaitTimeout)
+		}
+		s.Advance()
+		status, err := a.GetAsyncTaskStatus(context.Background(), tasks)
+		if err != nil {
+			t.Fatalf("GetAsyncTaskStatus error: %v", err)
+		}
+		if len(status) != n {
+			t.Fatalf("GetAsyncTaskStatus with %d tasks returns %d status", n, len(status))
+		}
+		for i, st := range status {
+			if finished[i] {
+				continue
+			}
+			switch st.TaskStatus {
+			case lighttaskscheduler.TASK_STATUS_RUNNING:
+			case lighttaskscheduler.TASK_STATUS_SUCCESS:
+				finished[i] = true
+				left--
+			default:
+				t.Fatalf("task %s status want RUNNING or SUCCESS, got %v, reason %v",
+					tasks[i].TaskId, st.TaskStatus, st.FailedReason)
+			}
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package actuatortest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+)
+
+// Script 假执行器中一个任务的执行脚本
+type Script struct {
+	// 任务执行的时间，从 Start 开始计算，通过执行器的 Clock 判断任务是否结束
+	Duration time.Duration
+
+	// 不为 nil 的时候任务执行结束以后失败
+	Err error
+
+	// 前 FailedAttempts 次执行失败，之后按照 Err 执行，用来模拟需要重试的任务，按照 Task.TaskAttemptsTime 计算
+	FailedAttempts int32
+
+	// 任务成功以后 GetOutput 返回的结果
+	Output interface{}
+
+	// 执行过程中的进度，在 Duration 内按照时间均匀上报
+	Progress []lighttaskscheduler.TaskProgress
+
+	// 执行过程中的检查点，在 Duration 内按照时间均匀上报，失败的时候保留最后上报的检查点
+	Checkpoints [][]byte
+
+	// 大于 0 的时候只在开始以后的 HeartbeatFor 内上报心跳，之后任务仍然运行但是心跳停止，模拟执行任务的 worker 丢失
+	HeartbeatFor time.Duration
+
+	// 不为 nil 的时候 Start 返回该错误，IgnoreStartErr 对应 Start 的 ignoreErr 返回值
+	StartErr       error
+	IgnoreStartErr bool
+}
+
+// fakeRun 假执行器中一次执行的状态
+type fakeRun struct {
+	task      lighttaskscheduler.Task
+	script    Script
+	startTime time.Time
+	called    bool // 结束以后是否已经回调
+}
+
+// fakeActuator 可以配置执行脚本的假执行器，任务状态只根据 Clock 计算，没有后台 goroutine，
+// 配合 fakeclock 和调度器的手动模式，可以确定性地测试调度器以及基于调度器的业务代码
+type fakeActuator struct {
+	lock          sync.Mutex
+	clock         lighttaskscheduler.Clock
+	defaultScript Script
+	scripts       map[string]Script   // taskId -> 执行脚本
+	runs          map[string]*fakeRun // 执行中或者结束以后还没有查询过状态的任务
+	outputs       map[string]interface{}
+	starts        map[string]int
+	stops         map[string]int
+	checkpoints   map[string][][]byte // taskId -> 每次 Start 的时候传入的检查点
+
+	callbackChannel chan lighttaskscheduler.Task
+}
+
+// MakeFakeActuator 构造假执行器，没有配置脚本的任务按照 defaultScript 执行
+func MakeFakeActuator(defaultScript Script) *fakeActuator {
+	return &fakeActuator{
+		clock:         lighttaskscheduler.RealClock,
+		defaultScript: defaultScript,
+		scripts:       map[string]Script{},
+		runs:          map[string]*fakeRun{},
+		outputs:       map[string]interface{}{},
+		starts:        map[string]int{},
+		stops:         map[string]int{},
+		checkpoints:   map[string][][]byte{},
+	}
+}
+
+// SetClock 设置执行器使用的时钟
+func (f *fakeActuator) SetClock(clock lighttaskscheduler.Clock) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.clock = lighttaskscheduler.ClockOrReal(clock)
+}
+
+// SetScript 设置任务的执行脚本，对之后的 Start 生效
+func (f *fakeActuator) SetScript(taskId string, script Script) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.scripts[taskId] = script
+}
+
+// SetCallbackChannel 任务配置回调 channel，配置以后通过 DeliverCallbacks 回调已经结束的任务
+func (f *fakeActuator) SetCallbackChannel(callbackChannel chan lighttaskscheduler.Task) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.callbackChannel = callbackChannel
+}
+
+// Starts 任务被 Start 的次数
+func (f *fakeActuator) Starts(taskId string) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.starts[taskId]
+}
+
+// Stops 任务被 Stop 的次数
+func (f *fakeActuator) Stops(taskId string) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.stops[taskId]
+}
+
+// StartCheckpoints 任务每次 Start 的时候传入的 Task.TaskCheckpoint，没有检查点的时候对应 nil
+func (f *fakeActuator) StartCheckpoints(taskId string) [][]byte {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([][]byte{}, f.checkpoints[taskId]...)
+}
+
+// Init 任务在被调度前的初始化工作
+func (f *fakeActuator) Init(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, err error) {
+	return task, nil
+}
+
+// Start 按照脚本开始执行任务
+func (f *fakeActuator) Start(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, ignoreErr bool, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.starts[task.TaskId]++
+	f.checkpoints[task.TaskId] = append(f.checkpoints[task.TaskId], task.TaskCheckpoint)
+	script, ok := f.scripts[task.TaskId]
+	if !ok {
+		script = f.defaultScript
+	}
+	if script.StartErr != nil {
+		return task, script.IgnoreStartErr, script.StartErr
+	}
+	if run, ok := f.runs[task.TaskId]; ok && f.statusOf(run).TaskStatus == lighttaskscheduler.TASK_STATUS_RUNNING {
+		return task, false, fmt.Errorf("task %s is running", task.TaskId)
+	}
+	now := f.clock.Now()
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_RUNNING
+	task.TaskStartTime = now
+	delete(f.outputs, task.TaskId)
+	f.runs[task.TaskId] = &fakeRun{task: *task, script: script, startTime: now}
+	return task, false, nil
+}
+
+// statusOf 根据时钟计算任务当前的状态，需要持有锁
+func (f *fakeActuator) statusOf(run *fakeRun) lighttaskscheduler.AsyncTaskStatus {
+	now := f.clock.Now()
+	elapsed := now.Sub(run.startTime)
+	if elapsed < run.script.Duration {
+		status := lighttaskscheduler.AsyncTaskStatus{
+			TaskStatus:    lighttaskscheduler.TASK_STATUS_RUNNING,
+			LastHeartbeat: now,
+		}
+		if run.script.HeartbeatFor > 0 && elapsed > run.script.HeartbeatFor {
+			status.LastHeartbeat = run.startTime.Add(run.script.HeartbeatFor)
+		}
+		if n := len(run.script.Progress); n > 0 {
+			status.TaskProgress = run.script.Progress[int(int64(n)*int64(elapsed)/int64(run.script.Duration))]
+		}
+		if n := len(run.script.Checkpoints); n > 0 {
+			status.Checkpoint = run.script.Checkpoints[int(int64(n)*int64(elapsed)/int64(run.script.Duration))]
+		}
+		return status
+	}
+	var checkpoint []byte
+	if n := len(run.script.Checkpoints); n > 0 {
+		checkpoint = run.script.Checkpoints[n-1]
+	}
+	if run.task.TaskAttemptsTime < run.script.FailedAttempts {
+		return lighttaskscheduler.AsyncTaskStatus{
+			TaskStatus:   lighttaskscheduler.TASK_STATUS_FAILED,
+			FailedReason: fmt.Errorf("scripted failure of attempt %d", run.task.TaskAttemptsTime),
+			Checkpoint:   checkpoint,
+		}
+	}
+	if run.script.Err != nil {
+		return lighttaskscheduler.AsyncTaskStatus{
+			TaskStatus:   lighttaskscheduler.TASK_STATUS_FAILED,
+			FailedReason: run.script.Err,
+			Checkpoint:   checkpoint,
+		}
+	}
+	return lighttaskscheduler.AsyncTaskStatus{TaskStatus: lighttaskscheduler.TASK_STATUS_SUCCESS}
+}
+
+// finish 任务结束状态已经被查询或者回调，执行器不再维护任务状态，需要持有锁
+func (f *fakeActuator) finish(run *fakeRun, status lighttaskscheduler.AsyncTaskStatus) {
+	delete(f.runs, run.task.TaskId)
+	if status.TaskStatus == lighttaskscheduler.TASK_STATUS_SUCCESS {
+		f.outputs[run.task.TaskId] = run.script.Output
+	}
+}
+
+// GetAsyncTaskStatus 批量获取任务状态，结束状态只返回一次
+func (f *fakeActuator) GetAsyncTaskStatus(ctx context.Context, tasks []lighttaskscheduler.Task) (
+	status []lighttaskscheduler.AsyncTaskStatus, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for _, task := range tasks {
+		run, ok := f.runs[task.TaskId]
+		if !ok {
+			status = append(status, lighttaskscheduler.AsyncTaskStatus{
+				TaskStatus:   lighttaskscheduler.TASK_STATUS_FAILED,
+				FailedReason: errors.New("task not found"),
+			})
+			continue
+		}
+		st := f.statusOf(run)
+		if st.TaskStatus != lighttaskscheduler.TASK_STATUS_RUNNING {
+			f.finish(run, st)
+		}
+		status = append(status, st)
+	}
+	return status, nil
+}
+
+// DeliverCallbacks 通过回调 channel 回调所有已经结束的任务，返回回调的任务数
+func (f *fakeActuator) DeliverCallbacks(ctx context.Context) (count int, err error) {
+	f.lock.Lock()
+	if f.callbackChannel == nil {
+		f.lock.Unlock()
+		return 0, fmt.Errorf("callback channel is not set")
+	}
+	callbackChannel := f.callbackChannel
+	callbacks := []lighttaskscheduler.Task{}
+	for _, run := range f.runs {
+		if run.called {
+			continue
+		}
+		st := f.statusOf(run)
+		if st.TaskStatus == lighttaskscheduler.TASK_STATUS_RUNNING {
+			continue
+		}
+		run.called = true
+		task := run.task
+		task.TaskStatus = st.TaskStatus
+		task.FailedReason = st.FailedReason
+		task.TaskCheckpoint = st.Checkpoint
+		task.TaskEnbTime = f.clock.Now()
+		callbacks = append(callbacks, task)
+	}
+	f.lock.Unlock()
+	for _, task := range callbacks {
+		select {
+		case callbackChannel <- task:
+			count++
+		case <-ctx.Done():
+			return count, ctx.Err()
+		}
+	}
+	return count, nil
+}
+
+// Stop 停止任务
+func (f *fakeActuator) Stop(ctx context.Context, task *lighttaskscheduler.Task) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.stops[task.TaskId]++
+	delete(f.runs, task.TaskId)
+	delete(f.outputs, task.TaskId)
+	return nil
+}
+
+// GetOutput 获取任务成功以后脚本配置的结果
+func (f *fakeActuator) GetOutput(ctx context.Context, task *lighttaskscheduler.Task) (
+	data interface{}, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if run, ok := f.runs[task.TaskId]; ok {
+		// 回调模式下结束状态没有通过 GetAsyncTaskStatus 查询
+		if st := f.statusOf(run); st.TaskStatus == lighttaskscheduler.TASK_STATUS_SUCCESS {
+			f.finish(run, st)
+		}
+	}
+	data, ok := f.outputs[task.TaskId]
+	if !ok {
+		return nil, fmt.Errorf("not found result for task %s", task.TaskId)
+	}
+	delete(f.outputs, task.TaskId)
+	return data, nil
+}
+
+// DeleteOutput 删除任务的结果
+func (f *fakeActuator) DeleteOutput(ctx context.Context, task *lighttaskscheduler.Task) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.outputs, task.TaskId)
+	return nil
+}
@@ -0,0 +1,117 @@
+package actuatortest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+	"github.com/memory-overflow/light-task-scheduler/fakeclock"
+)
+
+var startTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// TestFakeActuatorSuite 假执行器本身也要满足执行器的一致性要求，时间通过假时钟推进
+func TestFakeActuatorSuite(t *testing.T) {
+	clock := fakeclock.MakeFakeClock(startTime)
+	var current interface {
+		SetScript(taskId string, script actuatortest.Script)
+	}
+	actuatortest.RunSuite(t, actuatortest.Suite{
+		NewActuator: func(t *testing.T) lighttaskscheduler.TaskActuator {
+			a := actuatortest.MakeFakeActuator(actuatortest.Script{Duration: time.Second})
+			a.SetClock(clock)
+			current = a
+			return a
+		},
+		NewSuccessTask: func(taskId string) lighttaskscheduler.Task {
+			return lighttaskscheduler.Task{TaskId: taskId}
+		},
+		NewFailedTask: func(taskId string) lighttaskscheduler.Task {
+			current.SetScript(taskId, actuatortest.Script{Duration: time.Second, Err: errors.New("run error")})
+			return lighttaskscheduler.Task{TaskId: taskId}
+		},
+		NewLongTask: func(taskId string) lighttaskscheduler.Task {
+			current.SetScript(taskId, actuatortest.Script{Duration: 24 * time.Hour})
+			return lighttaskscheduler.Task{TaskId: taskId}
+		},
+		WaitTimeout: time.Second,
+		Advance:     func() { clock.Advance(100 * time.Millisecond) },
+	})
+}
+
+// channelReceiver 把假执行器的回调 channel 交给调度器
+type channelReceiver chan lighttaskscheduler.Task
+
+func (r channelReceiver) GetCallbackChannel(ctx context.Context) chan lighttaskscheduler.Task {
+	return r
+}
+
+// TestFakeActuatorCallback 手动模式的调度器通过假执行器的回调结束任务，时间只由假时钟决定
+func TestFakeActuatorCallback(t *testing.T) {
+	ctx := context.Background()
+	clock := fakeclock.MakeFakeClock(startTime)
+	a := actuatortest.MakeFakeActuator(actuatortest.Script{Duration: time.Minute, Output: "output"})
+	a.SetClock(clock)
+	a.SetScript("failed", actuatortest.Script{Duration: 2 * time.Minute, Err: errors.New("run error")})
+	receiver := make(channelReceiver, 10)
+	a.SetCallbackChannel(receiver)
+	container := memeorycontainer.MakeQueueContainer(16, time.Millisecond)
+	sch, err := lighttaskscheduler.MakeScheduler(container, a, nil, lighttaskscheduler.Config{
+		TaskLimit:             2,
+		TaskTimeout:           time.Hour,
+		DisableStatePoll:      true,
+		EnableStateCallback:   true,
+		CallbackReceiver:      receiver,
+		EnableFinshedTaskList: true,
+		Clock:                 clock,
+		ManualStep:            true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sch.Close()
+	for _, taskId := range []string{"success", "failed"} {
+		if err := sch.AddTask(ctx, lighttaskscheduler.Task{TaskId: taskId}); err != nil {
+			t.Fatalf("AddTask error: %v", err)
+		}
+	}
+	if _, err := sch.ScheduleOnce(ctx); err != nil {
+		t.Fatalf("ScheduleOnce error: %v", err)
+	}
+
+	for _, step := range []struct {
+		advance time.Duration
+		want    string
+		status  lighttaskscheduler.TaskStatus
+	}{
+		{time.Minute, "success", lighttaskscheduler.TASK_STATUS_SUCCESS},
+		{time.Minute, "failed", lighttaskscheduler.TASK_STATUS_FAILED},
+	} {
+		clock.Advance(step.advance)
+		if count, err := a.DeliverCallbacks(ctx); err != nil || count != 1 {
+			t.Fatalf("DeliverCallbacks want 1 callback, got %d, err: %v", count, err)
+		}
+		report, err := sch.DrainCallbacks(ctx)
+		if err != nil || report.Callbacks != 1 {
+			t.Fatalf("DrainCallbacks want 1 callback, got %+v, err: %v", report, err)
+		}
+		select {
+		case task := <-sch.FinshedTasks():
+			if task.TaskId != step.want || task.TaskStatus != step.status {
+				t.Fatalf("finished task want %s %v, got %s %v", step.want, step.status, task.TaskId, task.TaskStatus)
+			}
+		default:
+			t.Fatalf("task %s is not finished", step.want)
+		}
+	}
+	if count, _ := container.GetRunningTaskCount(ctx); count != 0 {
+		t.Fatalf("running tasks after callbacks want 0, got %d", count)
+	}
+	if starts := a.Starts("success") + a.Starts("failed"); starts != 2 {
+		t.Fatalf("Starts want 2, got %d", starts)
+	}
+}
@@ -0,0 +1,127 @@
+package lighttaskscheduler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AdaptiveConcurrencyConfig 自适应并发配置，使用 AIMD（加性增、乘性减）算法根据执行器的反馈调整实际的并发限制
+// 任务启动延时和失败率健康的时候，每个调度周期并发限制增加 IncreaseStep，
+// Start 返回错误、ignoreErr 重试或者启动延时超过阈值的时候，并发限制乘以 DecreaseFactor
+type AdaptiveConcurrencyConfig struct {
+	// 并发限制的下限和上限，实际并发限制在 [MinTaskLimit, MaxTaskLimit] 之间调整
+	MinTaskLimit int32
+	MaxTaskLimit int32
+
+	// 每个健康的调度周期增加的并发数，默认 1
+	IncreaseStep int32
+
+	// 不健康的调度周期并发限制的缩减系数，取值 (0, 1)，默认 0.5
+	DecreaseFactor float64
+
+	// 单个任务 Start 的延时阈值，调度周期内平均启动延时超过阈值视为不健康，0 表示不检查延时
+	LatencyThreshold time.Duration
+
+	// 调度周期内任务启动失败率阈值，超过阈值视为不健康，取值 [0, 1)，默认 0，即有任何失败都视为不健康
+	FailureRateThreshold float64
+}
+
+func (c *AdaptiveConcurrencyConfig) check() error {
+	if c.MinTaskLimit <= 0 || c.MaxTaskLimit < c.MinTaskLimit {
+		return fmt.Errorf("unreasonable config, AdaptiveConcurrency must satisfy 0 < MinTaskLimit <= MaxTaskLimit")
+	}
+	if c.IncreaseStep < 0 || c.DecreaseFactor < 0 || c.DecreaseFactor >= 1 {
+		return fmt.Errorf("unreasonable config, AdaptiveConcurrency IncreaseStep must not be negative " +
+			"and DecreaseFactor must in [0, 1)")
+	}
+	if c.LatencyThreshold < 0 || c.FailureRateThreshold < 0 || c.FailureRateThreshold >= 1 {
+		return fmt.Errorf("unreasonable config, AdaptiveConcurrency LatencyThreshold must not be negative " +
+			"and FailureRateThreshold must in [0, 1)")
+	}
+	return nil
+}
+
+// concurrencyController 自适应并发控制器，统计一个调度周期内任务启动的反馈，在周期结束的时候调整并发限制
+type concurrencyController struct {
+	lock  sync.Mutex
+	limit float64
+	base  int32 // 当前并发限制开始调整时的 TaskLimit，TaskLimit 修改以后从新的值重新开始调整
+
+	startCount   int
+	failedCount  int
+	totalLatency time.Duration
+}
+
+// limitOf 返回当前实际生效的并发限制，未开启自适应并发的时候，返回配置的 TaskLimit
+func (c *concurrencyController) limitOf(config Config) int32 {
+	ac := config.AdaptiveConcurrency
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if ac == nil {
+		// 关闭自适应并发，重新开启的时候从 TaskLimit 开始调整
+		c.limit = 0
+		return config.TaskLimit
+	}
+	if c.limit == 0 || c.base != config.TaskLimit {
+		// 第一次使用或者运行时修改了 TaskLimit，从 TaskLimit 开始调整
+		c.limit, c.base = float64(config.TaskLimit), config.TaskLimit
+	}
+	// 运行时修改了 MinTaskLimit、MaxTaskLimit 的时候重新限制在新的范围内
+	c.limit = clampLimit(c.limit, ac)
+	return int32(c.limit)
+}
+
+// record 记录一次任务启动的反馈，failed 表示 Start 返回错误，包括 ignoreErr 的重试
+func (c *concurrencyController) record(latency time.Duration, failed bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.startCount++
+	c.totalLatency += latency
+	if failed {
+		c.failedCount++
+	}
+}
+
+// adjust 在调度周期结束的时候，根据周期内的反馈调整并发限制
+func (c *concurrencyController) adjust(config Config) {
+	ac := config.AdaptiveConcurrency
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	defer func() {
+		// 没有开启自适应并发的时候也清空反馈，避免运行时开启以后使用之前累积的反馈
+		c.startCount, c.failedCount, c.totalLatency = 0, 0, 0
+	}()
+	if ac == nil || c.startCount == 0 {
+		// 本周期没有调度任务，没有反馈，不调整
+		return
+	}
+	healthy := float64(c.failedCount)/float64(c.startCount) <= ac.FailureRateThreshold
+	if ac.LatencyThreshold > 0 && c.totalLatency/time.Duration(c.startCount) > ac.LatencyThreshold {
+		healthy = false
+	}
+	if healthy {
+		step := ac.IncreaseStep
+		if step == 0 {
+			step = 1
+		}
+		c.limit += float64(step)
+	} else {
+		factor := ac.DecreaseFactor
+		if factor == 0 {
+			factor = 0.5
+		}
+		c.limit *= factor
+	}
+	c.limit = clampLimit(c.limit, ac)
+}
+
+func clampLimit(limit float64, ac *AdaptiveConcurrencyConfig) float64 {
+	if limit < float64(ac.MinTaskLimit) {
+		return float64(ac.MinTaskLimit)
+	}
+	if limit > float64(ac.MaxTaskLimit) {
+		return float64(ac.MaxTaskLimit)
+	}
+	return limit
+}
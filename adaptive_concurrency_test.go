@@ -0,0 +1,116 @@
+package lighttaskscheduler_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+)
+
+// runCycles 执行 n 个调度周期，每个周期补充足够的等待任务，返回每个周期以后的实际并发限制
+func runCycles(t *testing.T, h *harness, prefix string, n int) []int32 {
+	t.Helper()
+	limits := []int32{}
+	for i := 0; i < n; i++ {
+		for j := 0; j < 8; j++ {
+			h.add(t, lighttaskscheduler.Task{TaskId: fmt.Sprintf("%s-%d-%d", prefix, i, j)})
+		}
+		h.schedule(t)
+		limits = append(limits, h.sch.Stats().EffectiveTaskLimit)
+		h.clock.Advance(time.Second)
+		h.poll(t)
+	}
+	return limits
+}
+
+// TestAdaptiveConcurrency 健康的调度周期并发限制加性增加，启动失败的周期乘性减少，都限制在 [MinTaskLimit, MaxTaskLimit] 内
+func TestAdaptiveConcurrency(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		script actuatortest.Script
+		ac     lighttaskscheduler.AdaptiveConcurrencyConfig
+		want   []int32
+	}{
+		{
+			name:   "increase",
+			script: actuatortest.Script{Duration: time.Second},
+			ac:     lighttaskscheduler.AdaptiveConcurrencyConfig{MinTaskLimit: 1, MaxTaskLimit: 6},
+			want:   []int32{5, 6, 6},
+		},
+		{
+			name:   "increase step",
+			script: actuatortest.Script{Duration: time.Second},
+			ac:     lighttaskscheduler.AdaptiveConcurrencyConfig{MinTaskLimit: 1, MaxTaskLimit: 16, IncreaseStep: 3},
+			want:   []int32{7, 10, 13},
+		},
+		{
+			name:   "decrease",
+			script: actuatortest.Script{StartErr: errors.New("start error")},
+			ac:     lighttaskscheduler.AdaptiveConcurrencyConfig{MinTaskLimit: 1, MaxTaskLimit: 8},
+			want:   []int32{2, 1, 1},
+		},
+		{
+			name:   "decrease factor",
+			script: actuatortest.Script{StartErr: errors.New("start error")},
+			ac: lighttaskscheduler.AdaptiveConcurrencyConfig{MinTaskLimit: 2, MaxTaskLimit: 8,
+				DecreaseFactor: 0.75},
+			want: []int32{3, 2, 2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ac := tc.ac
+			h := makeHarness(t, tc.script, func(c *lighttaskscheduler.Config) {
+				c.TaskLimit = 4
+				c.AdaptiveConcurrency = &ac
+			})
+			if limit := h.sch.Stats().EffectiveTaskLimit; limit != 4 {
+				t.Fatalf("EffectiveTaskLimit before scheduling want 4, got %d", limit)
+			}
+			if got := runCycles(t, h, "task", len(tc.want)); fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Fatalf("EffectiveTaskLimit want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestAdaptiveConcurrencyReset 修改 TaskLimit 或者关闭自适应并发以后，并发限制从新的 TaskLimit 重新开始调整
+func TestAdaptiveConcurrencyReset(t *testing.T) {
+	h := makeHarness(t, actuatortest.Script{Duration: time.Second}, func(c *lighttaskscheduler.Config) {
+		c.TaskLimit = 4
+		c.AdaptiveConcurrency = &lighttaskscheduler.AdaptiveConcurrencyConfig{MinTaskLimit: 1, MaxTaskLimit: 8}
+	})
+	runCycles(t, h, "before", 2)
+	for _, step := range []struct {
+		name   string
+		update func(c *lighttaskscheduler.Config)
+		want   int32
+	}{
+		{"TaskLimit", func(c *lighttaskscheduler.Config) { c.TaskLimit = 3 }, 3},
+		{"disable", func(c *lighttaskscheduler.Config) { c.AdaptiveConcurrency = nil; c.TaskLimit = 5 }, 5},
+		{"enable", func(c *lighttaskscheduler.Config) {
+			c.AdaptiveConcurrency = &lighttaskscheduler.AdaptiveConcurrencyConfig{MinTaskLimit: 1, MaxTaskLimit: 8}
+		}, 5},
+		{"MaxTaskLimit", func(c *lighttaskscheduler.Config) {
+			c.AdaptiveConcurrency = &lighttaskscheduler.AdaptiveConcurrencyConfig{MinTaskLimit: 1, MaxTaskLimit: 2}
+		}, 2},
+	} {
+		if err := h.sch.UpdateConfig(step.update); err != nil {
+			t.Fatalf("%s: UpdateConfig error: %v", step.name, err)
+		}
+		if limit := h.sch.Stats().EffectiveTaskLimit; limit != step.want {
+			t.Fatalf("%s: EffectiveTaskLimit want %d, got %d", step.name, step.want, limit)
+		}
+	}
+	// 重新开启以后按照新的配置继续调整
+	if err := h.sch.UpdateConfig(func(c *lighttaskscheduler.Config) {
+		c.AdaptiveConcurrency = &lighttaskscheduler.AdaptiveConcurrencyConfig{MinTaskLimit: 1, MaxTaskLimit: 8}
+	}); err != nil {
+		t.Fatalf("UpdateConfig error: %v", err)
+	}
+	if got := runCycles(t, h, "after", 2); fmt.Sprint(got) != "[3 4]" {
+		t.Fatalf("EffectiveTaskLimit after re-enable want [3 4], got %v", got)
+	}
+}
@@ -0,0 +1,266 @@
+package lighttaskscheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrAdmissionRejected 任务被准入控制拒绝，可以通过 errors.Is 判断
+var ErrAdmissionRejected = errors.New("task rejected by admission control")
+
+// AdmissionError 任务被准入控制拒绝的原因，调用方可以按照 RetryAfter 稍后重试，比如 API 层返回 429 和 Retry-After
+type AdmissionError struct {
+	TaskId     string
+	Key        string        // 任务所属的队列或者租户，全局限制的时候为空
+	Waiting    int32         // 拒绝时等待中的任务数
+	Limit      int32         // 触发拒绝的限制
+	Reason     string        // 拒绝的原因
+	RetryAfter time.Duration // 建议的重试间隔
+}
+
+func (e *AdmissionError) Error() string {
+	return fmt.Sprintf("task %s rejected by admission control: %s, waiting %d, limit %d, retry after %v",
+		e.TaskId, e.Reason, e.Waiting, e.Limit, e.RetryAfter)
+}
+
+// Unwrap 支持 errors.Is(err, ErrAdmissionRejected)
+func (e *AdmissionError) Unwrap() error {
+	return ErrAdmissionRejected
+}
+
+// IsAdmissionRejected 判断错误是否是被准入控制拒绝
+func IsAdmissionRejected(err error) bool {
+	return errors.Is(err, ErrAdmissionRejected)
+}
+
+// RetryAfter 被准入控制拒绝的时候返回建议的重试间隔
+func RetryAfter(err error) (time.Duration, bool) {
+	var admissionErr *AdmissionError
+	if errors.As(err, &admissionErr) {
+		return admissionErr.RetryAfter, true
+	}
+	return 0, false
+}
+
+const defaultAdmissionRetryAfter = time.Second
+
+// WaitingTaskCounter 可以统计等待中任务数的任务容器，实现以后准入控制的全局限制使用容器中真实的等待任务数，
+// 包括其他副本添加的任务、重启以前已经在等待的任务，框架的队列容器、orderedMap 容器和 redis 容器已经实现
+type WaitingTaskCounter interface {
+	// GetWaitingTaskCount 获取等待中的任务数
+	GetWaitingTaskCount(ctx context.Context) (count int32, err error)
+}
+
+// AdmissionConfig AddTask 的准入控制配置，
+// 任务容器实现了 WaitingTaskCounter 的时候，全局限制按照容器中等待中的任务数加上正在添加的任务数计算，
+// 否则只统计通过本调度器添加并且还没有被调度的任务，多副本部署的时候每个副本分别统计；
+// 容器不能按照队列统计，每个队列的限制总是只统计通过本调度器添加的任务
+type AdmissionConfig struct {
+	// 全局最多等待中的任务数，0 表示不限制
+	MaxWaiting int32
+
+	// 全局等待中的任务数达到 ShedWaiting 以后开始按照优先级限流，只接受 TaskPriority 大于等于 ShedPriority 的任务，
+	// 0 表示不按照优先级限流
+	ShedWaiting  int32
+	ShedPriority int
+
+	// 任务所属的队列或者租户，为 nil 的时候不按照队列限制
+	KeyFunc func(task Task) string
+
+	// 每个队列最多等待中的任务数，0 表示不限制，KeyLimits 可以为单独的队列配置不同的限制
+	MaxWaitingPerKey int32
+	KeyLimits        map[string]int32
+
+	// 拒绝的时候建议的重试间隔，默认 1 秒，配置了 Config.DurationEstimator 的时候按照超出的任务数预计排空的时间，不小于该值
+	RetryAfter time.Duration
+}
+
+func (c *AdmissionConfig) check() error {
+	if c.MaxWaiting < 0 || c.ShedWaiting < 0 || c.MaxWaitingPerKey < 0 || c.RetryAfter < 0 {
+		return fmt.Errorf("unreasonable admission config, limits and RetryAfter must not be negative")
+	}
+	if c.MaxWaiting > 0 && c.ShedWaiting > c.MaxWaiting {
+		return fmt.Errorf("unreasonable admission config, ShedWaiting must not be greater than MaxWaiting")
+	}
+	for key, limit := range c.KeyLimits {
+		if limit < 0 {
+			return fmt.Errorf("unreasonable admission config, limit of key %s must not be negative", key)
+		}
+	}
+	return nil
+}
+
+func (c *AdmissionConfig) keyLimit(key string) int32 {
+	if limit, ok := c.KeyLimits[key]; ok {
+		return limit
+	}
+	return c.MaxWaitingPerKey
+}
+
+// AdmissionStats 准入控制的统计
+type AdmissionStats struct {
+	Waiting  int32 // 最近一次准入检查时等待中的任务数，任务容器不能统计的时候是通过本调度器添加还在等待中的任务数
+	Admitted int64 // 累计接受的任务数
+	Rejected int64 // 累计拒绝的任务数
+}
+
+// admissionController 准入控制，统计通过调度器添加并且还在等待中的任务，用于每个队列的限制，
+// 以及任务容器不能统计等待中任务数时的全局限制
+type admissionController struct {
+	lock     sync.Mutex
+	tasks    map[string]string // 等待中的任务，taskId -> key
+	keys     map[string]int32  // 每个队列等待中的任务数
+	adding   map[string]bool   // 已经通过准入检查，正在添加到任务容器的任务
+	counted  bool              // 最近一次准入检查是否使用了任务容器统计的等待中任务数
+	waiting  int32             // 任务容器统计的等待中任务数，counted 的时候有效
+	admitted int64
+	rejected int64
+}
+
+// admit 检查准入限制，接受的时候预占一个等待名额，添加到任务容器以后需要调用 settle，
+// taskLimit 是当前实际的并发限制，开启自适应并发的时候和 TaskLimit 不同
+func (a *admissionController) admit(ctx context.Context, config Config, taskLimit int32, container TaskContainer,
+	task Task) error {
+	ac := config.Admission
+	counted, count := false, int32(0)
+	if counter, ok := ContainerAs[WaitingTaskCounter](container); ok && ac != nil &&
+		(ac.MaxWaiting > 0 || ac.ShedWaiting > 0) {
+		var err error
+		if count, err = counter.GetWaitingTaskCount(ctx); err != nil {
+			return fmt.Errorf("get waiting task count error: %v", err)
+		}
+		counted = true
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.tasks == nil {
+		a.tasks, a.keys, a.adding = map[string]string{}, map[string]int32{}, map[string]bool{}
+	}
+	if ac == nil {
+		a.reserve(task.TaskId, "", false)
+		return nil
+	}
+	waiting := int32(len(a.tasks))
+	if counted {
+		// 正在添加的任务还没有计入容器的统计
+		waiting = count + int32(len(a.adding))
+		a.counted, a.waiting = true, count
+	}
+	reject := func(key string, waiting, limit int32, reason string) error {
+		a.rejected++
+		return &AdmissionError{TaskId: task.TaskId, Key: key, Waiting: waiting, Limit: limit, Reason: reason,
+			RetryAfter: retryAfter(config, taskLimit, task, waiting-limit+1)}
+	}
+	if ac.MaxWaiting > 0 && waiting >= ac.MaxWaiting {
+		return reject("", waiting, ac.MaxWaiting, "too many waiting tasks")
+	}
+	if ac.ShedWaiting > 0 && waiting >= ac.ShedWaiting && task.TaskPriority < ac.ShedPriority {
+		return reject("", waiting, ac.ShedWaiting, fmt.Sprintf("shedding tasks with priority lower than %d", ac.ShedPriority))
+	}
+	// 任务容器可以统计的时候，本地只需要记录每个队列的任务
+	key, track := "", !counted
+	if ac.KeyFunc != nil {
+		track = true
+		key = ac.KeyFunc(task)
+		if limit := ac.keyLimit(key); limit > 0 && a.keys[key] >= limit {
+			if a.prune(ctx, container, key); a.keys[key] >= limit {
+				return reject(key, a.keys[key], limit, fmt.Sprintf("too many waiting tasks of %s", key))
+			}
+		}
+	}
+	a.reserve(task.TaskId, key, track)
+	return nil
+}
+
+// prune 删除队列中已经不在等待的任务，比如被其他副本调度或者直接从容器删除的任务，需要持有锁，
+// 只在队列达到限制的时候调用，任务容器需要实现 TaskGetter
+func (a *admissionController) prune(ctx context.Context, container TaskContainer, key string) {
+	getter, ok := ContainerAs[TaskGetter](container)
+	if !ok {
+		return
+	}
+	for taskId, k := range a.tasks {
+		if k != key || a.adding[taskId] {
+			// 正在添加的任务还不在任务容器中
+			continue
+		}
+		task, found, err := getter.GetTask(ctx, taskId)
+		if err != nil {
+			log.Printf("get task %s error: %v\n", taskId, err)
+			return
+		}
+		if !found || task.TaskStatus != TASK_STATUS_WAITING {
+			a.remove(taskId)
+		}
+	}
+}
+
+// reserve 记录正在添加的任务，track 为 true 的时候同时记录到等待中的任务，需要持有锁
+func (a *admissionController) reserve(taskId, key string, track bool) {
+	a.adding[taskId] = true
+	a.admitted++
+	if _, ok := a.tasks[taskId]; ok || !track {
+		return
+	}
+	a.tasks[taskId] = key
+	a.keys[key]++
+}
+
+// remove 删除等待中的任务，需要持有锁
+func (a *admissionController) remove(taskId string) {
+	key, ok := a.tasks[taskId]
+	if !ok {
+		return
+	}
+	delete(a.tasks, taskId)
+	if a.keys[key]--; a.keys[key] <= 0 {
+		delete(a.keys, key)
+	}
+}
+
+// settle 任务添加到任务容器结束，added 为 false 表示添加失败，撤销预占的名额
+func (a *admissionController) settle(taskId string, added bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	delete(a.adding, taskId)
+	if !added {
+		a.remove(taskId)
+		a.admitted--
+	}
+}
+
+// release 任务离开等待队列，被调度或者停止
+func (a *admissionController) release(taskId string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.remove(taskId)
+}
+
+func (a *admissionController) snapshot() AdmissionStats {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	waiting := int32(len(a.tasks))
+	if a.counted {
+		waiting = a.waiting
+	}
+	return AdmissionStats{Waiting: waiting, Admitted: a.admitted, Rejected: a.rejected}
+}
+
+// retryAfter 建议的重试间隔，配置了执行时间估计器的时候，按照超出的任务数和当前实际的并发限制预计排空的时间
+func retryAfter(config Config, taskLimit int32, task Task, excess int32) time.Duration {
+	d := config.Admission.RetryAfter
+	if d <= 0 {
+		d = defaultAdmissionRetryAfter
+	}
+	if config.DurationEstimator != nil && taskLimit > 0 && excess > 0 {
+		if drain := config.DurationEstimator.Estimate(task) * time.Duration(excess) /
+			time.Duration(taskLimit); drain > d {
+			d = drain
+		}
+	}
+	return d
+}
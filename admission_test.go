@@ -0,0 +1,57 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+)
+
+// fixedEstimator 所有任务的预计执行时间都相同
+type fixedEstimator time.Duration
+
+func (e fixedEstimator) Observe(task lighttaskscheduler.Task) {}
+
+func (e fixedEstimator) Estimate(task lighttaskscheduler.Task) time.Duration { return time.Duration(e) }
+
+// TestAdmissionRetryAfter 建议的重试间隔按照当前实际的并发限制计算，开启自适应并发的时候不使用 TaskLimit
+func TestAdmissionRetryAfter(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		adaptive *lighttaskscheduler.AdaptiveConcurrencyConfig
+		want     time.Duration
+	}{
+		// 超出 1 个任务，预计排空时间为 1 分钟 / 并发限制
+		{"TaskLimit", nil, time.Minute / 8},
+		// 启动失败以后自适应并发限制减半到 4
+		{"adaptive limit", &lighttaskscheduler.AdaptiveConcurrencyConfig{MinTaskLimit: 1, MaxTaskLimit: 8},
+			time.Minute / 4},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := makeHarness(t, actuatortest.Script{Duration: time.Hour}, func(c *lighttaskscheduler.Config) {
+				c.TaskLimit = 8
+				c.AdaptiveConcurrency = tc.adaptive
+				c.DurationEstimator = fixedEstimator(time.Minute)
+				c.Admission = &lighttaskscheduler.AdmissionConfig{MaxWaiting: 4}
+			})
+			h.actuator.SetScript("failed", actuatortest.Script{StartErr: errors.New("start error")})
+			h.add(t, lighttaskscheduler.Task{TaskId: "failed"})
+			h.schedule(t)
+			for i := 0; i < 4; i++ {
+				h.add(t, lighttaskscheduler.Task{TaskId: fmt.Sprintf("task-%d", i)})
+			}
+			err := h.sch.AddTask(context.Background(), lighttaskscheduler.Task{TaskId: "rejected"})
+			var admissionErr *lighttaskscheduler.AdmissionError
+			if !errors.As(err, &admissionErr) {
+				t.Fatalf("AddTask want admission error, got %v", err)
+			}
+			if admissionErr.RetryAfter != tc.want {
+				t.Fatalf("RetryAfter want %v, got %v", tc.want, admissionErr.RetryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package chaos
+
+import (
+	"context"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+)
+
+// chaosActuator 故障注入的任务执行器
+type chaosActuator struct {
+	*injector
+	actuator lighttaskscheduler.TaskActuator
+}
+
+// MakeActuator 构造故障注入的任务执行器
+// Start 注入的错误不可忽略，任务会按照失败处理；对 GetAsyncTaskStatus 注入延时可以模拟查询状态很慢的执行器
+func MakeActuator(actuator lighttaskscheduler.TaskActuator, config Config) (*chaosActuator, error) {
+	i, err := makeInjector(config)
+	if err != nil {
+		return nil, err
+	}
+	return &chaosActuator{injector: i, actuator: actuator}, nil
+}
+
+// Init 任务在被调度前的初始化工作
+func (a *chaosActuator) Init(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, err error) {
+	if err = a.before(ctx, "Init"); err != nil {
+		return task, err
+	}
+	if newTask, err = a.actuator.Init(ctx, task); err != nil {
+		return newTask, err
+	}
+	return newTask, a.after("Init")
+}
+
+// Start 开始执行任务
+func (a *chaosActuator) Start(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, ignoreErr bool, err error) {
+	if err = a.before(ctx, "Start"); err != nil {
+		return task, false, err
+	}
+	if newTask, ignoreErr, err = a.actuator.Start(ctx, task); err != nil {
+		return newTask, ignoreErr, err
+	}
+	// 任务已经启动但是响应丢失，执行器中会残留一个运行中的任务，和真实的故障一致
+	return newTask, false, a.after("Start")
+}
+
+// GetOutput 获取任务执行的结果
+func (a *chaosActuator) GetOutput(ctx context.Context, task *lighttaskscheduler.Task) (
+	data interface{}, err error) {
+	if err = a.before(ctx, "GetOutput"); err != nil {
+		return nil, err
+	}
+	if data, err = a.actuator.GetOutput(ctx, task); err != nil {
+		return data, err
+	}
+	if err = a.after("GetOutput"); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Stop 停止任务
+func (a *chaosActuator) Stop(ctx context.Context, task *lighttaskscheduler.Task) error {
+	if err := a.before(ctx, "Stop"); err != nil {
+		return err
+	}
+	if err := a.actuator.Stop(ctx, task); err != nil {
+		return err
+	}
+	return a.after("Stop")
+}
+
+// GetAsyncTaskStatus 批量获取任务状态
+func (a *chaosActuator) GetAsyncTaskStatus(ctx context.Context, tasks []lighttaskscheduler.Task) (
+	status []lighttaskscheduler.AsyncTaskStatus, err error) {
+	if err = a.before(ctx, "GetAsyncTaskStatus"); err != nil {
+		return nil, err
+	}
+	if status, err = a.actuator.GetAsyncTaskStatus(ctx, tasks); err != nil {
+		return status, err
+	}
+	if err = a.after("GetAsyncTaskStatus"); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
@@ -0,0 +1,62 @@
+package chaos
+
+import (
+	"context"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+)
+
+// chaosCallbackReceiver 故障注入的回调接收器，按照概率丢弃、重复投递和延迟回调
+type chaosCallbackReceiver struct {
+	*injector
+	receiver lighttaskscheduler.CallbackReceiver
+}
+
+// MakeCallbackReceiver 构造故障注入的回调接收器，丢弃回调以后需要开启轮询兜底任务状态
+func MakeCallbackReceiver(receiver lighttaskscheduler.CallbackReceiver, config Config) (
+	*chaosCallbackReceiver, error) {
+	i, err := makeInjector(config)
+	if err != nil {
+		return nil, err
+	}
+	return &chaosCallbackReceiver{injector: i, receiver: receiver}, nil
+}
+
+// GetCallbackChannel 返回注入故障以后的回调 channel，被包装的回调 channel 关闭以后返回的 channel 也会关闭
+func (r *chaosCallbackReceiver) GetCallbackChannel(ctx context.Context) (taskChannel chan lighttaskscheduler.Task) {
+	inner := r.receiver.GetCallbackChannel(ctx)
+	taskChannel = make(chan lighttaskscheduler.Task, cap(inner))
+	go func() {
+		defer close(taskChannel)
+		for task := range inner {
+			times := r.deliverTimes(ctx)
+			for i := 0; i < times; i++ {
+				select {
+				case taskChannel <- task:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return taskChannel
+}
+
+// deliverTimes 一个回调投递的次数，0 表示丢弃
+func (r *chaosCallbackReceiver) deliverTimes(ctx context.Context) int {
+	const method = "GetCallbackChannel"
+	if !r.active(method) {
+		return 1
+	}
+	r.calls.Add(1)
+	r.delay(ctx)
+	if r.hit(r.config.DropRate) {
+		r.drops.Add(1)
+		return 0
+	}
+	if r.hit(r.config.DuplicateRate) {
+		r.duplicates.Add(1)
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,177 @@
+// Package chaos 故障注入的任务容器、执行器、数据持久化和回调接收器，按照配置的概率注入错误、延时、
+// 丢失的响应以及丢弃和重复的回调，用来验证调度器和业务代码在依赖异常的情况下不会丢失任务
+
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+)
+
+// ErrInjected 注入的错误，可以通过 errors.Is 判断
+var ErrInjected = errors.New("chaos injected error")
+
+// IsInjected 判断错误是否是注入的错误
+func IsInjected(err error) bool {
+	return errors.Is(err, ErrInjected)
+}
+
+// Config 故障注入的配置，所有概率的取值范围都是 [0, 1]
+type Config struct {
+	// 随机种子，相同的种子和相同的调用顺序注入相同的故障
+	Seed int64
+
+	// 调用返回错误的概率，返回错误的时候不会调用被包装的对象，模拟 db 抖动、网络错误
+	ErrorRate float64
+
+	// 调用被包装的对象成功以后仍然返回错误的概率，模拟操作已经生效但是响应丢失，或者导出的过程中崩溃
+	LostResponseRate float64
+
+	// 调用之前增加延时的概率，延时在 (0, Latency] 之间随机
+	LatencyRate float64
+	Latency     time.Duration
+
+	// 回调被丢弃的概率，只对回调接收器生效
+	DropRate float64
+
+	// 回调被重复投递的概率，只对回调接收器生效
+	DuplicateRate float64
+
+	// 只对这些方法注入故障，方法名和接口的方法名一致，比如 "GetAsyncTaskStatus"，为空的时候对所有方法注入
+	Methods []string
+
+	// 延时使用的时钟，为 nil 的时候使用系统时间
+	Clock lighttaskscheduler.Clock
+}
+
+func (c *Config) check() error {
+	for _, rate := range []float64{c.ErrorRate, c.LostResponseRate, c.LatencyRate, c.DropRate, c.DuplicateRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("unreasonable chaos config, rate must be in [0, 1]")
+		}
+	}
+	if c.Latency < 0 {
+		return fmt.Errorf("unreasonable chaos config, Latency must not be negative")
+	}
+	return nil
+}
+
+// Stats 故障注入的统计
+type Stats struct {
+	Calls         int64 // 经过故障注入的调用数
+	Errors        int64 // 注入的错误数
+	LostResponses int64 // 注入的丢失响应数
+	Delays        int64 // 注入的延时数
+	Drops         int64 // 丢弃的回调数
+	Duplicates    int64 // 重复投递的回调数
+}
+
+// injector 按照配置的概率决定是否注入故障，所有包装器共享相同的实现
+type injector struct {
+	config  Config
+	methods map[string]bool
+	clock   lighttaskscheduler.Clock
+	enabled atomic.Bool
+
+	lock sync.Mutex
+	rand *rand.Rand
+
+	calls, errors, lostResponses, delays, drops, duplicates atomic.Int64
+}
+
+func makeInjector(config Config) (*injector, error) {
+	if err := config.check(); err != nil {
+		return nil, err
+	}
+	i := &injector{
+		config:  config,
+		methods: map[string]bool{},
+		clock:   lighttaskscheduler.ClockOrReal(config.Clock),
+		rand:    rand.New(rand.NewSource(config.Seed)),
+	}
+	for _, method := range config.Methods {
+		i.methods[method] = true
+	}
+	i.enabled.Store(true)
+	return i, nil
+}
+
+// SetEnabled 开启或者关闭故障注入，关闭以后所有调用直接转发给被包装的对象，
+// 可以在测试的最后关闭故障注入，等待任务全部结束以后校验结果
+func (i *injector) SetEnabled(enabled bool) {
+	i.enabled.Store(enabled)
+}
+
+// Stats 故障注入的统计
+func (i *injector) Stats() Stats {
+	return Stats{
+		Calls:         i.calls.Load(),
+		Errors:        i.errors.Load(),
+		LostResponses: i.lostResponses.Load(),
+		Delays:        i.delays.Load(),
+		Drops:         i.drops.Load(),
+		Duplicates:    i.duplicates.Load(),
+	}
+}
+
+// hit 按照概率判断是否命中
+func (i *injector) hit(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.rand.Float64() < rate
+}
+
+func (i *injector) active(method string) bool {
+	return i.enabled.Load() && (len(i.methods) == 0 || i.methods[method])
+}
+
+// delay 按照概率增加延时，ctx 结束的时候提前返回
+func (i *injector) delay(ctx context.Context) {
+	if i.config.Latency <= 0 || !i.hit(i.config.LatencyRate) {
+		return
+	}
+	i.lock.Lock()
+	d := time.Duration(i.rand.Int63n(int64(i.config.Latency))) + 1
+	i.lock.Unlock()
+	i.delays.Add(1)
+	select {
+	case <-i.clock.After(d):
+	case <-ctx.Done():
+	}
+}
+
+// before 调用被包装的对象之前注入延时和错误，返回错误的时候不再调用被包装的对象
+func (i *injector) before(ctx context.Context, method string) error {
+	if !i.active(method) {
+		return nil
+	}
+	i.calls.Add(1)
+	i.delay(ctx)
+	if i.hit(i.config.ErrorRate) {
+		i.errors.Add(1)
+		return fmt.Errorf("%s: %w", method, ErrInjected)
+	}
+	return nil
+}
+
+// after 调用被包装的对象成功以后按照概率丢失响应
+func (i *injector) after(method string) error {
+	if !i.active(method) {
+		return nil
+	}
+	if i.hit(i.config.LostResponseRate) {
+		i.lostResponses.Add(1)
+		return fmt.Errorf("%s response lost: %w", method, ErrInjected)
+	}
+	return nil
+}
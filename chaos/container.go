@@ -0,0 +1,155 @@
+package chaos
+
+import (
+	"context"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+)
+
+// chaosContainer 故障注入的任务容器
+// 检查点、发件箱等可选接口通过 Unwrap 直接访问被包装的容器，不会注入故障
+type chaosContainer struct {
+	*injector
+	container lighttaskscheduler.TaskContainer
+}
+
+// MakeContainer 构造故障注入的任务容器
+// 注意对 GetWaitingTask 注入丢失响应的时候，队列型的容器已经弹出的任务会丢失，和真实的故障一致
+func MakeContainer(container lighttaskscheduler.TaskContainer, config Config) (*chaosContainer, error) {
+	i, err := makeInjector(config)
+	if err != nil {
+		return nil, err
+	}
+	return &chaosContainer{injector: i, container: container}, nil
+}
+
+// Unwrap 返回被包装的任务容器
+func (c *chaosContainer) Unwrap() lighttaskscheduler.TaskContainer {
+	return c.container
+}
+
+// transfer 注入故障以后执行状态转移
+func (c *chaosContainer) transfer(ctx context.Context, method string, task *lighttaskscheduler.Task,
+	do func() (*lighttaskscheduler.Task, error)) (*lighttaskscheduler.Task, error) {
+	if err := c.before(ctx, method); err != nil {
+		return task, err
+	}
+	newTask, err := do()
+	if err != nil {
+		return newTask, err
+	}
+	return newTask, c.after(method)
+}
+
+// AddTask 添加任务
+func (c *chaosContainer) AddTask(ctx context.Context, task lighttaskscheduler.Task) (err error) {
+	if err = c.before(ctx, "AddTask"); err != nil {
+		return err
+	}
+	if err = c.container.AddTask(ctx, task); err != nil {
+		return err
+	}
+	return c.after("AddTask")
+}
+
+// GetRunningTask 获取运行中的任务
+func (c *chaosContainer) GetRunningTask(ctx context.Context) (tasks []lighttaskscheduler.Task, err error) {
+	if err = c.before(ctx, "GetRunningTask"); err != nil {
+		return nil, err
+	}
+	if tasks, err = c.container.GetRunningTask(ctx); err != nil {
+		return tasks, err
+	}
+	if err = c.after("GetRunningTask"); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// GetRunningTaskCount 获取运行中的任务数
+func (c *chaosContainer) GetRunningTaskCount(ctx context.Context) (count int32, err error) {
+	if err = c.before(ctx, "GetRunningTaskCount"); err != nil {
+		return 0, err
+	}
+	if count, err = c.container.GetRunningTaskCount(ctx); err != nil {
+		return count, err
+	}
+	if err = c.after("GetRunningTaskCount"); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetWaitingTask 获取等待中的任务
+func (c *chaosContainer) GetWaitingTask(ctx context.Context, limit int32) (tasks []lighttaskscheduler.Task, err error) {
+	if err = c.before(ctx, "GetWaitingTask"); err != nil {
+		return nil, err
+	}
+	if tasks, err = c.container.GetWaitingTask(ctx, limit); err != nil {
+		return tasks, err
+	}
+	if err = c.after("GetWaitingTask"); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// ToRunningStatus 转移到运行中的状态
+func (c *chaosContainer) ToRunningStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, err error) {
+	return c.transfer(ctx, "ToRunningStatus", task, func() (*lighttaskscheduler.Task, error) {
+		return c.container.ToRunningStatus(ctx, task)
+	})
+}
+
+// ToStopStatus 转移到停止状态
+func (c *chaosContainer) ToStopStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, err error) {
+	return c.transfer(ctx, "ToStopStatus", task, func() (*lighttaskscheduler.Task, error) {
+		return c.container.ToStopStatus(ctx, task)
+	})
+}
+
+// ToDeleteStatus 转移到删除状态
+func (c *chaosContainer) ToDeleteStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, err error) {
+	return c.transfer(ctx, "ToDeleteStatus", task, func() (*lighttaskscheduler.Task, error) {
+		return c.container.ToDeleteStatus(ctx, task)
+	})
+}
+
+// ToFailedStatus 转移到失败状态
+func (c *chaosContainer) ToFailedStatus(ctx context.Context, task *lighttaskscheduler.Task, reason error) (
+	newTask *lighttaskscheduler.Task, err error) {
+	return c.transfer(ctx, "ToFailedStatus", task, func() (*lighttaskscheduler.Task, error) {
+		return c.container.ToFailedStatus(ctx, task, reason)
+	})
+}
+
+// ToExportStatus 转移到数据导出状态
+func (c *chaosContainer) ToExportStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, err error) {
+	return c.transfer(ctx, "ToExportStatus", task, func() (*lighttaskscheduler.Task, error) {
+		return c.container.ToExportStatus(ctx, task)
+	})
+}
+
+// ToSuccessStatus 转移到执行成功状态
+func (c *chaosContainer) ToSuccessStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, err error) {
+	return c.transfer(ctx, "ToSuccessStatus", task, func() (*lighttaskscheduler.Task, error) {
+		return c.container.ToSuccessStatus(ctx, task)
+	})
+}
+
+// UpdateRunningTaskStatus 更新执行中的任务状态
+func (c *chaosContainer) UpdateRunningTaskStatus(ctx context.Context,
+	task *lighttaskscheduler.Task, status lighttaskscheduler.AsyncTaskStatus) error {
+	if err := c.before(ctx, "UpdateRunningTaskStatus"); err != nil {
+		return err
+	}
+	if err := c.container.UpdateRunningTaskStatus(ctx, task, status); err != nil {
+		return err
+	}
+	return c.after("UpdateRunningTaskStatus")
+}
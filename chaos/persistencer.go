@@ -0,0 +1,62 @@
+package chaos
+
+import (
+	"context"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+)
+
+// chaosPersistencer 故障注入的任务数据持久化
+type chaosPersistencer struct {
+	*injector
+	persistencer lighttaskscheduler.TaskdataPersistencer
+}
+
+// MakePersistencer 构造故障注入的任务数据持久化
+// 对 DataPersistence 注入丢失响应，可以模拟结果已经写入但是导出的过程中崩溃
+func MakePersistencer(persistencer lighttaskscheduler.TaskdataPersistencer, config Config) (
+	*chaosPersistencer, error) {
+	i, err := makeInjector(config)
+	if err != nil {
+		return nil, err
+	}
+	return &chaosPersistencer{injector: i, persistencer: persistencer}, nil
+}
+
+// DataPersistence 持久化任务数据
+func (p *chaosPersistencer) DataPersistence(ctx context.Context, task *lighttaskscheduler.Task,
+	data interface{}) (err error) {
+	if err = p.before(ctx, "DataPersistence"); err != nil {
+		return err
+	}
+	if err = p.persistencer.DataPersistence(ctx, task, data); err != nil {
+		return err
+	}
+	return p.after("DataPersistence")
+}
+
+// GetPersistenceData 查询任务持久化结果
+func (p *chaosPersistencer) GetPersistenceData(ctx context.Context, task *lighttaskscheduler.Task) (
+	data interface{}, err error) {
+	if err = p.before(ctx, "GetPersistenceData"); err != nil {
+		return nil, err
+	}
+	if data, err = p.persistencer.GetPersistenceData(ctx, task); err != nil {
+		return data, err
+	}
+	if err = p.after("GetPersistenceData"); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// DeletePersistenceData 删除任务的持久化结果
+func (p *chaosPersistencer) DeletePersistenceData(ctx context.Context, task *lighttaskscheduler.Task) (err error) {
+	if err = p.before(ctx, "DeletePersistenceData"); err != nil {
+		return err
+	}
+	if err = p.persistencer.DeletePersistenceData(ctx, task); err != nil {
+		return err
+	}
+	return p.after("DeletePersistenceData")
+}
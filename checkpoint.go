@@ -0,0 +1,72 @@
+package lighttaskscheduler
+
+import (
+	"context"
+	"log"
+)
+
+// CheckpointStore 任务检查点存储，任务容器或者数据持久化可以选择实现该接口
+// 执行器通过 AsyncTaskStatus.Checkpoint 或者回调的 Task.TaskCheckpoint 上报检查点，调度器负责保存，
+// 任务重试或者恢复以后重新 Start 的时候，调度器把最新的检查点放到 Task.TaskCheckpoint，执行器可以从检查点继续执行
+type CheckpointStore interface {
+	// SaveCheckpoint 保存任务最新的检查点，覆盖之前的检查点
+	SaveCheckpoint(ctx context.Context, task *Task, checkpoint []byte) (err error)
+
+	// GetCheckpoint 获取任务最新的检查点，没有检查点返回 nil
+	GetCheckpoint(ctx context.Context, task *Task) (checkpoint []byte, err error)
+
+	// DeleteCheckpoint 删除任务的检查点
+	DeleteCheckpoint(ctx context.Context, task *Task) (err error)
+}
+
+// checkpointStore 优先使用任务容器实现的检查点存储，其次是数据持久化，都没有实现返回 nil
+func (s *TaskScheduler) checkpointStore() CheckpointStore {
+	if store, ok := ContainerAs[CheckpointStore](s.Container); ok {
+		return store
+	}
+	if store, ok := s.Persistencer.(CheckpointStore); ok {
+		return store
+	}
+	return nil
+}
+
+// saveCheckpoint 保存执行器上报的检查点
+func (s *TaskScheduler) saveCheckpoint(ctx context.Context, task *Task, checkpoint []byte) {
+	if checkpoint == nil {
+		return
+	}
+	store := s.checkpointStore()
+	if store == nil {
+		return
+	}
+	if err := store.SaveCheckpoint(ctx, task, checkpoint); err != nil {
+		log.Printf("save checkpoint for task %s error: %v\n", task.TaskId, err)
+	}
+}
+
+// loadCheckpoint 任务 Start 之前加载最新的检查点到 task.TaskCheckpoint
+func (s *TaskScheduler) loadCheckpoint(ctx context.Context, task *Task) {
+	store := s.checkpointStore()
+	if store == nil {
+		return
+	}
+	checkpoint, err := store.GetCheckpoint(ctx, task)
+	if err != nil {
+		log.Printf("get checkpoint for task %s error: %v\n", task.TaskId, err)
+		return
+	}
+	if checkpoint != nil {
+		task.TaskCheckpoint = checkpoint
+	}
+}
+
+// deleteCheckpoint 任务成功以后检查点不再需要
+func (s *TaskScheduler) deleteCheckpoint(ctx context.Context, task *Task) {
+	store := s.checkpointStore()
+	if store == nil {
+		return
+	}
+	if err := store.DeleteCheckpoint(ctx, task); err != nil {
+		log.Printf("delete checkpoint for task %s error: %v\n", task.TaskId, err)
+	}
+}
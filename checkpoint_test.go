@@ -0,0 +1,99 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+)
+
+var checkpoints = [][]byte{[]byte("c1"), []byte("c2"), []byte("c3"), []byte("c4")}
+
+// TestCheckpointRetry 任务重试的时候从最新的检查点继续执行，成功以后删除检查点
+func TestCheckpointRetry(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		script actuatortest.Script
+		poll   time.Duration // 轮询间隔
+		want   []string      // 每次 Start 传入的检查点
+	}{
+		{
+			name:   "no checkpoint",
+			script: actuatortest.Script{Duration: 4 * time.Second, FailedAttempts: 1},
+			poll:   time.Second,
+			want:   []string{"", ""},
+		},
+		{
+			name:   "checkpoint reported while running",
+			script: actuatortest.Script{Duration: 4 * time.Second, FailedAttempts: 1, Checkpoints: checkpoints},
+			poll:   time.Second,
+			want:   []string{"", "c4"},
+		},
+		{
+			name:   "checkpoint reported with failure",
+			script: actuatortest.Script{Duration: 4 * time.Second, FailedAttempts: 1, Checkpoints: checkpoints},
+			poll:   5 * time.Second,
+			want:   []string{"", "c4"},
+		},
+		{
+			name:   "latest checkpoint of each attempt",
+			script: actuatortest.Script{Duration: 4 * time.Second, FailedAttempts: 2, Checkpoints: checkpoints},
+			poll:   time.Second,
+			want:   []string{"", "c4", "c4"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := makeHarness(t, tc.script, func(c *lighttaskscheduler.Config) {
+				c.MaxFailedAttempts = 2
+			})
+			h.add(t, lighttaskscheduler.Task{TaskId: "task"})
+			h.schedule(t)
+			var finished *lighttaskscheduler.Task
+			for i := 0; i < 20 && finished == nil; i++ {
+				h.clock.Advance(tc.poll)
+				h.poll(t)
+				finished = h.finished()["task"]
+			}
+			if finished == nil || finished.TaskStatus != lighttaskscheduler.TASK_STATUS_SUCCESS {
+				t.Fatalf("task is not succeeded: %+v", finished)
+			}
+			if got := fmt.Sprintf("%q", h.actuator.StartCheckpoints("task")); got != fmt.Sprintf("%q", tc.want) {
+				t.Fatalf("checkpoints of each Start want %q, got %s", tc.want, got)
+			}
+			store, _ := lighttaskscheduler.ContainerAs[lighttaskscheduler.CheckpointStore](h.container)
+			if checkpoint, _ := store.GetCheckpoint(context.Background(), finished); checkpoint != nil {
+				t.Fatalf("checkpoint of succeeded task is not deleted: %s", checkpoint)
+			}
+		})
+	}
+}
+
+// TestCheckpointRestart 调度器和执行器重启以后，执行器丢失的任务按照失败重试，从重启之前保存的检查点继续执行
+func TestCheckpointRestart(t *testing.T) {
+	container := memeorycontainer.MakeQueueContainer(16, time.Millisecond)
+	script := actuatortest.Script{Duration: 4 * time.Second, Checkpoints: checkpoints}
+	update := func(c *lighttaskscheduler.Config) { c.MaxFailedAttempts = 1 }
+	before := makeHarnessWith(t, container, script, update)
+	before.add(t, lighttaskscheduler.Task{TaskId: "task"})
+	before.schedule(t)
+	before.clock.Advance(2 * time.Second)
+	before.poll(t) // 保存检查点 c3
+
+	// 重启以后使用新的执行器，之前的执行已经丢失
+	after := makeHarnessWith(t, container, script, update)
+	if got := after.poll(t); len(got.Errors) != 0 {
+		t.Fatalf("PollOnce after restart errors: %v", got.Errors)
+	}
+	if got := fmt.Sprintf("%q", after.actuator.StartCheckpoints("task")); got != `["c3"]` {
+		t.Fatalf("checkpoints of Start after restart want [\"c3\"], got %s", got)
+	}
+	after.clock.Advance(4 * time.Second)
+	after.poll(t)
+	if task := after.finished()["task"]; task == nil || task.TaskStatus != lighttaskscheduler.TASK_STATUS_SUCCESS {
+		t.Fatalf("task is not succeeded after restart: %+v", task)
+	}
+}
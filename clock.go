@@ -0,0 +1,61 @@
+package lighttaskscheduler
+
+import "time"
+
+// Clock 时钟，调度器、框架提供的任务容器和执行器中所有和时间相关的逻辑都通过 Clock 获取时间，
+// 测试的时候可以使用 fakeclock 包手动推进时间，快速、确定地测试超时和重试
+type Clock interface {
+	// Now 当前时间
+	Now() time.Time
+
+	// After 等待 d 时间以后返回当前时间
+	After(d time.Duration) <-chan time.Time
+
+	// NewTicker 新建周期为 d 的定时器
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker 定时器
+type Ticker interface {
+	// C 定时器的 channel
+	C() <-chan time.Time
+
+	// Reset 修改定时器的周期
+	Reset(d time.Duration)
+
+	// Stop 停止定时器
+	Stop()
+}
+
+// RealClock 使用系统时间的时钟，没有配置 Clock 的时候默认使用
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// ClockOrReal 返回 clock，为 nil 的时候返回 RealClock
+func ClockOrReal(clock Clock) Clock {
+	if clock == nil {
+		return RealClock
+	}
+	return clock
+}
@@ -0,0 +1,65 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+	"github.com/memory-overflow/light-task-scheduler/fakeclock"
+)
+
+// TestFakeClockAutomatic 非手动模式下调度和轮询的周期也由时钟驱动，时间不推进的时候任务不会被调度
+func TestFakeClockAutomatic(t *testing.T) {
+	clock := fakeclock.MakeFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	actuator := actuatortest.MakeFakeActuator(actuatortest.Script{Duration: 10 * time.Second})
+	actuator.SetClock(clock)
+	container := memeorycontainer.MakeQueueContainer(16, time.Millisecond)
+	container.SetClock(clock)
+	sch, err := lighttaskscheduler.MakeScheduler(container, actuator, nil, lighttaskscheduler.Config{
+		TaskLimit:              2,
+		TaskTimeout:            time.Hour,
+		SchedulingPollInterval: time.Second,
+		StatePollInterval:      time.Second,
+		EnableFinshedTaskList:  true,
+		Clock:                  clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sch.Close()
+	if err := sch.AddTask(context.Background(), lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	clock.BlockUntil(2) // 调度和轮询的定时器
+	time.Sleep(20 * time.Millisecond)
+	if starts := actuator.Starts("task"); starts != 0 {
+		t.Fatalf("task is started before the clock advances, Starts %d", starts)
+	}
+
+	waitUntil := func(what string, done func() bool) {
+		deadline := time.Now().Add(5 * time.Second)
+		for !done() {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s in 5s", what)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	clock.Advance(time.Second)
+	waitUntil("task is not started", func() bool { return actuator.Starts("task") == 1 })
+	clock.Advance(10 * time.Second)
+	select {
+	case task := <-sch.FinshedTasks():
+		if task.TaskId != "task" || task.TaskStatus != lighttaskscheduler.TASK_STATUS_SUCCESS {
+			t.Fatalf("finished task want task SUCCESS, got %s %v", task.TaskId, task.TaskStatus)
+		}
+		if cost := task.TaskEnbTime.Sub(task.TaskStartTime); cost < 10*time.Second {
+			t.Fatalf("task finished in %v of the fake clock, want at least 10s", cost)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("task is not finished after the clock advances")
+	}
+}
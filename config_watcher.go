@@ -0,0 +1,113 @@
+package lighttaskscheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// FileConfig 可以从配置文件热加载的配置项，未配置的字段保持不变
+// 时间使用 time.ParseDuration 支持的格式，比如 "5s", "100ms"
+type FileConfig struct {
+	TaskTimeout            *string `json:"task_timeout,omitempty"`
+	TaskLimit              *int32  `json:"task_limit,omitempty"`
+	MaxFailedAttempts      *int32  `json:"max_failed_attempts,omitempty"`
+	SchedulingWindow       *int32  `json:"scheduling_window,omitempty"`
+	SchedulingPollInterval *string `json:"scheduling_poll_interval,omitempty"`
+	StatePollInterval      *string `json:"state_poll_interval,omitempty"`
+}
+
+// apply 把配置文件的配置项应用到 Config
+func (f *FileConfig) apply(c *Config) error {
+	parse := func(name string, value *string, target *time.Duration) error {
+		if value == nil {
+			return nil
+		}
+		d, err := time.ParseDuration(*value)
+		if err != nil {
+			return fmt.Errorf("parse %s error: %v", name, err)
+		}
+		*target = d
+		return nil
+	}
+	if err := parse("task_timeout", f.TaskTimeout, &c.TaskTimeout); err != nil {
+		return err
+	}
+	if err := parse("scheduling_poll_interval", f.SchedulingPollInterval, &c.SchedulingPollInterval); err != nil {
+		return err
+	}
+	if err := parse("state_poll_interval", f.StatePollInterval, &c.StatePollInterval); err != nil {
+		return err
+	}
+	if f.TaskLimit != nil {
+		c.TaskLimit = *f.TaskLimit
+	}
+	if f.MaxFailedAttempts != nil {
+		c.MaxFailedAttempts = *f.MaxFailedAttempts
+	}
+	if f.SchedulingWindow != nil {
+		c.SchedulingWindow = *f.SchedulingWindow
+	}
+	return nil
+}
+
+// LoadConfigFile 从 json 配置文件加载配置，并且更新到调度器
+func (s *TaskScheduler) LoadConfigFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file %s error: %v", path, err)
+	}
+	var fileConfig FileConfig
+	if err := json.Unmarshal(content, &fileConfig); err != nil {
+		return fmt.Errorf("unmarshal config file %s error: %v", path, err)
+	}
+	// 先在副本上检查配置文件格式，避免只更新了部分配置
+	config := s.Config()
+	if err := fileConfig.apply(&config); err != nil {
+		return err
+	}
+	return s.UpdateConfig(func(c *Config) {
+		fileConfig.apply(c)
+	})
+}
+
+// WatchConfigFile 定期检查 json 配置文件，文件修改后自动重新加载配置，直到 ctx 或者调度器结束
+// 配置文件有错误的时候，打印日志并保持原有配置，interval 必须大于 0
+func (s *TaskScheduler) WatchConfigFile(ctx context.Context, path string, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("config file watch interval must be positive, got %v", interval)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stat config file %s error: %v", path, err)
+	}
+	if err := s.LoadConfigFile(path); err != nil {
+		return err
+	}
+	lastModTime := info.ModTime()
+	go func() {
+		ticker := s.clock.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C():
+				info, err := os.Stat(path)
+				if err != nil || !info.ModTime().After(lastModTime) {
+					continue
+				}
+				lastModTime = info.ModTime()
+				if err := s.LoadConfigFile(path); err != nil {
+					log.Printf("reload config file %s error: %v\n", path, err)
+				}
+			}
+		}
+	}()
+	return nil
+}
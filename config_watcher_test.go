@@ -0,0 +1,157 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+	"github.com/memory-overflow/light-task-scheduler/fakeclock"
+)
+
+// makeConfigScheduler 构造手动模式的调度器，配置文件的测试只关心配置
+func makeConfigScheduler(t *testing.T, clock lighttaskscheduler.Clock) *lighttaskscheduler.TaskScheduler {
+	t.Helper()
+	sch, err := lighttaskscheduler.MakeScheduler(memeorycontainer.MakeQueueContainer(16, time.Millisecond),
+		actuatortest.MakeFakeActuator(actuatortest.Script{}), nil, lighttaskscheduler.Config{
+			TaskLimit:         2,
+			TaskTimeout:       time.Minute,
+			MaxFailedAttempts: 1,
+			Clock:             clock,
+			ManualStep:        true,
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sch.Close)
+	return sch
+}
+
+func writeConfigFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateConfigRejectsInvalid(t *testing.T) {
+	sch := makeConfigScheduler(t, nil)
+	for _, tc := range []struct {
+		name   string
+		update func(c *lighttaskscheduler.Config)
+	}{
+		{"negative TaskLimit", func(c *lighttaskscheduler.Config) { c.TaskLimit = -1 }},
+		{"negative TaskTimeout", func(c *lighttaskscheduler.Config) { c.TaskTimeout = -time.Second }},
+		{"DisableStatePoll", func(c *lighttaskscheduler.Config) { c.DisableStatePoll = true }},
+		{"ManualStep", func(c *lighttaskscheduler.Config) { c.ManualStep = false }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := sch.UpdateConfig(tc.update); err == nil {
+				t.Fatal("invalid config is accepted")
+			}
+			if config := sch.Config(); config.TaskLimit != 2 || config.TaskTimeout != time.Minute ||
+				config.DisableStatePoll || !config.ManualStep {
+				t.Fatalf("rejected update changed config: %+v", config)
+			}
+		})
+	}
+	if err := sch.UpdateConfig(func(c *lighttaskscheduler.Config) { c.TaskLimit = 5 }); err != nil {
+		t.Fatalf("UpdateConfig error: %v", err)
+	}
+	if limit := sch.Config().TaskLimit; limit != 5 {
+		t.Fatalf("TaskLimit want 5, got %d", limit)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	sch := makeConfigScheduler(t, nil)
+	path := filepath.Join(t.TempDir(), "config.json")
+	for _, tc := range []struct {
+		name    string
+		content string
+	}{
+		{"malformed json", `{"task_limit": `},
+		{"invalid duration", `{"task_limit": 3, "task_timeout": "ten seconds"}`},
+		{"negative limit", `{"task_limit": -3, "task_timeout": "10s"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			writeConfigFile(t, path, tc.content, time.Now())
+			if err := sch.LoadConfigFile(path); err == nil {
+				t.Fatal("invalid config file is accepted")
+			}
+			if config := sch.Config(); config.TaskLimit != 2 || config.TaskTimeout != time.Minute {
+				t.Fatalf("rejected config file changed config: limit %d, timeout %v", config.TaskLimit, config.TaskTimeout)
+			}
+		})
+	}
+	writeConfigFile(t, path, `{"task_limit": 3, "task_timeout": "10s"}`, time.Now())
+	if err := sch.LoadConfigFile(path); err != nil {
+		t.Fatalf("LoadConfigFile error: %v", err)
+	}
+	if config := sch.Config(); config.TaskLimit != 3 || config.TaskTimeout != 10*time.Second ||
+		config.MaxFailedAttempts != 1 {
+		t.Fatalf("config after LoadConfigFile: limit %d, timeout %v, attempts %d",
+			config.TaskLimit, config.TaskTimeout, config.MaxFailedAttempts)
+	}
+}
+
+func TestWatchConfigFileInvalidInterval(t *testing.T) {
+	sch := makeConfigScheduler(t, nil)
+	path := filepath.Join(t.TempDir(), "config.json")
+	writeConfigFile(t, path, `{"task_limit": 3}`, time.Now())
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if err := sch.WatchConfigFile(context.Background(), path, interval); err == nil {
+			t.Fatalf("interval %v is accepted", interval)
+		}
+	}
+}
+
+func TestWatchConfigFileReload(t *testing.T) {
+	clock := fakeclock.MakeFakeClock(time.Now())
+	sch := makeConfigScheduler(t, clock)
+	path := filepath.Join(t.TempDir(), "config.json")
+	modTime := time.Now().Add(-time.Hour)
+	writeConfigFile(t, path, `{"task_limit": 3}`, modTime)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := sch.WatchConfigFile(ctx, path, time.Second); err != nil {
+		t.Fatalf("WatchConfigFile error: %v", err)
+	}
+	if limit := sch.Config().TaskLimit; limit != 3 {
+		t.Fatalf("TaskLimit after WatchConfigFile want 3, got %d", limit)
+	}
+	clock.BlockUntil(1)
+
+	// 修改时间没有变化的时候不重新加载
+	writeConfigFile(t, path, `{"task_limit": 4}`, modTime)
+	clock.Advance(time.Second)
+	time.Sleep(20 * time.Millisecond)
+	if limit := sch.Config().TaskLimit; limit != 3 {
+		t.Fatalf("config reloaded without mtime change, TaskLimit %d", limit)
+	}
+
+	// 错误的配置文件保持原有配置
+	writeConfigFile(t, path, `{"task_limit": -1}`, modTime.Add(time.Minute))
+	clock.Advance(time.Second)
+	time.Sleep(20 * time.Millisecond)
+	if limit := sch.Config().TaskLimit; limit != 3 {
+		t.Fatalf("invalid config file is reloaded, TaskLimit %d", limit)
+	}
+
+	writeConfigFile(t, path, `{"task_limit": 5}`, modTime.Add(2*time.Minute))
+	clock.Advance(time.Second)
+	deadline := time.Now().Add(5 * time.Second)
+	for sch.Config().TaskLimit != 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("config is not reloaded after mtime change, TaskLimit %d", sch.Config().TaskLimit)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
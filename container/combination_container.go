@@ -3,6 +3,7 @@ package container
 import (
 	"context"
 	"fmt"
+	"time"
 
 	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
 	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
@@ -48,7 +49,7 @@ func MakeCombinationContainer(memeoryContainer memeorycontainer.MemeoryContainer
 // AddTask 添加任务
 func (c *combinationContainer) AddTask(ctx context.Context, task lighttaskscheduler.Task) (err error) {
 	if err = c.memeoryContainer.AddTask(ctx, task); err != nil {
-		return fmt.Errorf("memeoryContainer AddTask error: %v", err)
+		return fmt.Errorf("memeoryContainer AddTask error: %w", err)
 	}
 	defer func() {
 		if err != nil {
@@ -56,7 +57,7 @@ func (c *combinationContainer) AddTask(ctx context.Context, task lighttaskschedu
 		}
 	}()
 	if err = c.persistContainer.AddTask(ctx, task); err != nil {
-		return fmt.Errorf("persistContainer AddTask error: %v", err)
+		return fmt.Errorf("persistContainer AddTask error: %w", err)
 	}
 	return nil
 }
@@ -79,11 +80,13 @@ func (c *combinationContainer) GetWaitingTask(ctx context.Context, limit int32)
 // ToRunningStatus 转移到运行中的状态
 func (c *combinationContainer) ToRunningStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
+	version := task.TaskVersion
 	if newTask, err = c.persistContainer.ToRunningStatus(ctx, task); err != nil {
-		return newTask, fmt.Errorf("persistContainer ToRunningStatus error: %v", err)
+		return newTask, fmt.Errorf("persistContainer ToRunningStatus error: %w", err)
 	}
+	task.TaskVersion = version // 以内存容器的版本为准
 	if newTask, err = c.memeoryContainer.ToRunningStatus(ctx, task); err != nil {
-		return newTask, fmt.Errorf("memeoryContainer ToRunningStatus error: %v", err)
+		return newTask, fmt.Errorf("memeoryContainer ToRunningStatus error: %w", err)
 	}
 	return newTask, nil
 }
@@ -91,12 +94,13 @@ func (c *combinationContainer) ToRunningStatus(ctx context.Context, task *lightt
 // ToExportStatus 转移到停止状态
 func (c *combinationContainer) ToStopStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
-
+	version := task.TaskVersion
 	if newTask, err = c.persistContainer.ToStopStatus(ctx, task); err != nil {
-		return newTask, fmt.Errorf("persistContainer ToStopStatus error: %v", err)
+		return newTask, fmt.Errorf("persistContainer ToStopStatus error: %w", err)
 	}
+	task.TaskVersion = version // 以内存容器的版本为准
 	if newTask, err = c.memeoryContainer.ToStopStatus(ctx, task); err != nil {
-		return newTask, fmt.Errorf("memeoryContainer ToRunningStatus error: %v", err)
+		return newTask, fmt.Errorf("memeoryContainer ToRunningStatus error: %w", err)
 	}
 	return newTask, nil
 }
@@ -104,11 +108,13 @@ func (c *combinationContainer) ToStopStatus(ctx context.Context, task *lighttask
 // ToExportStatus 转移到删除状态
 func (c *combinationContainer) ToDeleteStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
+	version := task.TaskVersion
 	if newTask, err = c.persistContainer.ToDeleteStatus(ctx, task); err != nil {
-		return newTask, fmt.Errorf("persistContainer ToStopStatus error: %v", err)
+		return newTask, fmt.Errorf("persistContainer ToStopStatus error: %w", err)
 	}
+	task.TaskVersion = version // 以内存容器的版本为准
 	if newTask, err = c.memeoryContainer.ToDeleteStatus(ctx, task); err != nil {
-		return newTask, fmt.Errorf("memeoryContainer ToRunningStatus error: %v", err)
+		return newTask, fmt.Errorf("memeoryContainer ToRunningStatus error: %w", err)
 	}
 	return newTask, nil
 }
@@ -116,11 +122,13 @@ func (c *combinationContainer) ToDeleteStatus(ctx context.Context, task *lightta
 // ToFailedStatus 转移到失败状态
 func (c *combinationContainer) ToFailedStatus(ctx context.Context, task *lighttaskscheduler.Task, reason error) (
 	newTask *lighttaskscheduler.Task, err error) {
+	version := task.TaskVersion
 	if newTask, err = c.persistContainer.ToFailedStatus(ctx, task, reason); err != nil {
-		return newTask, fmt.Errorf("persistContainer ToFailedStatus error: %v", err)
+		return newTask, fmt.Errorf("persistContainer ToFailedStatus error: %w", err)
 	}
+	task.TaskVersion = version // 以内存容器的版本为准
 	if newTask, err = c.memeoryContainer.ToFailedStatus(ctx, task, reason); err != nil {
-		return newTask, fmt.Errorf("memeoryContainer ToFailedStatus error: %v", err)
+		return newTask, fmt.Errorf("memeoryContainer ToFailedStatus error: %w", err)
 	}
 	return newTask, nil
 }
@@ -128,11 +136,13 @@ func (c *combinationContainer) ToFailedStatus(ctx context.Context, task *lightta
 // ToExportStatus 转移到数据导出状态
 func (c *combinationContainer) ToExportStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
+	version := task.TaskVersion
 	if newTask, err = c.persistContainer.ToExportStatus(ctx, task); err != nil {
-		return newTask, fmt.Errorf("persistContainer ToExportStatus error: %v", err)
+		return newTask, fmt.Errorf("persistContainer ToExportStatus error: %w", err)
 	}
+	task.TaskVersion = version // 以内存容器的版本为准
 	if newTask, err = c.memeoryContainer.ToExportStatus(ctx, task); err != nil {
-		return newTask, fmt.Errorf("memeoryContainer ToExportStatus error: %v", err)
+		return newTask, fmt.Errorf("memeoryContainer ToExportStatus error: %w", err)
 	}
 	return newTask, nil
 }
@@ -140,11 +150,13 @@ func (c *combinationContainer) ToExportStatus(ctx context.Context, task *lightta
 // ToSuccessStatus 转移到执行成功状态
 func (c *combinationContainer) ToSuccessStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
+	version := task.TaskVersion
 	if newTask, err = c.persistContainer.ToSuccessStatus(ctx, task); err != nil {
-		return newTask, fmt.Errorf("persistContainer ToSuccessStatus error: %v", err)
+		return newTask, fmt.Errorf("persistContainer ToSuccessStatus error: %w", err)
 	}
+	task.TaskVersion = version // 以内存容器的版本为准
 	if newTask, err = c.memeoryContainer.ToSuccessStatus(ctx, task); err != nil {
-		return newTask, fmt.Errorf("memeoryContainer ToSuccessStatus error: %v", err)
+		return newTask, fmt.Errorf("memeoryContainer ToSuccessStatus error: %w", err)
 	}
 	return newTask, nil
 }
@@ -153,10 +165,155 @@ func (c *combinationContainer) ToSuccessStatus(ctx context.Context, task *lightt
 func (c *combinationContainer) UpdateRunningTaskStatus(ctx context.Context,
 	task *lighttaskscheduler.Task, status lighttaskscheduler.AsyncTaskStatus) error {
 	if err := c.persistContainer.UpdateRunningTaskStatus(ctx, task, status); err != nil {
-		return fmt.Errorf("persistContainer UpdateRunningTaskStatus error: %v", err)
+		return fmt.Errorf("persistContainer UpdateRunningTaskStatus error: %w", err)
 	}
 	if err := c.memeoryContainer.UpdateRunningTaskStatus(ctx, task, status); err != nil {
-		return fmt.Errorf("memeoryContainer UpdateRunningTaskStatus error: %v", err)
+		return fmt.Errorf("memeoryContainer UpdateRunningTaskStatus error: %w", err)
+	}
+	return nil
+}
+
+// checkpointStore 优先使用可持久化容器保存检查点，其次是内存容器
+func (c *combinationContainer) checkpointStore() lighttaskscheduler.CheckpointStore {
+	if store, ok := c.persistContainer.(lighttaskscheduler.CheckpointStore); ok {
+		return store
+	}
+	if store, ok := c.memeoryContainer.(lighttaskscheduler.CheckpointStore); ok {
+		return store
+	}
+	return nil
+}
+
+// SaveCheckpoint 保存任务检查点
+func (c *combinationContainer) SaveCheckpoint(ctx context.Context, task *lighttaskscheduler.Task,
+	checkpoint []byte) (err error) {
+	store := c.checkpointStore()
+	if store == nil {
+		return fmt.Errorf("neither persistContainer nor memeoryContainer implements CheckpointStore")
+	}
+	return store.SaveCheckpoint(ctx, task, checkpoint)
+}
+
+// GetCheckpoint 获取任务检查点
+func (c *combinationContainer) GetCheckpoint(ctx context.Context, task *lighttaskscheduler.Task) (
+	checkpoint []byte, err error) {
+	store := c.checkpointStore()
+	if store == nil {
+		return nil, nil
+	}
+	return store.GetCheckpoint(ctx, task)
+}
+
+// DeleteCheckpoint 删除任务检查点
+func (c *combinationContainer) DeleteCheckpoint(ctx context.Context, task *lighttaskscheduler.Task) (err error) {
+	store := c.checkpointStore()
+	if store == nil {
+		return nil
+	}
+	return store.DeleteCheckpoint(ctx, task)
+}
+
+// GetTaskProgress 查询运行中的任务进度，优先从内存容器查询
+func (c *combinationContainer) GetTaskProgress(ctx context.Context, task *lighttaskscheduler.Task) (
+	progress lighttaskscheduler.TaskProgress, err error) {
+	if querier, ok := c.memeoryContainer.(lighttaskscheduler.TaskProgressQuerier); ok {
+		return querier.GetTaskProgress(ctx, task)
+	}
+	if querier, ok := c.persistContainer.(lighttaskscheduler.TaskProgressQuerier); ok {
+		return querier.GetTaskProgress(ctx, task)
+	}
+	return progress, fmt.Errorf("neither memeoryContainer nor persistContainer implements TaskProgressQuerier")
+}
+
+// finishedOutbox 优先使用可持久化容器的发件箱，其次是内存容器
+func (c *combinationContainer) finishedOutbox() lighttaskscheduler.FinishedOutbox {
+	if outbox, ok := c.persistContainer.(lighttaskscheduler.FinishedOutbox); ok {
+		return outbox
+	}
+	if outbox, ok := c.memeoryContainer.(lighttaskscheduler.FinishedOutbox); ok {
+		return outbox
 	}
 	return nil
 }
+
+// PushFinished 保存任务完成记录
+func (c *combinationContainer) PushFinished(ctx context.Context, task lighttaskscheduler.Task) (err error) {
+	outbox := c.finishedOutbox()
+	if outbox == nil {
+		return fmt.Errorf("neither persistContainer nor memeoryContainer implements FinishedOutbox")
+	}
+	return outbox.PushFinished(ctx, task)
+}
+
+// NextFinished 获取未确认的完成记录
+func (c *combinationContainer) NextFinished(ctx context.Context, limit int32, ackTimeout time.Duration) (
+	records []lighttaskscheduler.FinishedRecord, err error) {
+	outbox := c.finishedOutbox()
+	if outbox == nil {
+		return nil, fmt.Errorf("neither persistContainer nor memeoryContainer implements FinishedOutbox")
+	}
+	return outbox.NextFinished(ctx, limit, ackTimeout)
+}
+
+// AckFinished 确认完成记录
+func (c *combinationContainer) AckFinished(ctx context.Context, recordIds []string) (err error) {
+	outbox := c.finishedOutbox()
+	if outbox == nil {
+		return fmt.Errorf("neither persistContainer nor memeoryContainer implements FinishedOutbox")
+	}
+	return outbox.AckFinished(ctx, recordIds)
+}
+
+// SupportTaskVersion 组合容器以内存容器的任务版本为准
+func (c *combinationContainer) SupportTaskVersion() bool {
+	versioned, ok := c.memeoryContainer.(lighttaskscheduler.VersionedContainer)
+	return ok && versioned.SupportTaskVersion()
+}
+
+// GetTask 运行中和等待中的任务以内存容器为准，内存容器不支持查询的时候视为没有找到
+func (c *combinationContainer) GetTask(ctx context.Context, taskId string) (
+	task lighttaskscheduler.Task, ok bool, err error) {
+	getter, ok := c.memeoryContainer.(lighttaskscheduler.TaskGetter)
+	if !ok {
+		return task, false, nil
+	}
+	return getter.GetTask(ctx, taskId)
+}
+
+// SupportFinishedTaskList 可持久化容器实现了 FinishedTaskLister 的时候才支持查询已经结束的任务
+func (c *combinationContainer) SupportFinishedTaskList() bool {
+	_, ok := c.persistContainer.(lighttaskscheduler.FinishedTaskLister)
+	return ok
+}
+
+// GetFinishedTask 已结束的任务只保存在可持久化容器中
+func (c *combinationContainer) GetFinishedTask(ctx context.Context, status lighttaskscheduler.TaskStatus,
+	before time.Time, limit int32) (tasks []lighttaskscheduler.Task, err error) {
+	lister, ok := c.persistContainer.(lighttaskscheduler.FinishedTaskLister)
+	if !ok {
+		return nil, fmt.Errorf("persistContainer does not implement FinishedTaskLister")
+	}
+	return lister.GetFinishedTask(ctx, status, before, limit)
+}
+
+// RestoreWaitingTask 等待中的任务只在内存容器中排队，放回内存容器，内存容器不支持放回的时候重新添加到队尾
+func (c *combinationContainer) RestoreWaitingTask(ctx context.Context, tasks []lighttaskscheduler.Task) (err error) {
+	if restorer, ok := c.memeoryContainer.(lighttaskscheduler.WaitingTaskRestorer); ok {
+		return restorer.RestoreWaitingTask(ctx, tasks)
+	}
+	for _, task := range tasks {
+		if e := c.memeoryContainer.AddTask(ctx, task); e != nil {
+			err = fmt.Errorf("memeoryContainer AddTask error: %w", e)
+		}
+	}
+	return err
+}
+
+// ListWaitingTask 等待中的任务只在内存容器中排队
+func (c *combinationContainer) ListWaitingTask(ctx context.Context) (tasks []lighttaskscheduler.Task, err error) {
+	lister, ok := c.memeoryContainer.(lighttaskscheduler.WaitingTaskLister)
+	if !ok {
+		return nil, fmt.Errorf("memeoryContainer does not implement WaitingTaskLister")
+	}
+	return lister.ListWaitingTask(ctx)
+}
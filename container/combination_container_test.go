@@ -0,0 +1,105 @@
+package container
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+	"github.com/memory-overflow/light-task-scheduler/containertest"
+)
+
+// mapContainer 测试用的可持久化容器，只在 map 中记录任务最新的状态
+type mapContainer struct {
+	lock  sync.Mutex
+	tasks map[string]lighttaskscheduler.Task
+}
+
+func makeMapContainer() *mapContainer {
+	return &mapContainer{tasks: map[string]lighttaskscheduler.Task{}}
+}
+
+func (m *mapContainer) store(task *lighttaskscheduler.Task, status lighttaskscheduler.TaskStatus) (
+	*lighttaskscheduler.Task, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	task.TaskStatus = status
+	m.tasks[task.TaskId] = *task
+	return task, nil
+}
+
+func (m *mapContainer) list(status lighttaskscheduler.TaskStatus, limit int) (tasks []lighttaskscheduler.Task) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, task := range m.tasks {
+		if task.TaskStatus == status && (limit <= 0 || len(tasks) < limit) {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
+
+func (m *mapContainer) AddTask(ctx context.Context, task lighttaskscheduler.Task) (err error) {
+	_, err = m.store(&task, lighttaskscheduler.TASK_STATUS_WAITING)
+	return err
+}
+
+func (m *mapContainer) GetRunningTask(ctx context.Context) (tasks []lighttaskscheduler.Task, err error) {
+	return m.list(lighttaskscheduler.TASK_STATUS_RUNNING, 0), nil
+}
+
+func (m *mapContainer) GetRunningTaskCount(ctx context.Context) (count int32, err error) {
+	return int32(len(m.list(lighttaskscheduler.TASK_STATUS_RUNNING, 0))), nil
+}
+
+func (m *mapContainer) GetWaitingTask(ctx context.Context, limit int32) (tasks []lighttaskscheduler.Task, err error) {
+	return m.list(lighttaskscheduler.TASK_STATUS_WAITING, int(limit)), nil
+}
+
+func (m *mapContainer) ToRunningStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	*lighttaskscheduler.Task, error) {
+	return m.store(task, lighttaskscheduler.TASK_STATUS_RUNNING)
+}
+
+func (m *mapContainer) ToStopStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	*lighttaskscheduler.Task, error) {
+	return m.store(task, lighttaskscheduler.TASK_STATUS_STOPED)
+}
+
+func (m *mapContainer) ToDeleteStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	*lighttaskscheduler.Task, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.tasks, task.TaskId)
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_DELETE
+	return task, nil
+}
+
+func (m *mapContainer) ToFailedStatus(ctx context.Context, task *lighttaskscheduler.Task, reason error) (
+	*lighttaskscheduler.Task, error) {
+	task.FailedReason = reason
+	return m.store(task, lighttaskscheduler.TASK_STATUS_FAILED)
+}
+
+func (m *mapContainer) ToExportStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	*lighttaskscheduler.Task, error) {
+	return m.store(task, lighttaskscheduler.TASK_STATUS_EXPORTING)
+}
+
+func (m *mapContainer) ToSuccessStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	*lighttaskscheduler.Task, error) {
+	return m.store(task, lighttaskscheduler.TASK_STATUS_SUCCESS)
+}
+
+func (m *mapContainer) UpdateRunningTaskStatus(ctx context.Context, task *lighttaskscheduler.Task,
+	status lighttaskscheduler.AsyncTaskStatus) error {
+	return nil
+}
+
+func TestCombinationContainer(t *testing.T) {
+	containertest.Run(t, func(t *testing.T) lighttaskscheduler.TaskContainer {
+		return MakeCombinationContainer(memeorycontainer.MakeQueueContainer(16, 10*time.Millisecond), makeMapContainer())
+	})
+}
@@ -0,0 +1,67 @@
+package memeorycontainer_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+)
+
+// benchContainer 测量任务容器一个任务完整生命周期的吞吐：添加、取出、运行、导出、成功
+func benchContainer(b *testing.B, container lighttaskscheduler.TaskContainer) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := container.AddTask(ctx, lighttaskscheduler.Task{TaskId: strconv.Itoa(i)}); err != nil {
+			b.Fatal(err)
+		}
+		tasks, err := container.GetWaitingTask(ctx, 1)
+		if err != nil || len(tasks) != 1 {
+			b.Fatalf("GetWaitingTask got %d tasks, err: %v", len(tasks), err)
+		}
+		task, err := container.ToRunningStatus(ctx, &tasks[0])
+		if err != nil {
+			b.Fatal(err)
+		}
+		if task, err = container.ToExportStatus(ctx, task); err != nil {
+			b.Fatal(err)
+		}
+		if _, err = container.ToSuccessStatus(ctx, task); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchAddTask 测量任务容器添加任务的吞吐
+func benchAddTask(b *testing.B, container lighttaskscheduler.TaskContainer) {
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := container.AddTask(ctx, lighttaskscheduler.Task{TaskId: strconv.Itoa(i)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkQueueContainer(b *testing.B) {
+	b.Run("AddTask", func(b *testing.B) {
+		benchAddTask(b, memeorycontainer.MakeQueueContainer(uint32(b.N), time.Hour))
+	})
+	b.Run("Lifecycle", func(b *testing.B) {
+		benchContainer(b, memeorycontainer.MakeQueueContainer(16, time.Hour))
+	})
+}
+
+func BenchmarkOrderedMapContainer(b *testing.B) {
+	b.Run("AddTask", func(b *testing.B) {
+		benchAddTask(b, memeorycontainer.MakeOrderedMapContainer(time.Hour))
+	})
+	b.Run("Lifecycle", func(b *testing.B) {
+		benchContainer(b, memeorycontainer.MakeOrderedMapContainer(time.Hour))
+	})
+}
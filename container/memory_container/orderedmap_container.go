@@ -2,91 +2,369 @@ package memeorycontainer
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
 
+	stlextension "github.com/memory-overflow/go-orderedmap"
 	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
 )
 
+// priorityKey 等待中的任务在 OrderedMap 中的 key，TaskPriority 大的排在前面，优先级相同的按照加入的顺序
+type priorityKey struct {
+	priority int
+	seq      int64
+}
+
+// Less 是否排在 v 的前面
+func (k priorityKey) Less(v stlextension.Key) (bool, error) {
+	o, ok := v.(priorityKey)
+	if !ok {
+		return false, fmt.Errorf("can not compare between priorityKey and %T", v)
+	}
+	if k.priority != o.priority {
+		return k.priority > o.priority, nil
+	}
+	return k.seq < o.seq, nil
+}
+
 // orderedMapContainer OrderedMap 作为容器，支持任务优先级，多进程数据无法共享数据
 type orderedMapContainer struct {
 	MemeoryContainer
-	// TODO
+
+	lock         sync.Mutex
+	waitingMap   stlextension.OrderedMap                    // 等待中的任务，priorityKey -> lighttaskscheduler.Task
+	waitingKeys  map[string]priorityKey                     // 等待中任务的 key，taskId -> priorityKey
+	takenTasks   map[string]bool                            // 已经取出还没有转移到运行中的任务，用于判断是否可以放回等待队列
+	exporting    map[string]bool                            // 导出中的任务
+	runningTasks map[string]lighttaskscheduler.Task         // 运行中的任务，taskId -> lighttaskscheduler.Task
+	progressMap  map[string]lighttaskscheduler.TaskProgress // 运行中的任务进度，taskId -> lighttaskscheduler.TaskProgress
+	headSeq      int64                                      // 放回等待队列的任务使用递减的负数序号
+	tailSeq      int64                                      // 添加的任务使用递增的正数序号
+
+	waitingNotify chan struct{} // 有新的等待任务的通知，唤醒阻塞的 GetWaitingTask
+	timeout       time.Duration
+	clock         lighttaskscheduler.Clock
+}
+
+// MakeOrderedMapContainer 构造优先级任务容器，TaskPriority 大的任务先调度，优先级相同的先进先出，
+// timeout 表示没有等待中的任务的时候 GetWaitingTask 等待新任务的超时时间
+func MakeOrderedMapContainer(timeout time.Duration) *orderedMapContainer {
+	return &orderedMapContainer{
+		waitingKeys:   map[string]priorityKey{},
+		takenTasks:    map[string]bool{},
+		exporting:     map[string]bool{},
+		runningTasks:  map[string]lighttaskscheduler.Task{},
+		progressMap:   map[string]lighttaskscheduler.TaskProgress{},
+		waitingNotify: make(chan struct{}, 1),
+		timeout:       timeout,
+		clock:         lighttaskscheduler.RealClock,
+	}
 }
 
-// MakeOrderedMapContainer 构造队列型任务容器
-func MakeOrderedMapContainer() *orderedMapContainer {
-	// TODO
-	return &orderedMapContainer{}
+// SetClock 设置容器使用的时钟，需要在使用容器之前设置，测试的时候可以使用 fakeclock 手动推进时间
+func (o *orderedMapContainer) SetClock(clock lighttaskscheduler.Clock) {
+	o.clock = lighttaskscheduler.ClockOrReal(clock)
+}
+
+// notifyWaiting 通知有新的等待任务
+func (o *orderedMapContainer) notifyWaiting() {
+	select {
+	case o.waitingNotify <- struct{}{}:
+	default:
+	}
+}
+
+// insertWaiting 加入等待队列，需要持有锁
+func (o *orderedMapContainer) insertWaiting(task lighttaskscheduler.Task, seq int64) error {
+	key := priorityKey{priority: task.TaskPriority, seq: seq}
+	if err := o.waitingMap.Insert(key, task); err != nil {
+		return fmt.Errorf("insert task %s to OrderedMap error: %v", task.TaskId, err)
+	}
+	o.waitingKeys[task.TaskId] = key
+	return nil
+}
+
+// removeWaiting 从等待队列删除，需要持有锁
+func (o *orderedMapContainer) removeWaiting(taskId string) (removed bool, err error) {
+	key, ok := o.waitingKeys[taskId]
+	if !ok {
+		return false, nil
+	}
+	if err = o.waitingMap.Erase(key); err != nil {
+		return false, fmt.Errorf("erase task %s from OrderedMap error: %v", taskId, err)
+	}
+	delete(o.waitingKeys, taskId)
+	return true, nil
 }
 
 // AddTask 添加任务
 func (o *orderedMapContainer) AddTask(ctx context.Context, task lighttaskscheduler.Task) (err error) {
-	// TODO
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if _, ok := o.waitingKeys[task.TaskId]; ok {
+		return fmt.Errorf("task %s is already waiting: %w", task.TaskId, lighttaskscheduler.ErrTaskExists)
+	}
+	if _, ok := o.runningTasks[task.TaskId]; ok || o.takenTasks[task.TaskId] || o.exporting[task.TaskId] {
+		return fmt.Errorf("task %s is already scheduling, running or exporting: %w",
+			task.TaskId, lighttaskscheduler.ErrTaskExists)
+	}
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_WAITING
+	o.tailSeq++
+	if err = o.insertWaiting(task, o.tailSeq); err != nil {
+		return err
+	}
+	o.notifyWaiting()
+	return nil
+}
+
+// AddRunningTask 添加正在分析中的任务，用于从持久化容器中恢复数据
+func (o *orderedMapContainer) AddRunningTask(ctx context.Context, task lighttaskscheduler.Task) (err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if _, err = o.removeWaiting(task.TaskId); err != nil {
+		return err
+	}
+	delete(o.takenTasks, task.TaskId)
+	if _, ok := o.runningTasks[task.TaskId]; !ok {
+		task.TaskStatus = lighttaskscheduler.TASK_STATUS_RUNNING
+		o.runningTasks[task.TaskId] = task
+	}
 	return nil
 }
 
 // GetRunningTask 获取运行中的任务
 func (o *orderedMapContainer) GetRunningTask(ctx context.Context) (tasks []lighttaskscheduler.Task, err error) {
-	// TODO
-	return tasks, err
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for _, task := range o.runningTasks {
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
 }
 
 // GetRunningTaskCount 获取运行中的任务数
 func (o *orderedMapContainer) GetRunningTaskCount(ctx context.Context) (count int32, err error) {
-	// TODO
-	return 0, nil
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return int32(len(o.runningTasks)), nil
 }
 
-// GetWaitingTask 获取等待中的任务
+// GetWaitingTask 按照优先级获取等待中的任务，没有等待中的任务的时候最多等待 timeout
 func (o *orderedMapContainer) GetWaitingTask(ctx context.Context, limit int32) (tasks []lighttaskscheduler.Task, err error) {
-	// TODO
+	if limit <= 0 {
+		return nil, nil
+	}
+	return waitTasks(ctx, o.waitingNotify, o.clock, o.timeout, func() []lighttaskscheduler.Task {
+		return o.popWaiting(limit)
+	}), nil
+}
+
+// popWaiting 取出优先级最高的最多 limit 个任务
+func (o *orderedMapContainer) popWaiting(limit int32) (tasks []lighttaskscheduler.Task) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for len(tasks) < int(limit) {
+		key, value := o.waitingMap.Begin()
+		if key == nil {
+			break
+		}
+		task := value.(lighttaskscheduler.Task)
+		if _, err := o.removeWaiting(task.TaskId); err != nil {
+			// 不会出现，key 的类型都是 priorityKey
+			break
+		}
+		o.takenTasks[task.TaskId] = true
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// GetWaitingTaskCount 获取等待中的任务数
+func (o *orderedMapContainer) GetWaitingTaskCount(ctx context.Context) (count int32, err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return int32(o.waitingMap.Size()), nil
+}
+
+// ListWaitingTask 按照取出的顺序列出等待中的任务
+func (o *orderedMapContainer) ListWaitingTask(ctx context.Context) (tasks []lighttaskscheduler.Task, err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	for key, value := o.waitingMap.Begin(); key != nil; key, value, err = o.waitingMap.Next(key) {
+		if err != nil {
+			return nil, fmt.Errorf("iterate OrderedMap error: %v", err)
+		}
+		tasks = append(tasks, value.(lighttaskscheduler.Task))
+	}
 	return tasks, nil
 }
 
-// ToRunningStatus 转移到运行中的状态
+// RestoreWaitingTask 把调度策略没有选中的任务放回等待队列，在相同优先级的任务中排在最前面，
+// 取出以后已经被停止、删除或者重新添加的任务不再放回
+func (o *orderedMapContainer) RestoreWaitingTask(ctx context.Context, tasks []lighttaskscheduler.Task) (err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	base := o.headSeq - int64(len(tasks))
+	o.headSeq = base
+	for i, task := range tasks {
+		if !o.takenTasks[task.TaskId] {
+			continue
+		}
+		delete(o.takenTasks, task.TaskId)
+		if _, ok := o.waitingKeys[task.TaskId]; ok {
+			continue
+		}
+		if e := o.insertWaiting(task, base+int64(i)); e != nil {
+			err = e
+		}
+	}
+	o.notifyWaiting()
+	return err
+}
+
+// UpdateWaitingTaskPriority 修改等待中任务的优先级，调整任务在等待队列中的位置
+func (o *orderedMapContainer) UpdateWaitingTaskPriority(ctx context.Context, taskId string, priority int) (err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	key, ok := o.waitingKeys[taskId]
+	if !ok {
+		return fmt.Errorf("task %s is not waiting", taskId)
+	}
+	value, _, err := o.waitingMap.Find(key)
+	if err != nil {
+		return fmt.Errorf("find task %s in OrderedMap error: %v", taskId, err)
+	}
+	if _, err = o.removeWaiting(taskId); err != nil {
+		return err
+	}
+	task := value.(lighttaskscheduler.Task)
+	task.TaskPriority = priority
+	return o.insertWaiting(task, key.seq)
+}
+
+// finish 任务离开运行中的状态，删除运行中的任务和进度，需要持有锁
+func (o *orderedMapContainer) finish(taskId string) {
+	delete(o.runningTasks, taskId)
+	delete(o.progressMap, taskId)
+	delete(o.takenTasks, taskId)
+	delete(o.exporting, taskId)
+}
+
+// ToRunningStatus 转移到运行中的状态，只有等待中、已经取出和运行中重试的任务可以转移，
+// 取出以后被停止、删除的任务返回 ErrTaskVersionConflict
 func (o *orderedMapContainer) ToRunningStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
-	// TODO
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	_, waiting := o.waitingKeys[task.TaskId]
+	_, running := o.runningTasks[task.TaskId]
+	if !waiting && !running && !o.takenTasks[task.TaskId] {
+		return task, fmt.Errorf("task %s is not waiting, claimed or running: %w",
+			task.TaskId, lighttaskscheduler.ErrTaskVersionConflict)
+	}
+	if _, err = o.removeWaiting(task.TaskId); err != nil {
+		return task, err
+	}
+	delete(o.takenTasks, task.TaskId)
+	task.TaskStartTime = o.clock.Now()
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_RUNNING
+	if running, ok := o.runningTasks[task.TaskId]; ok {
+		// 重试的任务，更新重试次数
+		running.TaskAttemptsTime = task.TaskAttemptsTime
+		o.runningTasks[task.TaskId] = running
+	} else {
+		o.runningTasks[task.TaskId] = *task
+	}
 	return task, nil
 }
 
 // ToExportStatus 转移到停止状态
 func (o *orderedMapContainer) ToStopStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
-	// TODO
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	// 任务在等待队列中的时候直接删除
+	if _, err = o.removeWaiting(task.TaskId); err != nil {
+		return task, err
+	}
+	o.finish(task.TaskId)
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_STOPED
 	return task, nil
 }
 
 // ToExportStatus 转移到删除状态
 func (o *orderedMapContainer) ToDeleteStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
-	// TODO
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if _, err = o.removeWaiting(task.TaskId); err != nil {
+		return task, err
+	}
+	o.finish(task.TaskId)
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_DELETE
 	return task, nil
 }
 
 // ToFailedStatus 转移到失败状态
 func (o *orderedMapContainer) ToFailedStatus(ctx context.Context, task *lighttaskscheduler.Task, reason error) (
 	newTask *lighttaskscheduler.Task, err error) {
-	// TODO
-	return
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	// 等待中的任务也可以直接失败
+	if _, err = o.removeWaiting(task.TaskId); err != nil {
+		return task, err
+	}
+	o.finish(task.TaskId)
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_FAILED
+	task.FailedReason = reason
+	return task, nil
 }
 
 // ToExportStatus 转移到数据导出状态
 func (o *orderedMapContainer) ToExportStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
-	// TODO
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	// 删除执行中的任务，增加一个任务调度空位，导出结束之前不能重复添加
+	o.finish(task.TaskId)
+	o.exporting[task.TaskId] = true
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_EXPORTING
 	return task, nil
 }
 
 // ToSuccessStatus 转移到执行成功状态
 func (o *orderedMapContainer) ToSuccessStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
-	// TODO
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.finish(task.TaskId)
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_SUCCESS
 	return task, nil
 }
 
 // UpdateRunningTaskStatus 更新执行中的任务状态
 func (o *orderedMapContainer) UpdateRunningTaskStatus(ctx context.Context,
 	task *lighttaskscheduler.Task, status lighttaskscheduler.AsyncTaskStatus) error {
-	// TODO
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if _, ok := o.runningTasks[task.TaskId]; ok {
+		o.progressMap[task.TaskId] = status.GetProgress()
+	}
 	return nil
 }
+
+// GetTaskProgress 查询运行中的任务进度
+func (o *orderedMapContainer) GetTaskProgress(ctx context.Context, task *lighttaskscheduler.Task) (
+	progress lighttaskscheduler.TaskProgress, err error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if progress, ok := o.progressMap[task.TaskId]; ok {
+		return progress, nil
+	}
+	if _, ok := o.runningTasks[task.TaskId]; ok {
+		// 运行中还没有上报进度
+		return progress, nil
+	}
+	return progress, fmt.Errorf("task %s is not running", task.TaskId)
+}
@@ -0,0 +1,78 @@
+package memeorycontainer_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+	"github.com/memory-overflow/light-task-scheduler/containertest"
+)
+
+func TestOrderedMapContainer(t *testing.T) {
+	containertest.Run(t, func(t *testing.T) lighttaskscheduler.TaskContainer {
+		return memeorycontainer.MakeOrderedMapContainer(10 * time.Millisecond)
+	})
+}
+
+// TestOrderedMapContainerDuplicateAdd 已经取出、运行中和导出中的任务都不能重复添加
+func TestOrderedMapContainerDuplicateAdd(t *testing.T) {
+	ctx := context.Background()
+	c := memeorycontainer.MakeOrderedMapContainer(time.Millisecond)
+	add := func() error { return c.AddTask(ctx, lighttaskscheduler.Task{TaskId: "task"}) }
+	if err := add(); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	if err := add(); !lighttaskscheduler.IsTaskExists(err) {
+		t.Fatalf("waiting task is added again, err: %v", err)
+	}
+	tasks, err := c.GetWaitingTask(ctx, 1)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("GetWaitingTask got %d tasks, err: %v", len(tasks), err)
+	}
+	if err = add(); !lighttaskscheduler.IsTaskExists(err) {
+		t.Fatalf("claimed task is added again, err: %v", err)
+	}
+	task, err := c.ToRunningStatus(ctx, &tasks[0])
+	if err != nil {
+		t.Fatalf("ToRunningStatus error: %v", err)
+	}
+	if err = add(); !lighttaskscheduler.IsTaskExists(err) {
+		t.Fatalf("running task is added again, err: %v", err)
+	}
+	if task, err = c.ToExportStatus(ctx, task); err != nil {
+		t.Fatalf("ToExportStatus error: %v", err)
+	}
+	if err = add(); !lighttaskscheduler.IsTaskExists(err) {
+		t.Fatalf("exporting task is added again, err: %v", err)
+	}
+	if _, err = c.ToSuccessStatus(ctx, task); err != nil {
+		t.Fatalf("ToSuccessStatus error: %v", err)
+	}
+	if err = add(); err != nil {
+		t.Fatalf("finished task can not be added again: %v", err)
+	}
+}
+
+// TestOrderedMapContainerRunStopped 取出以后被停止的任务不能再转移到运行中
+func TestOrderedMapContainerRunStopped(t *testing.T) {
+	ctx := context.Background()
+	c := memeorycontainer.MakeOrderedMapContainer(time.Millisecond)
+	if err := c.AddTask(ctx, lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	tasks, err := c.GetWaitingTask(ctx, 1)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("GetWaitingTask got %d tasks, err: %v", len(tasks), err)
+	}
+	if _, err = c.ToStopStatus(ctx, &lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+		t.Fatalf("ToStopStatus error: %v", err)
+	}
+	if _, err = c.ToRunningStatus(ctx, &tasks[0]); !lighttaskscheduler.IsVersionConflict(err) {
+		t.Fatalf("ToRunningStatus of stopped task want version conflict, got %v", err)
+	}
+	if count, _ := c.GetRunningTaskCount(ctx); count != 0 {
+		t.Fatalf("running count want 0, got %d", count)
+	}
+}
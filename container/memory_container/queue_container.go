@@ -10,43 +10,121 @@ import (
 	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
 )
 
-// queueContainer 队列型容器，任务无状态，无优先级，先进先出，任务数据，多进程数据无法共享数据
+// queueContainer 队列型容器，任务无状态，默认无优先级，先进先出，任务数据，多进程数据无法共享数据
 type queueContainer struct {
 	MemeoryContainer
 
-	stopedTaskMap    sync.Map // 需要停止的任务 map，用来标记等待中的哪些任务被停职了，taskId -> lighttaskscheduler.Task
 	runningTaskMap   sync.Map // 运行中的任务的 map， taskId -> lighttaskscheduler.Task
 	runningTaskCount int32    // 运行中的任务总数
+	checkpointMap    sync.Map // 任务检查点，taskId -> []byte
+	progressMap      sync.Map // 运行中的任务进度，taskId -> lighttaskscheduler.TaskProgress
 
-	waitingTasks chan lighttaskscheduler.Task
-	timeout      time.Duration
+	versionLock  sync.Mutex
+	versionMap   map[string]int64 // 任务版本，taskId -> version，用于乐观锁，结束的任务保留版本作为标记
+	tombstones   []tombstone      // 结束的任务保留的版本，按照过期时间排序，过期以后从 versionMap 中删除
+	tombstoneTTL time.Duration
+
+	outboxLock sync.Mutex
+	outbox     []*finishedEntry // 已完成任务的发件箱，按照完成时间排序
+	outboxSeq  int64
+
+	waitingLock   sync.Mutex
+	waiting       *waitingQueue       // 等待中的任务，停止和删除的时候直接从队列中删除
+	claimed       map[string]struct{} // 已经从等待队列取出还没有转移到运行中的任务，以及导出中的任务
+	waitingNotify chan struct{}       // 有新的等待任务的通知，唤醒阻塞的 GetWaitingTask
+	timeout       time.Duration
+	clock         lighttaskscheduler.Clock
+}
+
+// tombstone 结束的任务在 versionMap 中保留的版本标记
+type tombstone struct {
+	taskId   string
+	version  int64
+	expireAt time.Time
+}
+
+// defaultTombstoneTTL 结束的任务版本标记默认的保留时间
+const defaultTombstoneTTL = 10 * time.Minute
+
+// finishedEntry 发件箱中的完成记录
+type finishedEntry struct {
+	record         lighttaskscheduler.FinishedRecord
+	invisibleUntil time.Time // 投递以后在该时间之前不会再次投递
 }
 
-// MakeQueueContainer 构造队列型任务容器, size 表示队列的大小, timeout 表示队列读取的超时时间
+// MakeQueueContainer 构造队列型任务容器, size 表示队列的初始容量，队列满了以后自动扩容,
+// timeout 表示队列为空的时候 GetWaitingTask 等待新任务的超时时间
 func MakeQueueContainer(size uint32, timeout time.Duration) *queueContainer {
 	return &queueContainer{
-		waitingTasks: make(chan lighttaskscheduler.Task, size),
-		timeout:      timeout,
+		waiting:       makeWaitingQueue(int(size)),
+		claimed:       map[string]struct{}{},
+		waitingNotify: make(chan struct{}, 1),
+		timeout:       timeout,
+		versionMap:    map[string]int64{},
+		tombstoneTTL:  defaultTombstoneTTL,
+		clock:         lighttaskscheduler.RealClock,
+	}
+}
+
+// SetTombstoneTTL 设置结束的任务版本标记的保留时间，默认 10 分钟，需要在使用容器之前设置，
+// 保留时间内结束之前的过期状态转移仍然版本冲突，需要大于调度器取出任务到完成状态转移的最长时间
+func (q *queueContainer) SetTombstoneTTL(ttl time.Duration) {
+	q.tombstoneTTL = ttl
+}
+
+// SetClock 设置容器使用的时钟，需要在使用容器之前设置，测试的时候可以使用 fakeclock 手动推进时间
+func (q *queueContainer) SetClock(clock lighttaskscheduler.Clock) {
+	q.clock = lighttaskscheduler.ClockOrReal(clock)
+}
+
+// SetPriorityOrder 设置等待队列是否按照优先级排序，开启以后 TaskPriority 大的任务先取出，优先级相同的先进先出
+func (q *queueContainer) SetPriorityOrder(priority bool) {
+	q.waitingLock.Lock()
+	defer q.waitingLock.Unlock()
+	q.waiting.setPriorityOrder(priority)
+}
+
+// notifyWaiting 通知有新的等待任务
+func (q *queueContainer) notifyWaiting() {
+	select {
+	case q.waitingNotify <- struct{}{}:
+	default:
 	}
 }
 
 // AddTask 添加任务
 func (q *queueContainer) AddTask(ctx context.Context, task lighttaskscheduler.Task) (err error) {
-	// 如果是之前已经暂停，那么直接删除暂停状态
-	if _, ok := q.stopedTaskMap.LoadAndDelete(task.TaskId); ok {
-		return
-	}
 	task.TaskStatus = lighttaskscheduler.TASK_STATUS_WAITING
-	select {
-	case q.waitingTasks <- task:
-		return
-	case <-time.After(q.timeout):
-		return fmt.Errorf("add task timeout")
+	q.waitingLock.Lock()
+	defer q.waitingLock.Unlock()
+	if _, ok := q.waiting.get(task.TaskId); ok {
+		return fmt.Errorf("task %s is already waiting: %w", task.TaskId, lighttaskscheduler.ErrTaskExists)
 	}
+	if _, ok := q.claimed[task.TaskId]; ok {
+		return fmt.Errorf("task %s is already scheduling or exporting: %w", task.TaskId, lighttaskscheduler.ErrTaskExists)
+	}
+	if _, ok := q.runningTaskMap.Load(task.TaskId); ok {
+		return fmt.Errorf("task %s is already running: %w", task.TaskId, lighttaskscheduler.ErrTaskExists)
+	}
+	q.versionLock.Lock()
+	q.pruneTombstones()
+	if v, ok := q.versionMap[task.TaskId]; ok && v > task.TaskVersion {
+		// 重新添加的任务，版本从容器中的版本继续增加
+		task.TaskVersion = v
+	}
+	task.TaskVersion++
+	q.versionMap[task.TaskId] = task.TaskVersion
+	q.versionLock.Unlock()
+	q.waiting.pushBack(task)
+	q.notifyWaiting()
+	return nil
 }
 
 // AddRunningTask 添加正在分析中的任务，用于从持久化容器中恢复数据
 func (q *queueContainer) AddRunningTask(ctx context.Context, task lighttaskscheduler.Task) (err error) {
+	q.versionLock.Lock()
+	q.versionMap[task.TaskId] = task.TaskVersion
+	q.versionLock.Unlock()
 	// 如果任务没有在执行列表中，加入执行列表
 	if _, ok := q.runningTaskMap.LoadOrStore(task.TaskId, task); !ok {
 		atomic.AddInt32(&q.runningTaskCount, 1)
@@ -70,29 +148,171 @@ func (q *queueContainer) GetRunningTask(ctx context.Context) (tasks []lighttasks
 
 // GetRunningTaskCount 获取运行中的任务数
 func (q *queueContainer) GetRunningTaskCount(ctx context.Context) (count int32, err error) {
-	return q.runningTaskCount, nil
+	return atomic.LoadInt32(&q.runningTaskCount), nil
 }
 
-// GetWaitingTask 获取等待中的任务
+// GetWaitingTask 获取等待中的任务，队列为空的时候最多等待 timeout
 func (q *queueContainer) GetWaitingTask(ctx context.Context, limit int32) (tasks []lighttaskscheduler.Task, err error) {
-	for i := 0; i < int(limit); i++ {
-		select {
-		case task := <-q.waitingTasks:
-			// 暂停的任务直接忽略
-			if _, ok := q.stopedTaskMap.LoadAndDelete(task.TaskId); !ok {
-				tasks = append(tasks, task)
-			}
-		case <-time.After(q.timeout):
-			return tasks, nil
+	if limit <= 0 {
+		return nil, nil
+	}
+	return waitTasks(ctx, q.waitingNotify, q.clock, q.timeout, func() []lighttaskscheduler.Task {
+		return q.popWaiting(limit)
+	}), nil
+}
+
+// popWaiting 从队列头部取出最多 limit 个任务
+func (q *queueContainer) popWaiting(limit int32) (tasks []lighttaskscheduler.Task) {
+	q.waitingLock.Lock()
+	defer q.waitingLock.Unlock()
+	for len(tasks) < int(limit) {
+		task, ok := q.waiting.pop()
+		if !ok {
+			break
 		}
+		q.claimed[task.TaskId] = struct{}{}
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// GetWaitingTaskCount 获取等待中的任务数
+func (q *queueContainer) GetWaitingTaskCount(ctx context.Context) (count int32, err error) {
+	q.waitingLock.Lock()
+	defer q.waitingLock.Unlock()
+	return int32(q.waiting.Len()), nil
+}
+
+// GetWaitingTaskById 按照任务 id 查询等待中的任务，任务不在等待队列中的时候 ok 为 false
+func (q *queueContainer) GetWaitingTaskById(ctx context.Context, taskId string) (
+	task lighttaskscheduler.Task, ok bool) {
+	q.waitingLock.Lock()
+	defer q.waitingLock.Unlock()
+	return q.waiting.get(taskId)
+}
+
+// GetTask 查询运行中或者等待中的任务，已经结束的任务不在队列容器中
+func (q *queueContainer) GetTask(ctx context.Context, taskId string) (
+	task lighttaskscheduler.Task, ok bool, err error) {
+	if v, ok := q.runningTaskMap.Load(taskId); ok {
+		return v.(lighttaskscheduler.Task), true, nil
+	}
+	task, ok = q.GetWaitingTaskById(ctx, taskId)
+	return task, ok, nil
+}
+
+// ListWaitingTask 按照取出的顺序列出等待中的任务
+func (q *queueContainer) ListWaitingTask(ctx context.Context) (tasks []lighttaskscheduler.Task, err error) {
+	q.waitingLock.Lock()
+	defer q.waitingLock.Unlock()
+	return q.waiting.tasks(), nil
+}
+
+// UpdateWaitingTaskPriority 修改等待中任务的优先级，开启 SetPriorityOrder 以后会调整任务在队列中的位置
+func (q *queueContainer) UpdateWaitingTaskPriority(ctx context.Context, taskId string, priority int) (err error) {
+	q.waitingLock.Lock()
+	defer q.waitingLock.Unlock()
+	if !q.waiting.setPriority(taskId, priority) {
+		return fmt.Errorf("task %s is not waiting", taskId)
+	}
+	return nil
+}
+
+// RestoreWaitingTask 把调度策略没有选中的任务放回等待队列的头部，下次 GetWaitingTask 优先取出，
+// 取出以后已经被停止、删除或者重新添加的任务不再放回
+func (q *queueContainer) RestoreWaitingTask(ctx context.Context, tasks []lighttaskscheduler.Task) (err error) {
+	q.waitingLock.Lock()
+	defer q.waitingLock.Unlock()
+	restored := make([]lighttaskscheduler.Task, 0, len(tasks))
+	q.versionLock.Lock()
+	for _, task := range tasks {
+		if v, ok := q.versionMap[task.TaskId]; ok && v == task.TaskVersion {
+			restored = append(restored, task)
+			delete(q.claimed, task.TaskId)
+		}
+	}
+	q.versionLock.Unlock()
+	q.waiting.pushFront(restored)
+	q.notifyWaiting()
+	return nil
+}
+
+// removeWaiting 从等待队列中删除任务
+func (q *queueContainer) removeWaiting(taskId string) {
+	q.waitingLock.Lock()
+	defer q.waitingLock.Unlock()
+	q.waiting.remove(taskId)
+}
+
+// claim 标记任务已经取出，需要在任务进入运行中或者结束之前调用，防止任务被重复添加
+func (q *queueContainer) claim(taskId string) {
+	q.waitingLock.Lock()
+	defer q.waitingLock.Unlock()
+	q.claimed[taskId] = struct{}{}
+}
+
+// unclaim 任务已经进入运行中或者结束，删除取出的标记
+func (q *queueContainer) unclaim(taskId string) {
+	q.waitingLock.Lock()
+	defer q.waitingLock.Unlock()
+	delete(q.claimed, taskId)
+}
+
+// SupportTaskVersion 队列容器支持任务版本的乐观锁
+func (q *queueContainer) SupportTaskVersion() bool {
+	return true
+}
+
+// casVersion 比较任务版本，和容器中的版本一致的时候版本加一，不一致返回 ErrTaskVersionConflict
+// 版本为 0 的任务，比如用户直接构造用来停止任务的 Task，不比较版本
+func (q *queueContainer) casVersion(task *lighttaskscheduler.Task) error {
+	q.versionLock.Lock()
+	defer q.versionLock.Unlock()
+	v, ok := q.versionMap[task.TaskId]
+	if ok && task.TaskVersion != 0 && v != task.TaskVersion {
+		return fmt.Errorf("task %s version %d, current version %d: %w",
+			task.TaskId, task.TaskVersion, v, lighttaskscheduler.ErrTaskVersionConflict)
+	}
+	task.TaskVersion = v + 1
+	q.versionMap[task.TaskId] = task.TaskVersion
+	return nil
+}
+
+// bury 任务结束以后保留版本作为标记，保留时间过期以后删除，避免 versionMap 无限增长
+func (q *queueContainer) bury(task *lighttaskscheduler.Task) {
+	q.versionLock.Lock()
+	defer q.versionLock.Unlock()
+	q.tombstones = append(q.tombstones, tombstone{
+		taskId:   task.TaskId,
+		version:  task.TaskVersion,
+		expireAt: q.clock.Now().Add(q.tombstoneTTL),
+	})
+	q.pruneTombstones()
+}
+
+// pruneTombstones 删除过期的版本标记，任务重新添加以后版本已经变化的不删除，需要持有 versionLock
+func (q *queueContainer) pruneTombstones() {
+	now := q.clock.Now()
+	expired := 0
+	for expired < len(q.tombstones) && !now.Before(q.tombstones[expired].expireAt) {
+		t := q.tombstones[expired]
+		if v, ok := q.versionMap[t.taskId]; ok && v == t.version {
+			delete(q.versionMap, t.taskId)
+		}
+		expired++
+	}
+	if expired > 0 {
+		q.tombstones = append(q.tombstones[:0], q.tombstones[expired:]...)
 	}
-	return tasks, nil
 }
 
 // ToRunningStatus 转移到运行中的状态
 func (q *queueContainer) ToRunningStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
-	task.TaskStartTime = time.Now()
+	if err = q.casVersion(task); err != nil {
+		return task, err
+	}
+	task.TaskStartTime = q.clock.Now()
 	task.TaskStatus = lighttaskscheduler.TASK_STATUS_RUNNING
 	t, ok := q.runningTaskMap.LoadOrStore(task.TaskId, *task)
 	if !ok {
@@ -100,57 +320,88 @@ func (q *queueContainer) ToRunningStatus(ctx context.Context, task *lighttasksch
 	} else {
 		nt := t.(lighttaskscheduler.Task)
 		nt.TaskAttemptsTime = task.TaskAttemptsTime
+		nt.TaskVersion = task.TaskVersion
 		q.runningTaskMap.Store(task.TaskId, nt)
 	}
+	q.unclaim(task.TaskId)
 	return task, nil
 }
 
 // ToExportStatus 转移到停止状态
 func (q *queueContainer) ToStopStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
+	if err = q.casVersion(task); err != nil {
+		return task, err
+	}
+	q.progressMap.Delete(task.TaskId)
 	// 如果任务已经在执行中，删除执行中的任务
 	if _, ok := q.runningTaskMap.LoadAndDelete(task.TaskId); ok {
 		atomic.AddInt32(&q.runningTaskCount, -1)
 	} else {
-		// 任务在等待队列中，任务加入到停止列表，待调度到的时候 pass 掉
-		q.stopedTaskMap.Store(task.TaskId, struct{}{})
+		// 任务在等待队列中，直接从等待队列中删除
+		q.removeWaiting(task.TaskId)
 	}
 	task.TaskStatus = lighttaskscheduler.TASK_STATUS_STOPED
+	q.unclaim(task.TaskId)
+	q.bury(task)
 	return task, nil
 }
 
 // ToExportStatus 转移到删除状态
 func (q *queueContainer) ToDeleteStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
+	if err = q.casVersion(task); err != nil {
+		return task, err
+	}
+	q.progressMap.Delete(task.TaskId)
 	// 如果任务已经在执行中，删除执行中的任务
 	if _, ok := q.runningTaskMap.LoadAndDelete(task.TaskId); ok {
 		atomic.AddInt32(&q.runningTaskCount, -1)
 	} else {
-		// 任务在等待队列中，任务加入到暂停列表，待调度到的时候 pass 掉
-		q.stopedTaskMap.Store(task.TaskId, struct{}{})
+		// 任务在等待队列中，直接从等待队列中删除
+		q.removeWaiting(task.TaskId)
 	}
+	// 保留版本作为删除的标记，保留时间内删除之前的过期状态转移仍然版本冲突，重新添加的任务从该版本继续增加，
+	// 同时清理已经过期的标记
+	q.checkpointMap.Delete(task.TaskId)
 	task.TaskStatus = lighttaskscheduler.TASK_STATUS_DELETE
+	q.unclaim(task.TaskId)
+	q.bury(task)
 	return task, nil
 }
 
 // ToFailedStatus 转移到失败状态
 func (q *queueContainer) ToFailedStatus(ctx context.Context, task *lighttaskscheduler.Task, reason error) (
 	newTask *lighttaskscheduler.Task, err error) {
+	if err = q.casVersion(task); err != nil {
+		return task, err
+	}
 	if _, ok := q.runningTaskMap.LoadAndDelete(task.TaskId); ok {
 		atomic.AddInt32(&q.runningTaskCount, -1)
+	} else {
+		// 等待中的任务也可以直接失败，从等待队列中删除
+		q.removeWaiting(task.TaskId)
 	}
+	q.progressMap.Delete(task.TaskId)
 	task.TaskStatus = lighttaskscheduler.TASK_STATUS_FAILED
 	task.FailedReason = reason
+	q.unclaim(task.TaskId)
+	q.bury(task)
 	return task, nil
 }
 
 // ToExportStatus 转移到数据导出状态
 func (q *queueContainer) ToExportStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
-	// 删除执行中的任务，增加一个任务调度空位
+	if err = q.casVersion(task); err != nil {
+		return task, err
+	}
+	// 删除执行中的任务，增加一个任务调度空位，导出结束之前不能重复添加
+	q.claim(task.TaskId)
 	if _, ok := q.runningTaskMap.LoadAndDelete(task.TaskId); ok {
 		atomic.AddInt32(&q.runningTaskCount, -1)
 	}
+	q.progressMap.Delete(task.TaskId)
 	task.TaskStatus = lighttaskscheduler.TASK_STATUS_EXPORTING
 	return task, nil
 }
@@ -158,15 +409,115 @@ func (q *queueContainer) ToExportStatus(ctx context.Context, task *lighttasksche
 // ToSuccessStatus 转移到执行成功状态
 func (q *queueContainer) ToSuccessStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
+	if err = q.casVersion(task); err != nil {
+		return task, err
+	}
 	if _, ok := q.runningTaskMap.LoadAndDelete(task.TaskId); ok {
 		atomic.AddInt32(&q.runningTaskCount, -1)
 	}
+	q.progressMap.Delete(task.TaskId)
 	task.TaskStatus = lighttaskscheduler.TASK_STATUS_SUCCESS
+	q.unclaim(task.TaskId)
+	q.bury(task)
 	return task, nil
 }
 
 // UpdateRunningTaskStatus 更新执行中的任务状态
 func (q *queueContainer) UpdateRunningTaskStatus(ctx context.Context,
 	task *lighttaskscheduler.Task, status lighttaskscheduler.AsyncTaskStatus) error {
+	if _, ok := q.runningTaskMap.Load(task.TaskId); ok {
+		q.progressMap.Store(task.TaskId, status.GetProgress())
+	}
+	return nil
+}
+
+// GetTaskProgress 查询运行中的任务进度
+func (q *queueContainer) GetTaskProgress(ctx context.Context, task *lighttaskscheduler.Task) (
+	progress lighttaskscheduler.TaskProgress, err error) {
+	if v, ok := q.progressMap.Load(task.TaskId); ok {
+		return v.(lighttaskscheduler.TaskProgress), nil
+	}
+	if _, ok := q.runningTaskMap.Load(task.TaskId); ok {
+		// 运行中还没有上报进度
+		return progress, nil
+	}
+	return progress, fmt.Errorf("task %s is not running", task.TaskId)
+}
+
+// SaveCheckpoint 保存任务检查点
+func (q *queueContainer) SaveCheckpoint(ctx context.Context, task *lighttaskscheduler.Task,
+	checkpoint []byte) (err error) {
+	q.checkpointMap.Store(task.TaskId, checkpoint)
+	return nil
+}
+
+// GetCheckpoint 获取任务检查点
+func (q *queueContainer) GetCheckpoint(ctx context.Context, task *lighttaskscheduler.Task) (
+	checkpoint []byte, err error) {
+	if v, ok := q.checkpointMap.Load(task.TaskId); ok {
+		return v.([]byte), nil
+	}
+	return nil, nil
+}
+
+// DeleteCheckpoint 删除任务检查点
+func (q *queueContainer) DeleteCheckpoint(ctx context.Context, task *lighttaskscheduler.Task) (err error) {
+	q.checkpointMap.Delete(task.TaskId)
+	return nil
+}
+
+// PushFinished 保存任务完成记录
+func (q *queueContainer) PushFinished(ctx context.Context, task lighttaskscheduler.Task) (err error) {
+	q.outboxLock.Lock()
+	defer q.outboxLock.Unlock()
+	q.outboxSeq++
+	q.outbox = append(q.outbox, &finishedEntry{
+		record: lighttaskscheduler.FinishedRecord{
+			RecordId:     fmt.Sprintf("%s-%d", task.TaskId, q.outboxSeq),
+			Task:         task,
+			FinishedTime: q.clock.Now(),
+		},
+	})
+	return nil
+}
+
+// NextFinished 获取未确认的完成记录
+func (q *queueContainer) NextFinished(ctx context.Context, limit int32, ackTimeout time.Duration) (
+	records []lighttaskscheduler.FinishedRecord, err error) {
+	q.outboxLock.Lock()
+	defer q.outboxLock.Unlock()
+	now := q.clock.Now()
+	for _, entry := range q.outbox {
+		if int32(len(records)) >= limit {
+			break
+		}
+		if entry.invisibleUntil.After(now) {
+			continue
+		}
+		entry.invisibleUntil = now.Add(ackTimeout)
+		entry.record.DeliveryCount++
+		records = append(records, entry.record)
+	}
+	return records, nil
+}
+
+// AckFinished 确认完成记录
+func (q *queueContainer) AckFinished(ctx context.Context, recordIds []string) (err error) {
+	acked := map[string]bool{}
+	for _, id := range recordIds {
+		acked[id] = true
+	}
+	q.outboxLock.Lock()
+	defer q.outboxLock.Unlock()
+	outbox := q.outbox[:0]
+	for _, entry := range q.outbox {
+		if !acked[entry.record.RecordId] {
+			outbox = append(outbox, entry)
+		}
+	}
+	for i := len(outbox); i < len(q.outbox); i++ {
+		q.outbox[i] = nil
+	}
+	q.outbox = outbox
 	return nil
 }
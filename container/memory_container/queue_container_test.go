@@ -0,0 +1,168 @@
+package memeorycontainer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+	"github.com/memory-overflow/light-task-scheduler/containertest"
+	"github.com/memory-overflow/light-task-scheduler/fakeclock"
+)
+
+func TestQueueContainer(t *testing.T) {
+	containertest.Run(t, func(t *testing.T) lighttaskscheduler.TaskContainer {
+		return memeorycontainer.MakeQueueContainer(16, 10*time.Millisecond)
+	})
+}
+
+// TestQueueContainerTombstoneTTL 结束的任务在保留时间内保留版本标记，过期以后删除
+func TestQueueContainerTombstoneTTL(t *testing.T) {
+	ctx := context.Background()
+	clock := fakeclock.MakeFakeClock(time.Now())
+	c := memeorycontainer.MakeQueueContainer(16, time.Millisecond)
+	c.SetClock(clock)
+	c.SetTombstoneTTL(time.Minute)
+
+	// addAndFinish 添加任务并且按照 finish 结束，返回结束之前的任务
+	addAndFinish := func(taskId string, finish func(task *lighttaskscheduler.Task) error) lighttaskscheduler.Task {
+		t.Helper()
+		if err := c.AddTask(ctx, lighttaskscheduler.Task{TaskId: taskId}); err != nil {
+			t.Fatalf("AddTask error: %v", err)
+		}
+		tasks, err := c.GetWaitingTask(ctx, 1)
+		if err != nil || len(tasks) != 1 {
+			t.Fatalf("GetWaitingTask got %d tasks, err: %v", len(tasks), err)
+		}
+		stale := tasks[0]
+		task := stale
+		if err := finish(&task); err != nil {
+			t.Fatalf("finish task %s error: %v", taskId, err)
+		}
+		return stale
+	}
+	waitingVersion := func(taskId string) int64 {
+		t.Helper()
+		if err := c.AddTask(ctx, lighttaskscheduler.Task{TaskId: taskId}); err != nil {
+			t.Fatalf("AddTask error: %v", err)
+		}
+		task, ok := c.GetWaitingTaskById(ctx, taskId)
+		if !ok {
+			t.Fatalf("task %s is not waiting", taskId)
+		}
+		if _, err := c.ToDeleteStatus(ctx, &lighttaskscheduler.Task{TaskId: taskId}); err != nil {
+			t.Fatalf("ToDeleteStatus error: %v", err)
+		}
+		return task.TaskVersion
+	}
+
+	for _, tc := range []struct {
+		name   string
+		finish func(task *lighttaskscheduler.Task) error
+	}{
+		{"stop", func(task *lighttaskscheduler.Task) error {
+			_, err := c.ToStopStatus(ctx, task)
+			return err
+		}},
+		{"delete", func(task *lighttaskscheduler.Task) error {
+			_, err := c.ToDeleteStatus(ctx, task)
+			return err
+		}},
+		{"failed", func(task *lighttaskscheduler.Task) error {
+			_, err := c.ToFailedStatus(ctx, task, errors.New("failed"))
+			return err
+		}},
+		{"success", func(task *lighttaskscheduler.Task) error {
+			if _, err := c.ToRunningStatus(ctx, task); err != nil {
+				return err
+			}
+			_, err := c.ToSuccessStatus(ctx, task)
+			return err
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stale := addAndFinish(tc.name, tc.finish)
+			// 保留时间内过期的状态转移版本冲突，重新添加的任务版本继续增加
+			clock.Advance(time.Minute / 2)
+			if _, err := c.ToRunningStatus(ctx, &stale); !lighttaskscheduler.IsVersionConflict(err) {
+				t.Fatalf("stale ToRunningStatus want version conflict, got %v", err)
+			}
+			if v := waitingVersion(tc.name); v <= stale.TaskVersion {
+				t.Fatalf("re-added task version %d is not greater than %d", v, stale.TaskVersion)
+			}
+			// 过期以后版本标记被删除，重新添加的任务版本从 1 开始
+			clock.Advance(time.Minute)
+			if _, err := c.ToDeleteStatus(ctx, &lighttaskscheduler.Task{TaskId: "other"}); err != nil {
+				t.Fatalf("ToDeleteStatus error: %v", err)
+			}
+			if v := waitingVersion(tc.name); v != 1 {
+				t.Fatalf("re-added task version after tombstone expired want 1, got %d", v)
+			}
+			clock.Advance(2 * time.Minute)
+		})
+	}
+}
+
+// TestQueueContainerDuplicateAdd 等待中、已经取出、运行中和导出中的任务都不能重复添加
+func TestQueueContainerDuplicateAdd(t *testing.T) {
+	ctx := context.Background()
+	c := memeorycontainer.MakeQueueContainer(16, time.Millisecond)
+	add := func() error { return c.AddTask(ctx, lighttaskscheduler.Task{TaskId: "task"}) }
+	if err := add(); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	if err := add(); !lighttaskscheduler.IsTaskExists(err) {
+		t.Fatalf("waiting task is added again, err: %v", err)
+	}
+	tasks, err := c.GetWaitingTask(ctx, 1)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("GetWaitingTask got %d tasks, err: %v", len(tasks), err)
+	}
+	if err = add(); !lighttaskscheduler.IsTaskExists(err) {
+		t.Fatalf("claimed task is added again, err: %v", err)
+	}
+	task, err := c.ToRunningStatus(ctx, &tasks[0])
+	if err != nil {
+		t.Fatalf("ToRunningStatus error: %v", err)
+	}
+	if err = add(); !lighttaskscheduler.IsTaskExists(err) {
+		t.Fatalf("running task is added again, err: %v", err)
+	}
+	if task, err = c.ToExportStatus(ctx, task); err != nil {
+		t.Fatalf("ToExportStatus error: %v", err)
+	}
+	if err = add(); !lighttaskscheduler.IsTaskExists(err) {
+		t.Fatalf("exporting task is added again, err: %v", err)
+	}
+	if _, err = c.ToSuccessStatus(ctx, task); err != nil {
+		t.Fatalf("ToSuccessStatus error: %v", err)
+	}
+	if count, _ := c.GetRunningTaskCount(ctx); count != 0 {
+		t.Fatalf("running count want 0, got %d", count)
+	}
+	if err = add(); err != nil {
+		t.Fatalf("finished task can not be added again: %v", err)
+	}
+
+	// 取出的任务放回等待队列以后仍然是等待中，停止以后可以重新添加
+	if tasks, err = c.GetWaitingTask(ctx, 1); err != nil || len(tasks) != 1 {
+		t.Fatalf("GetWaitingTask got %d tasks, err: %v", len(tasks), err)
+	}
+	if err = c.RestoreWaitingTask(ctx, tasks); err != nil {
+		t.Fatalf("RestoreWaitingTask error: %v", err)
+	}
+	if err = add(); !lighttaskscheduler.IsTaskExists(err) {
+		t.Fatalf("restored task is added again, err: %v", err)
+	}
+	if tasks, err = c.GetWaitingTask(ctx, 1); err != nil || len(tasks) != 1 {
+		t.Fatalf("GetWaitingTask got %d tasks, err: %v", len(tasks), err)
+	}
+	if _, err = c.ToStopStatus(ctx, &lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+		t.Fatalf("ToStopStatus error: %v", err)
+	}
+	if err = add(); err != nil {
+		t.Fatalf("stopped task can not be added again: %v", err)
+	}
+}
@@ -2,91 +2,804 @@ package memeorycontainer
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/redis/go-redis/v9"
 )
 
+const (
+	// redisPriorityWeight 等待队列的分数为 入队序号 - TaskPriority * redisPriorityWeight，
+	// 优先级大的分数小先取出，优先级相同的按照入队序号
+	redisPriorityWeight = 1e12
+	// redisMaxPriority 分数需要在 float64 的精确整数范围内，TaskPriority 超出范围的时候按照边界值排序
+	redisMaxPriority = 9000
+
+	defaultRedisLeaseTTL = 30 * time.Second
+)
+
+// redisTask 任务在 redis 中的 json 格式
+type redisTask struct {
+	TaskId            string                        `json:"task_id"`
+	TaskPriority      int                           `json:"task_priority"`
+	TaskItem          json.RawMessage               `json:"task_item,omitempty"`
+	TaskStartTime     time.Time                     `json:"task_start_time"`
+	TaskEnbTime       time.Time                     `json:"task_end_time"`
+	TaskStatus        lighttaskscheduler.TaskStatus `json:"task_status"`
+	FailedReason      string                        `json:"failed_reason,omitempty"`
+	TaskAttemptsTime  int32                         `json:"task_attempts_time"`
+	TaskVersion       int64                         `json:"task_version"`
+	TaskHeartbeatTime time.Time                     `json:"task_heartbeat_time"`
+	TaskCheckpoint    []byte                        `json:"task_checkpoint,omitempty"`
+}
+
+// redisKeys 容器使用的 redis key，使用相同的 hash tag，redis 集群中也在同一个 slot，可以在 lua 脚本中一起操作
+type redisKeys struct {
+	waiting   string // ZSET 等待中的任务，taskId -> 分数
+	tasks     string // HASH 等待中的任务数据，taskId -> json
+	claimed   string // HASH 已经取出还没有转移到运行中的任务，taskId -> json
+	running   string // HASH 运行中的任务，taskId -> json
+	exporting string // HASH 导出中的任务，taskId -> json
+	owners    string // HASH 取出、运行中和导出中的任务所属的调度器，taskId -> owner
+	leases    string // ZSET 取出、运行中和导出中的任务的租约，taskId -> 过期时间的毫秒时间戳
+	tokens    string // HASH 任务当前租约的标识，每次取出任务分配新的标识，taskId -> token
+	progress  string // HASH 运行中任务的进度，taskId -> json
+	seq       string // 添加任务的入队序号，递增
+	headSeq   string // 放回等待队列的任务的入队序号，递减
+	leaseSeq  string // 租约标识的序号，递增
+}
+
+func makeRedisKeys(prefix string) redisKeys {
+	key := func(name string) string { return fmt.Sprintf("{%s}:%s", prefix, name) }
+	return redisKeys{
+		waiting:   key("waiting"),
+		tasks:     key("tasks"),
+		claimed:   key("claimed"),
+		running:   key("running"),
+		exporting: key("exporting"),
+		owners:    key("owners"),
+		leases:    key("leases"),
+		tokens:    key("tokens"),
+		progress:  key("progress"),
+		seq:       key("seq"),
+		headSeq:   key("headseq"),
+		leaseSeq:  key("leaseseq"),
+	}
+}
+
+// addScript 添加等待中的任务，任务已经在等待中，或者被取出、运行中、导出中的时候返回 0
+// KEYS: waiting, tasks, seq, owners ARGV: taskId, json, priority
+var addScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[2], ARGV[1]) == 1 or redis.call('HEXISTS', KEYS[4], ARGV[1]) == 1 then
+	return 0
+end
+local seq = redis.call('INCR', KEYS[3])
+redis.call('ZADD', KEYS[1], string.format('%.0f', seq - tonumber(ARGV[3]) * 1e12), ARGV[1])
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+return 1
+`)
+
+// claimScript 取出分数最小的最多 limit 个任务，加上租约，返回任务数据和租约标识交替的列表
+// KEYS: waiting, tasks, claimed, owners, leases, tokens, leaseseq ARGV: limit, owner, 租约过期时间
+var claimScript = redis.NewScript(`
+local ids = redis.call('ZRANGE', KEYS[1], 0, tonumber(ARGV[1]) - 1)
+local result = {}
+for _, id in ipairs(ids) do
+	local data = redis.call('HGET', KEYS[2], id)
+	redis.call('ZREM', KEYS[1], id)
+	redis.call('HDEL', KEYS[2], id)
+	if data then
+		local token = redis.call('INCR', KEYS[7])
+		redis.call('HSET', KEYS[3], id, data)
+		redis.call('HSET', KEYS[4], id, ARGV[2])
+		redis.call('ZADD', KEYS[5], ARGV[3], id)
+		redis.call('HSET', KEYS[6], id, token)
+		table.insert(result, data)
+		table.insert(result, tostring(token))
+	end
+end
+return result
+`)
+
+// restoreScript 把本调度器取出的任务放回等待队列的头部，保持参数的顺序，已经不是本调度器取出的任务忽略
+// KEYS: waiting, tasks, claimed, owners, leases, headseq, tokens ARGV: owner, taskId, priority, token, taskId, priority, token...
+var restoreScript = redis.NewScript(`
+local n = (#ARGV - 1) / 3
+if n == 0 then
+	return 0
+end
+local base = redis.call('DECRBY', KEYS[6], n)
+local restored = 0
+for i = 1, n do
+	local id = ARGV[3 * i - 1]
+	local data = redis.call('HGET', KEYS[3], id)
+	if data and redis.call('HGET', KEYS[4], id) == ARGV[1] and redis.call('HGET', KEYS[7], id) == ARGV[3 * i + 1] and
+		redis.call('HEXISTS', KEYS[2], id) == 0 then
+		redis.call('HDEL', KEYS[3], id)
+		redis.call('HDEL', KEYS[4], id)
+		redis.call('ZREM', KEYS[5], id)
+		redis.call('HDEL', KEYS[7], id)
+		redis.call('ZADD', KEYS[1], string.format('%.0f', base + i - 1 - tonumber(ARGV[3 * i]) * 1e12), id)
+		redis.call('HSET', KEYS[2], id, data)
+		restored = restored + 1
+	end
+end
+return restored
+`)
+
+// runScript 本调度器取出或者运行中的任务转移到运行中，续租，
+// 任务已经被停止或者删除的时候返回 0，不属于本调度器或者租约已经过期的时候返回 -1
+// KEYS: claimed, running, owners, leases, tokens ARGV: taskId, json, owner, token, 当前时间, 租约过期时间
+var runScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 and redis.call('HEXISTS', KEYS[2], ARGV[1]) == 0 then
+	return 0
+end
+local expiry = redis.call('ZSCORE', KEYS[4], ARGV[1])
+if redis.call('HGET', KEYS[3], ARGV[1]) ~= ARGV[3] or redis.call('HGET', KEYS[5], ARGV[1]) ~= ARGV[4] or
+	not expiry or tonumber(expiry) < tonumber(ARGV[5]) then
+	return -1
+end
+redis.call('HDEL', KEYS[1], ARGV[1])
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+redis.call('ZADD', KEYS[4], ARGV[6], ARGV[1])
+return 1
+`)
+
+// addRunningScript 恢复运行中的任务，从等待队列和取出的任务中删除，返回新的租约标识，
+// 任务已经在运行中或者导出中的时候返回 0
+// KEYS: waiting, tasks, claimed, running, exporting, owners, leases, tokens, leaseseq ARGV: taskId, json, owner, 租约过期时间
+var addRunningScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[4], ARGV[1]) == 1 or redis.call('HEXISTS', KEYS[5], ARGV[1]) == 1 then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('HDEL', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+local token = redis.call('INCR', KEYS[9])
+redis.call('HSET', KEYS[4], ARGV[1], ARGV[2])
+redis.call('HSET', KEYS[6], ARGV[1], ARGV[3])
+redis.call('ZADD', KEYS[7], ARGV[4], ARGV[1])
+redis.call('HSET', KEYS[8], ARGV[1], token)
+return token
+`)
+
+// transitionScript 任务从 sources 中的状态转移出去，sources 是逗号分隔的 waiting、claimed、running、exporting，
+// owned 为 1 的时候取出、运行中和导出中的任务需要属于 owner，持有 token 标识的租约并且没有过期，
+// target 为 exporting 的时候转移到导出中，继续持有租约，否则从所有的队列中删除，
+// 成功返回 1，任务不在 sources 状态返回 0，不属于 owner 或者租约已经过期返回 -1
+// KEYS: waiting, tasks, claimed, running, exporting, owners, leases, tokens, progress
+// ARGV: taskId, owner, token, 当前时间, sources, owned, target, json, 租约过期时间
+var transitionScript = redis.NewScript(`
+local id = ARGV[1]
+local keyOf = {waiting = KEYS[2], claimed = KEYS[3], running = KEYS[4], exporting = KEYS[5]}
+local from = nil
+for source in string.gmatch(ARGV[5], '[^,]+') do
+	if redis.call('HEXISTS', keyOf[source], id) == 1 then
+		from = source
+		break
+	end
+end
+if not from then
+	return 0
+end
+if from ~= 'waiting' and ARGV[6] == '1' then
+	local expiry = redis.call('ZSCORE', KEYS[7], id)
+	if redis.call('HGET', KEYS[6], id) ~= ARGV[2] or redis.call('HGET', KEYS[8], id) ~= ARGV[3] or
+		not expiry or tonumber(expiry) < tonumber(ARGV[4]) then
+		return -1
+	end
+end
+redis.call('ZREM', KEYS[1], id)
+redis.call('HDEL', KEYS[2], id)
+redis.call('HDEL', KEYS[3], id)
+redis.call('HDEL', KEYS[4], id)
+redis.call('HDEL', KEYS[5], id)
+redis.call('HDEL', KEYS[9], id)
+if ARGV[7] == 'exporting' then
+	redis.call('HSET', KEYS[5], id, ARGV[8])
+	redis.call('ZADD', KEYS[7], ARGV[9], id)
+else
+	redis.call('HDEL', KEYS[6], id)
+	redis.call('ZREM', KEYS[7], id)
+	redis.call('HDEL', KEYS[8], id)
+end
+return 1
+`)
+
+// progressScript 保存运行中任务的进度
+// KEYS: running, progress ARGV: taskId, json
+var progressScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 1 then
+	redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+end
+return 0
+`)
+
+// listScript 按照取出的顺序返回等待中的任务
+// KEYS: waiting, tasks
+var listScript = redis.NewScript(`
+local ids = redis.call('ZRANGE', KEYS[1], 0, -1)
+local result = {}
+for _, id in ipairs(ids) do
+	local data = redis.call('HGET', KEYS[2], id)
+	if data then
+		table.insert(result, data)
+	end
+end
+return result
+`)
+
+// maintainScript 给本调度器持有租约的任务续租，把租约过期的取出、运行中和导出中的任务放回等待队列的头部，返回放回的任务 id
+// KEYS: waiting, tasks, claimed, running, exporting, owners, leases, tokens, progress, headseq
+// ARGV: 当前时间, owner, 租约过期时间, taskId, token, taskId, token...
+var maintainScript = redis.NewScript(`
+for i = 4, #ARGV, 2 do
+	if redis.call('HGET', KEYS[6], ARGV[i]) == ARGV[2] and redis.call('HGET', KEYS[8], ARGV[i]) == ARGV[i + 1] then
+		redis.call('ZADD', KEYS[7], ARGV[3], ARGV[i])
+	end
+end
+local expired = redis.call('ZRANGEBYSCORE', KEYS[7], '-inf', '(' .. ARGV[1])
+for _, id in ipairs(expired) do
+	local data = redis.call('HGET', KEYS[3], id)
+	if not data then
+		data = redis.call('HGET', KEYS[4], id)
+	end
+	if not data then
+		data = redis.call('HGET', KEYS[5], id)
+	end
+	redis.call('HDEL', KEYS[3], id)
+	redis.call('HDEL', KEYS[4], id)
+	redis.call('HDEL', KEYS[5], id)
+	redis.call('HDEL', KEYS[6], id)
+	redis.call('ZREM', KEYS[7], id)
+	redis.call('HDEL', KEYS[8], id)
+	redis.call('HDEL', KEYS[9], id)
+	if data and redis.call('HEXISTS', KEYS[2], id) == 0 then
+		local priority = tonumber(cjson.decode(data)['task_priority']) or 0
+		priority = math.max(-9000, math.min(9000, priority))
+		local seq = redis.call('DECR', KEYS[10])
+		redis.call('ZADD', KEYS[1], string.format('%.0f', seq - priority * 1e12), id)
+		redis.call('HSET', KEYS[2], id, data)
+	end
+end
+return expired
+`)
+
 // redisContainer redis 作为容器，支持任务优先级，并且可以多进程，多副本共享数据
+// 等待中的任务按照 TaskPriority 从大到小、优先级相同的按照添加的顺序取出，
+// 取出、运行中和导出中的任务属于取出任务的调度器，每次取出分配新的租约标识，调度器需要定期续租，
+// 租约过期的任务放回等待队列由其他调度器重新调度，之后原来的调度器对任务的状态转移都会被拒绝，
+// 多副本共享同一个前缀的时候，TaskLimit 限制的是所有副本的运行中任务总数
 type redisContainer struct {
 	MemeoryContainer
-	// TODO
+
+	client   redis.UniversalClient
+	keys     redisKeys
+	owner    string             // 调度器的标识，区分取出和运行中的任务属于哪一个副本
+	newItem  func() interface{} // 构造任务对象，用于把 json 解析成 TaskItem
+	leaseTTL time.Duration      // 取出和运行中的任务的租约时间
+	timeout  time.Duration      // 没有等待中的任务的时候 GetWaitingTask 等待的超时时间
+	clock    lighttaskscheduler.Clock
+
+	maintainLock sync.Mutex
+	lastMaintain time.Time
+
+	tokens sync.Map // 本调度器持有的租约，taskId -> 租约标识
+
+	waitingNotify chan struct{} // 本进程添加任务的通知，唤醒阻塞的 GetWaitingTask
+}
+
+// MakeRedisContainer 构造 redis 任务容器，prefix 是所有 key 的前缀，使用相同前缀的多个进程共享任务，
+// timeout 表示没有等待中的任务的时候 GetWaitingTask 等待的超时时间，其他进程添加的任务最迟在下一次 GetWaitingTask 取出
+func MakeRedisContainer(client redis.UniversalClient, prefix string, timeout time.Duration) *redisContainer {
+	host, _ := os.Hostname()
+	return &redisContainer{
+		client:        client,
+		keys:          makeRedisKeys(prefix),
+		owner:         fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+		leaseTTL:      defaultRedisLeaseTTL,
+		timeout:       timeout,
+		clock:         lighttaskscheduler.RealClock,
+		waitingNotify: make(chan struct{}, 1),
+	}
+}
+
+// SetClock 设置容器使用的时钟，租约的时间从该时钟获取，多个进程的时钟需要同步
+func (r *redisContainer) SetClock(clock lighttaskscheduler.Clock) {
+	r.clock = lighttaskscheduler.ClockOrReal(clock)
+}
+
+// SetOwner 设置调度器的标识，默认由主机名、进程号和启动时间组成，
+// 重启以后使用相同的标识，可以在 GetRunningTask 的时候继续持有之前运行中的任务
+func (r *redisContainer) SetOwner(owner string) {
+	r.owner = owner
+}
+
+// SetLeaseTTL 设置租约时间，默认 30 秒，调度器在 GetRunningTaskCount 和 GetWaitingTask 的时候续租，
+// 导出的时间也需要小于租约时间，否则导出中的任务会被放回等待队列重新执行，
+// 租约时间需要大于调度周期，调度器退出超过租约时间以后，它的任务放回等待队列
+func (r *redisContainer) SetLeaseTTL(ttl time.Duration) {
+	r.leaseTTL = ttl
+}
+
+// SetItemFactory 设置任务对象的构造函数，返回任务对象的指针，任务从 redis 中读取的时候 json 解析成该类型，
+// 不设置的时候 TaskItem 解析成 map、string 等 json 的通用类型
+func (r *redisContainer) SetItemFactory(newItem func() interface{}) {
+	r.newItem = newItem
+}
+
+// encode 把任务序列化成 json
+func (r *redisContainer) encode(task lighttaskscheduler.Task) (string, error) {
+	rt := redisTask{
+		TaskId:            task.TaskId,
+		TaskPriority:      task.TaskPriority,
+		TaskStartTime:     task.TaskStartTime,
+		TaskEnbTime:       task.TaskEnbTime,
+		TaskStatus:        task.TaskStatus,
+		TaskAttemptsTime:  task.TaskAttemptsTime,
+		TaskVersion:       task.TaskVersion,
+		TaskHeartbeatTime: task.TaskHeartbeatTime,
+		TaskCheckpoint:    task.TaskCheckpoint,
+	}
+	if task.FailedReason != nil {
+		rt.FailedReason = task.FailedReason.Error()
+	}
+	if task.TaskItem != nil {
+		item, err := json.Marshal(task.TaskItem)
+		if err != nil {
+			return "", fmt.Errorf("marshal TaskItem of task %s error: %v", task.TaskId, err)
+		}
+		rt.TaskItem = item
+	}
+	data, err := json.Marshal(rt)
+	if err != nil {
+		return "", fmt.Errorf("marshal task %s error: %v", task.TaskId, err)
+	}
+	return string(data), nil
+}
+
+// decode 从 json 解析任务
+func (r *redisContainer) decode(data string) (task lighttaskscheduler.Task, err error) {
+	var rt redisTask
+	if err = json.Unmarshal([]byte(data), &rt); err != nil {
+		return task, fmt.Errorf("unmarshal task error: %v", err)
+	}
+	task = lighttaskscheduler.Task{
+		TaskId:            rt.TaskId,
+		TaskPriority:      rt.TaskPriority,
+		TaskStartTime:     rt.TaskStartTime,
+		TaskEnbTime:       rt.TaskEnbTime,
+		TaskStatus:        rt.TaskStatus,
+		TaskAttemptsTime:  rt.TaskAttemptsTime,
+		TaskVersion:       rt.TaskVersion,
+		TaskHeartbeatTime: rt.TaskHeartbeatTime,
+		TaskCheckpoint:    rt.TaskCheckpoint,
+	}
+	if rt.FailedReason != "" {
+		task.FailedReason = errors.New(rt.FailedReason)
+	}
+	if len(rt.TaskItem) > 0 {
+		var item interface{}
+		if r.newItem != nil {
+			item = r.newItem()
+			err = json.Unmarshal(rt.TaskItem, item)
+		} else {
+			err = json.Unmarshal(rt.TaskItem, &item)
+		}
+		if err != nil {
+			return task, fmt.Errorf("unmarshal TaskItem of task %s error: %v", rt.TaskId, err)
+		}
+		task.TaskItem = item
+	}
+	return task, nil
+}
+
+// decodeList 解析 lua 脚本返回的任务列表
+func (r *redisContainer) decodeList(result interface{}) (tasks []lighttaskscheduler.Task, err error) {
+	values, _ := result.([]interface{})
+	for _, value := range values {
+		data, _ := value.(string)
+		task, e := r.decode(data)
+		if e != nil {
+			err = e
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, err
+}
+
+// priorityOf 等待队列排序使用的优先级
+func priorityOf(task lighttaskscheduler.Task) int {
+	if task.TaskPriority > redisMaxPriority {
+		return redisMaxPriority
+	}
+	if task.TaskPriority < -redisMaxPriority {
+		return -redisMaxPriority
+	}
+	return task.TaskPriority
+}
+
+// leaseExpiry 从现在开始计算的租约过期时间
+func (r *redisContainer) leaseExpiry() int64 {
+	return r.clock.Now().Add(r.leaseTTL).UnixMilli()
+}
+
+// tokenOf 本调度器持有的任务租约标识，没有持有的时候返回空
+func (r *redisContainer) tokenOf(taskId string) string {
+	token, _ := r.tokens.Load(taskId)
+	s, _ := token.(string)
+	return s
 }
 
-// MakeredisContainer 构造队列型任务容器
-func MakeredisContainer() *redisContainer {
-	// TODO
-	return &redisContainer{}
+// maintain 续租并且回收租约过期的任务，每三分之一个租约时间最多执行一次
+func (r *redisContainer) maintain(ctx context.Context) error {
+	r.maintainLock.Lock()
+	defer r.maintainLock.Unlock()
+	now := r.clock.Now()
+	if !r.lastMaintain.IsZero() && now.Sub(r.lastMaintain) < r.leaseTTL/3 {
+		return nil
+	}
+	args := []interface{}{now.UnixMilli(), r.owner, now.Add(r.leaseTTL).UnixMilli()}
+	r.tokens.Range(func(taskId, token interface{}) bool {
+		args = append(args, taskId, token)
+		return true
+	})
+	k := r.keys
+	expired, err := maintainScript.Run(ctx, r.client,
+		[]string{k.waiting, k.tasks, k.claimed, k.running, k.exporting, k.owners, k.leases, k.tokens, k.progress,
+			k.headSeq}, args...).StringSlice()
+	if err != nil {
+		return fmt.Errorf("redis maintain leases error: %v", err)
+	}
+	for _, taskId := range expired {
+		r.tokens.Delete(taskId)
+	}
+	r.lastMaintain = now
+	return nil
 }
 
 // AddTask 添加任务
 func (r *redisContainer) AddTask(ctx context.Context, task lighttaskscheduler.Task) (err error) {
-	// TODO
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_WAITING
+	data, err := r.encode(task)
+	if err != nil {
+		return err
+	}
+	k := r.keys
+	added, err := addScript.Run(ctx, r.client, []string{k.waiting, k.tasks, k.seq, k.owners},
+		task.TaskId, data, priorityOf(task)).Int()
+	if err != nil {
+		return fmt.Errorf("redis add task %s error: %v", task.TaskId, err)
+	}
+	if added == 0 {
+		return fmt.Errorf("task %s is already waiting or running: %w", task.TaskId, lighttaskscheduler.ErrTaskExists)
+	}
+	select {
+	case r.waitingNotify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// AddRunningTask 添加正在分析中的任务，用于从持久化容器中恢复数据，任务属于本调度器
+func (r *redisContainer) AddRunningTask(ctx context.Context, task lighttaskscheduler.Task) (err error) {
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_RUNNING
+	data, err := r.encode(task)
+	if err != nil {
+		return err
+	}
+	k := r.keys
+	token, err := addRunningScript.Run(ctx, r.client,
+		[]string{k.waiting, k.tasks, k.claimed, k.running, k.exporting, k.owners, k.leases, k.tokens, k.leaseSeq},
+		task.TaskId, data, r.owner, r.leaseExpiry()).Int64()
+	if err != nil {
+		return fmt.Errorf("redis add running task %s error: %v", task.TaskId, err)
+	}
+	if token > 0 {
+		r.tokens.Store(task.TaskId, strconv.FormatInt(token, 10))
+	}
 	return nil
 }
 
-// GetRunningTask 获取运行中的任务
+// GetRunningTask 获取本调度器运行中的任务，同时记录任务的租约标识，重启以后使用相同标识的调度器可以继续持有任务
 func (r *redisContainer) GetRunningTask(ctx context.Context) (tasks []lighttaskscheduler.Task, err error) {
-	// TODO
+	var running, owners, tokens *redis.MapStringStringCmd
+	if _, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		running = pipe.HGetAll(ctx, r.keys.running)
+		owners = pipe.HGetAll(ctx, r.keys.owners)
+		tokens = pipe.HGetAll(ctx, r.keys.tokens)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("redis get running task error: %v", err)
+	}
+	ownerMap, tokenMap := owners.Val(), tokens.Val()
+	for taskId, data := range running.Val() {
+		if ownerMap[taskId] != r.owner {
+			continue
+		}
+		r.tokens.Store(taskId, tokenMap[taskId])
+		task, e := r.decode(data)
+		if e != nil {
+			err = e
+			continue
+		}
+		tasks = append(tasks, task)
+	}
 	return tasks, err
 }
 
-// GetRunningTaskCount 获取运行中的任务数
+// GetRunningTaskCount 获取所有调度器运行中的任务数，同时续租和回收租约过期的任务
 func (r *redisContainer) GetRunningTaskCount(ctx context.Context) (count int32, err error) {
-	// TODO
-	return 0, nil
+	if err = r.maintain(ctx); err != nil {
+		return 0, err
+	}
+	n, err := r.client.HLen(ctx, r.keys.running).Result()
+	if err != nil {
+		return 0, fmt.Errorf("redis get running task count error: %v", err)
+	}
+	return int32(n), nil
 }
 
-// GetWaitingTask 获取等待中的任务
+// GetWaitingTask 按照优先级取出等待中的任务，没有等待中的任务的时候最多等待 timeout
 func (r *redisContainer) GetWaitingTask(ctx context.Context, limit int32) (tasks []lighttaskscheduler.Task, err error) {
-	// TODO
-	return tasks, nil
+	if limit <= 0 {
+		return nil, nil
+	}
+	if err = r.maintain(ctx); err != nil {
+		return nil, err
+	}
+	tasks = waitTasks(ctx, r.waitingNotify, r.clock, r.timeout, func() []lighttaskscheduler.Task {
+		var claimed []lighttaskscheduler.Task
+		claimed, err = r.claim(ctx, limit)
+		return claimed
+	})
+	return tasks, err
+}
+
+// claim 取出最多 limit 个等待中的任务
+func (r *redisContainer) claim(ctx context.Context, limit int32) (tasks []lighttaskscheduler.Task, err error) {
+	k := r.keys
+	result, err := claimScript.Run(ctx, r.client,
+		[]string{k.waiting, k.tasks, k.claimed, k.owners, k.leases, k.tokens, k.leaseSeq},
+		limit, r.owner, r.leaseExpiry()).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("redis claim waiting task error: %v", err)
+	}
+	for i := 0; i+1 < len(result); i += 2 {
+		task, e := r.decode(result[i])
+		if e != nil {
+			err = e
+			continue
+		}
+		r.tokens.Store(task.TaskId, result[i+1])
+		// 租约过期放回等待队列的任务保存的还是之前的状态
+		task.TaskStatus = lighttaskscheduler.TASK_STATUS_WAITING
+		tasks = append(tasks, task)
+	}
+	return tasks, err
 }
 
-// ToRunningStatus 转移到运行中的状态
+// GetWaitingTaskCount 获取等待中的任务数
+func (r *redisContainer) GetWaitingTaskCount(ctx context.Context) (count int32, err error) {
+	n, err := r.client.ZCard(ctx, r.keys.waiting).Result()
+	if err != nil {
+		return 0, fmt.Errorf("redis get waiting task count error: %v", err)
+	}
+	return int32(n), nil
+}
+
+// ListWaitingTask 按照取出的顺序列出等待中的任务
+func (r *redisContainer) ListWaitingTask(ctx context.Context) (tasks []lighttaskscheduler.Task, err error) {
+	result, err := listScript.Run(ctx, r.client, []string{r.keys.waiting, r.keys.tasks}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis list waiting task error: %v", err)
+	}
+	tasks, err = r.decodeList(result)
+	for i := range tasks {
+		tasks[i].TaskStatus = lighttaskscheduler.TASK_STATUS_WAITING
+	}
+	return tasks, err
+}
+
+// RestoreWaitingTask 把调度策略没有选中的任务放回等待队列，在相同优先级的任务中排在最前面，
+// 取出以后已经被停止、删除或者租约过期的任务不再放回
+func (r *redisContainer) RestoreWaitingTask(ctx context.Context, tasks []lighttaskscheduler.Task) (err error) {
+	if len(tasks) == 0 {
+		return nil
+	}
+	args := []interface{}{r.owner}
+	for _, task := range tasks {
+		args = append(args, task.TaskId, priorityOf(task), r.tokenOf(task.TaskId))
+	}
+	k := r.keys
+	if err = restoreScript.Run(ctx, r.client,
+		[]string{k.waiting, k.tasks, k.claimed, k.owners, k.leases, k.headSeq, k.tokens}, args...).Err(); err != nil {
+		return fmt.Errorf("redis restore waiting task error: %v", err)
+	}
+	for _, task := range tasks {
+		r.tokens.Delete(task.TaskId)
+	}
+	return nil
+}
+
+// 状态转移允许的源状态
+const (
+	redisFromAll     = "waiting,claimed,running,exporting"
+	redisFromRunning = "running"
+	redisFromExport  = "running,exporting"
+)
+
+// transfer 在 lua 脚本中校验任务当前的状态，以及本调度器是否持有任务的租约，然后把任务从 sources 状态转移出去，
+// owned 为 false 的时候不校验租约，用于任何副本都可以发起的停止和删除，exportData 不为空的时候转移到导出中，
+// 否则从所有的队列中删除，返回任务是否在 sources 状态
+func (r *redisContainer) transfer(ctx context.Context, task *lighttaskscheduler.Task, sources string, owned bool,
+	exportData string) (found bool, err error) {
+	k := r.keys
+	target, checkOwner := "", "0"
+	if exportData != "" {
+		target = "exporting"
+	}
+	if owned {
+		checkOwner = "1"
+	}
+	result, err := transitionScript.Run(ctx, r.client,
+		[]string{k.waiting, k.tasks, k.claimed, k.running, k.exporting, k.owners, k.leases, k.tokens, k.progress},
+		task.TaskId, r.owner, r.tokenOf(task.TaskId), r.clock.Now().UnixMilli(), sources, checkOwner, target,
+		exportData, r.leaseExpiry()).Int()
+	if err != nil {
+		return false, fmt.Errorf("redis transfer task %s error: %v", task.TaskId, err)
+	}
+	if result < 0 {
+		return true, fmt.Errorf("task %s is not held by %s or its lease has expired", task.TaskId, r.owner)
+	}
+	if result > 0 && target == "" {
+		r.tokens.Delete(task.TaskId)
+	}
+	return result > 0, nil
+}
+
+// finish 任务结束，从所有的队列中删除，任务不在 sources 状态的时候返回错误
+func (r *redisContainer) finish(ctx context.Context, task *lighttaskscheduler.Task, sources string, owned bool) error {
+	found, err := r.transfer(ctx, task, sources, owned, "")
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("task %s is not in status %s", task.TaskId, sources)
+	}
+	return nil
+}
+
+// ToRunningStatus 转移到运行中的状态，任务在取出以后已经被停止、删除或者租约过期的时候返回错误
 func (r *redisContainer) ToRunningStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
-	// TODO
+	t := *task
+	t.TaskStartTime = r.clock.Now()
+	t.TaskStatus = lighttaskscheduler.TASK_STATUS_RUNNING
+	data, err := r.encode(t)
+	if err != nil {
+		return task, err
+	}
+	k := r.keys
+	ok, err := runScript.Run(ctx, r.client, []string{k.claimed, k.running, k.owners, k.leases, k.tokens},
+		task.TaskId, data, r.owner, r.tokenOf(task.TaskId), r.clock.Now().UnixMilli(), r.leaseExpiry()).Int()
+	if err != nil {
+		return task, fmt.Errorf("redis run task %s error: %v", task.TaskId, err)
+	}
+	if ok == 0 {
+		return task, fmt.Errorf("task %s is not claimed or running", task.TaskId)
+	}
+	if ok < 0 {
+		return task, fmt.Errorf("task %s is not held by %s or its lease has expired", task.TaskId, r.owner)
+	}
+	*task = t
 	return task, nil
 }
 
-// ToExportStatus 转移到停止状态
+// ToStopStatus 转移到停止状态，任何副本都可以停止等待中、取出、运行中和导出中的任务
 func (r *redisContainer) ToStopStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
-	// TODO
+	if err = r.finish(ctx, task, redisFromAll, false); err != nil {
+		return task, err
+	}
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_STOPED
 	return task, nil
 }
 
-// ToExportStatus 转移到删除状态
+// ToDeleteStatus 转移到删除状态，任何副本都可以删除任务，已经结束的任务不在 redis 中，直接返回成功
 func (r *redisContainer) ToDeleteStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
-	// TODO
+	if _, err = r.transfer(ctx, task, redisFromAll, false, ""); err != nil {
+		return task, err
+	}
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_DELETE
 	return task, nil
 }
 
-// ToFailedStatus 转移到失败状态
+// ToFailedStatus 转移到失败状态，等待中的任务从等待队列中删除，取出、运行中和导出中的任务需要本调度器持有租约
 func (r *redisContainer) ToFailedStatus(ctx context.Context, task *lighttaskscheduler.Task, reason error) (
 	newTask *lighttaskscheduler.Task, err error) {
-	// TODO
-	return
+	if err = r.finish(ctx, task, redisFromAll, true); err != nil {
+		return task, err
+	}
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_FAILED
+	task.FailedReason = reason
+	return task, nil
 }
 
-// ToExportStatus 转移到数据导出状态
+// ToExportStatus 转移到数据导出状态，任务保存在导出中的队列，继续持有租约直到成功或者失败，
+// 调度器在导出的过程中退出，租约过期以后任务放回等待队列
 func (r *redisContainer) ToExportStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
-	// TODO
+	t := *task
+	t.TaskStatus = lighttaskscheduler.TASK_STATUS_EXPORTING
+	data, err := r.encode(t)
+	if err != nil {
+		return task, err
+	}
+	found, err := r.transfer(ctx, task, redisFromRunning, true, data)
+	if err != nil {
+		return task, err
+	}
+	if !found {
+		return task, fmt.Errorf("task %s is not running", task.TaskId)
+	}
+	*task = t
 	return task, nil
 }
 
-// ToSuccessStatus 转移到执行成功状态
+// ToSuccessStatus 转移到执行成功状态，任务需要在运行中或者导出中，并且本调度器持有租约
 func (r *redisContainer) ToSuccessStatus(ctx context.Context, task *lighttaskscheduler.Task) (
 	newTask *lighttaskscheduler.Task, err error) {
-	// TODO
+	if err = r.finish(ctx, task, redisFromExport, true); err != nil {
+		return task, err
+	}
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_SUCCESS
 	return task, nil
 }
 
 // UpdateRunningTaskStatus 更新执行中的任务状态
 func (r *redisContainer) UpdateRunningTaskStatus(ctx context.Context,
 	task *lighttaskscheduler.Task, status lighttaskscheduler.AsyncTaskStatus) error {
-	// TODO
+	data, err := json.Marshal(status.GetProgress())
+	if err != nil {
+		return fmt.Errorf("marshal progress of task %s error: %v", task.TaskId, err)
+	}
+	if err = progressScript.Run(ctx, r.client, []string{r.keys.running, r.keys.progress},
+		task.TaskId, string(data)).Err(); err != nil {
+		return fmt.Errorf("redis update progress of task %s error: %v", task.TaskId, err)
+	}
 	return nil
 }
+
+// GetTaskProgress 查询运行中的任务进度
+func (r *redisContainer) GetTaskProgress(ctx context.Context, task *lighttaskscheduler.Task) (
+	progress lighttaskscheduler.TaskProgress, err error) {
+	var data *redis.StringCmd
+	var running *redis.BoolCmd
+	if _, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		data = pipe.HGet(ctx, r.keys.progress, task.TaskId)
+		running = pipe.HExists(ctx, r.keys.running, task.TaskId)
+		return nil
+	}); err != nil && err != redis.Nil {
+		return progress, fmt.Errorf("redis get progress of task %s error: %v", task.TaskId, err)
+	}
+	if data.Err() == nil {
+		if err = json.Unmarshal([]byte(data.Val()), &progress); err != nil {
+			return progress, fmt.Errorf("unmarshal progress of task %s error: %v", task.TaskId, err)
+		}
+		return progress, nil
+	}
+	if running.Val() {
+		// 运行中还没有上报进度
+		return progress, nil
+	}
+	return progress, fmt.Errorf("task %s is not running", task.TaskId)
+}
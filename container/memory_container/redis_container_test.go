@@ -0,0 +1,253 @@
+package memeorycontainer_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+	"github.com/memory-overflow/light-task-scheduler/containertest"
+	"github.com/memory-overflow/light-task-scheduler/fakeclock"
+	"github.com/redis/go-redis/v9"
+)
+
+const redisTestLeaseTTL = 30 * time.Second
+
+func newRedisClient(t *testing.T) redis.UniversalClient {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+type redisTestContainer interface {
+	lighttaskscheduler.TaskContainer
+	lighttaskscheduler.WaitingTaskCounter
+}
+
+// newRedisContainer 构造使用手动时钟的 redis 容器，前缀相同 owner 不同的容器模拟多个副本
+func newRedisContainer(client redis.UniversalClient, clock lighttaskscheduler.Clock, owner string) redisTestContainer {
+	c := memeorycontainer.MakeRedisContainer(client, "test", 10*time.Millisecond)
+	c.SetClock(clock)
+	c.SetOwner(owner)
+	c.SetLeaseTTL(redisTestLeaseTTL)
+	return c
+}
+
+func TestRedisContainer(t *testing.T) {
+	containertest.Run(t, func(t *testing.T) lighttaskscheduler.TaskContainer {
+		return memeorycontainer.MakeRedisContainer(newRedisClient(t), "test", 10*time.Millisecond)
+	})
+}
+
+// claimRunning 取出一个任务并且转移到运行中
+func claimRunning(t *testing.T, c lighttaskscheduler.TaskContainer, taskId string) *lighttaskscheduler.Task {
+	t.Helper()
+	ctx := context.Background()
+	tasks, err := c.GetWaitingTask(ctx, 1)
+	if err != nil || len(tasks) != 1 || tasks[0].TaskId != taskId {
+		t.Fatalf("GetWaitingTask want [%s], got %v, err: %v", taskId, tasks, err)
+	}
+	if tasks[0].TaskStatus != lighttaskscheduler.TASK_STATUS_WAITING {
+		t.Fatalf("claimed task has status %v", tasks[0].TaskStatus)
+	}
+	task, err := c.ToRunningStatus(ctx, &tasks[0])
+	if err != nil {
+		t.Fatalf("ToRunningStatus error: %v", err)
+	}
+	return task
+}
+
+func TestRedisContainerPriority(t *testing.T) {
+	ctx := context.Background()
+	c := newRedisContainer(newRedisClient(t), fakeclock.MakeFakeClock(time.Now()), "a")
+	for _, task := range []lighttaskscheduler.Task{
+		{TaskId: "low", TaskPriority: -1},
+		{TaskId: "normal-1"},
+		{TaskId: "high", TaskPriority: 10},
+		{TaskId: "normal-2"},
+		{TaskId: "highest", TaskPriority: 100000},
+	} {
+		if err := c.AddTask(ctx, task); err != nil {
+			t.Fatalf("AddTask error: %v", err)
+		}
+	}
+	tasks, err := c.GetWaitingTask(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetWaitingTask error: %v", err)
+	}
+	want := []string{"highest", "high", "normal-1", "normal-2", "low"}
+	if len(tasks) != len(want) {
+		t.Fatalf("GetWaitingTask want %v, got %d tasks", want, len(tasks))
+	}
+	for i, task := range tasks {
+		if task.TaskId != want[i] {
+			t.Fatalf("GetWaitingTask want %v, got task %s at %d", want, task.TaskId, i)
+		}
+	}
+}
+
+func TestRedisContainerDuplicateAdd(t *testing.T) {
+	ctx := context.Background()
+	c := newRedisContainer(newRedisClient(t), fakeclock.MakeFakeClock(time.Now()), "a")
+	if err := c.AddTask(ctx, lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	tasks, err := c.GetWaitingTask(ctx, 1)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("GetWaitingTask got %d tasks, err: %v", len(tasks), err)
+	}
+	if err = c.AddTask(ctx, lighttaskscheduler.Task{TaskId: "task"}); !lighttaskscheduler.IsTaskExists(err) {
+		t.Fatal("claimed task is added again")
+	}
+	task, err := c.ToRunningStatus(ctx, &tasks[0])
+	if err != nil {
+		t.Fatalf("ToRunningStatus error: %v", err)
+	}
+	if err = c.AddTask(ctx, lighttaskscheduler.Task{TaskId: "task"}); !lighttaskscheduler.IsTaskExists(err) {
+		t.Fatal("running task is added again")
+	}
+	if _, err = c.ToExportStatus(ctx, task); err != nil {
+		t.Fatalf("ToExportStatus error: %v", err)
+	}
+	if err = c.AddTask(ctx, lighttaskscheduler.Task{TaskId: "task"}); !lighttaskscheduler.IsTaskExists(err) {
+		t.Fatal("exporting task is added again")
+	}
+}
+
+// TestRedisContainerLeaseReclaim 副本 a 的租约过期以后，任务被副本 b 回收重新调度，a 之后的状态转移都被拒绝
+func TestRedisContainerLeaseReclaim(t *testing.T) {
+	ctx := context.Background()
+	client := newRedisClient(t)
+	clock := fakeclock.MakeFakeClock(time.Now())
+	a, b := newRedisContainer(client, clock, "a"), newRedisContainer(client, clock, "b")
+	if err := a.AddTask(ctx, lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	stale := claimRunning(t, a, "task")
+
+	// 租约过期但是还没有被回收的时候，a 的状态转移也被拒绝
+	clock.Advance(redisTestLeaseTTL + time.Second)
+	if _, err := a.ToSuccessStatus(ctx, stale); err == nil {
+		t.Fatal("finish with an expired lease succeeded")
+	}
+
+	if count, err := b.GetRunningTaskCount(ctx); err != nil || count != 0 {
+		t.Fatalf("running count after reclaim want 0, got %d, err: %v", count, err)
+	}
+	if count, err := b.GetWaitingTaskCount(ctx); err != nil || count != 1 {
+		t.Fatalf("waiting count after reclaim want 1, got %d, err: %v", count, err)
+	}
+	task := claimRunning(t, b, "task")
+
+	// a 是过期的 owner，状态转移都被拒绝，不影响 b 运行中的任务
+	if _, err := a.ToSuccessStatus(ctx, stale); err == nil {
+		t.Fatal("stale owner finished the task")
+	}
+	if _, err := a.ToFailedStatus(ctx, stale, errors.New("failed")); err == nil {
+		t.Fatal("stale owner failed the task")
+	}
+	if _, err := a.ToExportStatus(ctx, stale); err == nil {
+		t.Fatal("stale owner exported the task")
+	}
+	if _, err := a.ToRunningStatus(ctx, stale); err == nil {
+		t.Fatal("stale owner restarted the task")
+	}
+	if count, _ := b.GetRunningTaskCount(ctx); count != 1 {
+		t.Fatalf("running count want 1, got %d", count)
+	}
+	if task, err := b.ToExportStatus(ctx, task); err != nil {
+		t.Fatalf("ToExportStatus error: %v", err)
+	} else if _, err = b.ToSuccessStatus(ctx, task); err != nil {
+		t.Fatalf("ToSuccessStatus error: %v", err)
+	}
+	if count, _ := b.GetWaitingTaskCount(ctx); count != 0 {
+		t.Fatalf("waiting count want 0, got %d", count)
+	}
+}
+
+// TestRedisContainerExportReclaim 导出的过程中副本退出，租约过期以后导出中的任务放回等待队列
+func TestRedisContainerExportReclaim(t *testing.T) {
+	ctx := context.Background()
+	client := newRedisClient(t)
+	clock := fakeclock.MakeFakeClock(time.Now())
+	a, b := newRedisContainer(client, clock, "a"), newRedisContainer(client, clock, "b")
+	if err := a.AddTask(ctx, lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	task, err := a.ToExportStatus(ctx, claimRunning(t, a, "task"))
+	if err != nil {
+		t.Fatalf("ToExportStatus error: %v", err)
+	}
+	if count, _ := a.GetRunningTaskCount(ctx); count != 0 {
+		t.Fatalf("running count after export want 0, got %d", count)
+	}
+
+	// 租约时间内导出中的任务不会被回收
+	clock.Advance(redisTestLeaseTTL / 2)
+	b.GetRunningTaskCount(ctx)
+	if count, _ := b.GetWaitingTaskCount(ctx); count != 0 {
+		t.Fatalf("exporting task is reclaimed before its lease expired, waiting count %d", count)
+	}
+
+	clock.Advance(redisTestLeaseTTL)
+	b.GetRunningTaskCount(ctx)
+	if count, _ := b.GetWaitingTaskCount(ctx); count != 1 {
+		t.Fatalf("exporting task is not reclaimed after its lease expired, waiting count %d", count)
+	}
+	if _, err = a.ToSuccessStatus(ctx, task); err == nil {
+		t.Fatal("stale owner finished the reclaimed exporting task")
+	}
+	claimRunning(t, b, "task")
+}
+
+// TestRedisContainerRenewLease 调度器定期续租，运行时间超过租约时间的任务不会被回收
+func TestRedisContainerRenewLease(t *testing.T) {
+	ctx := context.Background()
+	client := newRedisClient(t)
+	clock := fakeclock.MakeFakeClock(time.Now())
+	a, b := newRedisContainer(client, clock, "a"), newRedisContainer(client, clock, "b")
+	if err := a.AddTask(ctx, lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	task := claimRunning(t, a, "task")
+	for i := 0; i < 5; i++ {
+		clock.Advance(redisTestLeaseTTL / 2)
+		if _, err := a.GetRunningTaskCount(ctx); err != nil {
+			t.Fatalf("GetRunningTaskCount error: %v", err)
+		}
+		b.GetRunningTaskCount(ctx)
+	}
+	if count, _ := b.GetRunningTaskCount(ctx); count != 1 {
+		t.Fatalf("renewed task is reclaimed, running count %d", count)
+	}
+	if _, err := a.ToSuccessStatus(ctx, task); err != nil {
+		t.Fatalf("ToSuccessStatus error: %v", err)
+	}
+}
+
+// TestRedisContainerStopByOtherReplica 任何副本都可以停止任务，之后原来的 owner 不能再结束任务
+func TestRedisContainerStopByOtherReplica(t *testing.T) {
+	ctx := context.Background()
+	client := newRedisClient(t)
+	clock := fakeclock.MakeFakeClock(time.Now())
+	a, b := newRedisContainer(client, clock, "a"), newRedisContainer(client, clock, "b")
+	if err := a.AddTask(ctx, lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	task := claimRunning(t, a, "task")
+	if _, err := b.ToStopStatus(ctx, &lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+		t.Fatalf("ToStopStatus error: %v", err)
+	}
+	if _, err := a.ToSuccessStatus(ctx, task); err == nil {
+		t.Fatal("stopped task is finished")
+	}
+	if _, err := b.ToStopStatus(ctx, &lighttaskscheduler.Task{TaskId: "task"}); err == nil {
+		t.Fatal("stopped task is stopped again")
+	}
+	if _, err := b.ToDeleteStatus(ctx, &lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+		t.Fatalf("ToDeleteStatus of finished task error: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package memeorycontainer
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+)
+
+// waitingItem 等待队列中的任务
+type waitingItem struct {
+	task  lighttaskscheduler.Task
+	seq   int64 // 入队序号，优先级相同的时候序号小的先取出
+	index int   // 在堆中的下标
+}
+
+// waitingHeap 等待任务的二叉堆
+type waitingHeap struct {
+	items    []*waitingItem
+	priority bool // 为 true 的时候 TaskPriority 大的任务先取出，否则只按照入队序号先进先出
+}
+
+func (h *waitingHeap) Len() int { return len(h.items) }
+
+func (h *waitingHeap) Less(i, j int) bool { return h.lessItem(h.items[i], h.items[j]) }
+
+// lessItem a 是否比 b 先取出
+func (h *waitingHeap) lessItem(a, b *waitingItem) bool {
+	if h.priority && a.task.TaskPriority != b.task.TaskPriority {
+		return a.task.TaskPriority > b.task.TaskPriority
+	}
+	return a.seq < b.seq
+}
+
+func (h *waitingHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *waitingHeap) Push(x interface{}) {
+	item := x.(*waitingItem)
+	item.index = len(h.items)
+	h.items = append(h.items, item)
+}
+
+func (h *waitingHeap) Pop() interface{} {
+	n := len(h.items)
+	item := h.items[n-1]
+	h.items[n-1] = nil
+	h.items = h.items[:n-1]
+	item.index = -1
+	return item
+}
+
+// waitingQueue 带索引的等待队列，可以按照任务 id O(1) 查找，O(log n) 删除和调整优先级，容量自动增长，
+// 不是并发安全的，需要调用方加锁
+type waitingQueue struct {
+	heap    waitingHeap
+	index   map[string]*waitingItem // taskId -> waitingItem
+	headSeq int64                   // 放回队列头部的任务使用递减的负数序号
+	tailSeq int64                   // 添加到队列尾部的任务使用递增的正数序号
+}
+
+// makeWaitingQueue 构造等待队列，capacity 是初始容量
+func makeWaitingQueue(capacity int) *waitingQueue {
+	return &waitingQueue{
+		heap:  waitingHeap{items: make([]*waitingItem, 0, capacity)},
+		index: make(map[string]*waitingItem, capacity),
+	}
+}
+
+// Len 等待中的任务数
+func (w *waitingQueue) Len() int {
+	return w.heap.Len()
+}
+
+// get 按照任务 id 查找等待中的任务
+func (w *waitingQueue) get(taskId string) (lighttaskscheduler.Task, bool) {
+	if item, ok := w.index[taskId]; ok {
+		return item.task, true
+	}
+	return lighttaskscheduler.Task{}, false
+}
+
+// pushBack 把任务添加到队列尾部，任务已经在队列中的时候返回 false
+func (w *waitingQueue) pushBack(task lighttaskscheduler.Task) bool {
+	if _, ok := w.index[task.TaskId]; ok {
+		return false
+	}
+	w.tailSeq++
+	w.push(task, w.tailSeq)
+	return true
+}
+
+// pushFront 把任务放回队列头部，保持 tasks 的顺序，已经在队列中的任务忽略
+func (w *waitingQueue) pushFront(tasks []lighttaskscheduler.Task) {
+	base := w.headSeq - int64(len(tasks))
+	for i, task := range tasks {
+		if _, ok := w.index[task.TaskId]; !ok {
+			w.push(task, base+int64(i))
+		}
+	}
+	w.headSeq = base
+}
+
+func (w *waitingQueue) push(task lighttaskscheduler.Task, seq int64) {
+	item := &waitingItem{task: task, seq: seq}
+	w.index[task.TaskId] = item
+	heap.Push(&w.heap, item)
+}
+
+// pop 取出队列头部的任务
+func (w *waitingQueue) pop() (lighttaskscheduler.Task, bool) {
+	if w.heap.Len() == 0 {
+		return lighttaskscheduler.Task{}, false
+	}
+	item := heap.Pop(&w.heap).(*waitingItem)
+	delete(w.index, item.task.TaskId)
+	return item.task, true
+}
+
+// remove 从队列中删除任务，任务不在队列中的时候返回 false
+func (w *waitingQueue) remove(taskId string) (lighttaskscheduler.Task, bool) {
+	item, ok := w.index[taskId]
+	if !ok {
+		return lighttaskscheduler.Task{}, false
+	}
+	heap.Remove(&w.heap, item.index)
+	delete(w.index, taskId)
+	return item.task, true
+}
+
+// setPriority 修改等待中任务的优先级，调整任务在队列中的位置
+func (w *waitingQueue) setPriority(taskId string, priority int) bool {
+	item, ok := w.index[taskId]
+	if !ok {
+		return false
+	}
+	item.task.TaskPriority = priority
+	heap.Fix(&w.heap, item.index)
+	return true
+}
+
+// setPriorityOrder 设置是否按照优先级排序，重新建堆
+func (w *waitingQueue) setPriorityOrder(priority bool) {
+	w.heap.priority = priority
+	heap.Init(&w.heap)
+}
+
+// tasks 按照取出的顺序返回所有等待中的任务
+func (w *waitingQueue) tasks() []lighttaskscheduler.Task {
+	items := append([]*waitingItem{}, w.heap.items...)
+	sort.Slice(items, func(i, j int) bool {
+		return w.heap.lessItem(items[i], items[j])
+	})
+	tasks := make([]lighttaskscheduler.Task, len(items))
+	for i, item := range items {
+		tasks[i] = item.task
+	}
+	return tasks
+}
+
+// waitTasks 通过 pop 取出等待中的任务，取不到任务的时候等待 notify 的通知，最多等待 timeout
+func waitTasks(ctx context.Context, notify chan struct{}, clock lighttaskscheduler.Clock, timeout time.Duration,
+	pop func() []lighttaskscheduler.Task) []lighttaskscheduler.Task {
+	if tasks := pop(); len(tasks) > 0 {
+		return tasks
+	}
+	deadline := clock.After(timeout)
+	for {
+		select {
+		case <-notify:
+			// 通知可能是之前已经被取走的任务留下的，取不到任务的时候继续等待
+			if tasks := pop(); len(tasks) > 0 {
+				return tasks
+			}
+		case <-deadline:
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
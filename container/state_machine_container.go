@@ -0,0 +1,246 @@
+package container
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+)
+
+// stateMachineLockStripes 状态转移锁的分段数，同一个任务的状态转移使用同一把锁
+const stateMachineLockStripes = 64
+
+// defaultTombstoneLimit 默认最多记录的结束任务数
+const defaultTombstoneLimit = 100000
+
+// stateMachineContainer 状态机校验容器，包装任意任务容器，拒绝非法的任务状态转移
+// 容器记录经过它转移的任务的最新状态，校验的时候优先使用记录的状态，不完全信任调用方传入的 Task.TaskStatus，
+// 结束的任务作为墓碑记录，最多记录 tombstoneLimit 个，超过以后淘汰最早结束的任务，被淘汰的任务使用调用方传入的状态校验，
+// 删除的任务视为没有开始，可以重新添加
+// 非法的状态转移返回 *lighttaskscheduler.IllegalTransitionError
+type stateMachineContainer struct {
+	container lighttaskscheduler.TaskContainer
+	statusMap sync.Map // 任务最新的状态，taskId -> recordedStatus
+	locks     [stateMachineLockStripes]sync.Mutex
+
+	tombstoneLock  sync.Mutex
+	tombstones     []tombstone // 结束的任务，按照结束的顺序排序
+	tombstoneSeq   uint64
+	tombstoneLimit int
+}
+
+// recordedStatus 记录的任务状态，seq 是结束任务的墓碑序号，未结束的任务为 0
+type recordedStatus struct {
+	status lighttaskscheduler.TaskStatus
+	seq    uint64
+}
+
+// tombstone 结束的任务
+type tombstone struct {
+	taskId string
+	record recordedStatus
+}
+
+// MakeStateMachineContainer 构造状态机校验容器
+func MakeStateMachineContainer(container lighttaskscheduler.TaskContainer) *stateMachineContainer {
+	return &stateMachineContainer{container: container, tombstoneLimit: defaultTombstoneLimit}
+}
+
+// SetTombstoneLimit 设置最多记录的结束任务数，默认 100000，为 0 的时候不记录，需要在使用容器之前设置
+func (c *stateMachineContainer) SetTombstoneLimit(limit int) {
+	c.tombstoneLimit = limit
+}
+
+// Unwrap 返回被包装的任务容器
+func (c *stateMachineContainer) Unwrap() lighttaskscheduler.TaskContainer {
+	return c.container
+}
+
+// lockOf 任务状态转移使用的锁，保证同一个任务的校验、转移和记录状态是原子的
+func (c *stateMachineContainer) lockOf(taskId string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(taskId))
+	return &c.locks[h.Sum32()%stateMachineLockStripes]
+}
+
+// current 任务当前的状态，优先使用容器记录的状态，删除的任务视为没有开始
+func (c *stateMachineContainer) current(task *lighttaskscheduler.Task) lighttaskscheduler.TaskStatus {
+	if v, ok := c.statusMap.Load(task.TaskId); ok {
+		if status := v.(recordedStatus).status; status != lighttaskscheduler.TASK_STATUS_DELETE {
+			return status
+		}
+		return lighttaskscheduler.TASK_STATUS_UNSTART
+	}
+	return task.TaskStatus
+}
+
+// record 记录任务转移以后的状态，需要持有任务的锁，结束的任务记录为墓碑，超过限制的时候淘汰最早的墓碑
+func (c *stateMachineContainer) record(taskId string, status lighttaskscheduler.TaskStatus) {
+	if !lighttaskscheduler.IsTerminalStatus(status) {
+		c.statusMap.Store(taskId, recordedStatus{status: status})
+		return
+	}
+	c.tombstoneLock.Lock()
+	defer c.tombstoneLock.Unlock()
+	c.tombstoneSeq++
+	record := recordedStatus{status: status, seq: c.tombstoneSeq}
+	c.statusMap.Store(taskId, record)
+	c.tombstones = append(c.tombstones, tombstone{taskId: taskId, record: record})
+	if len(c.tombstones) > c.tombstoneLimit {
+		evicted := len(c.tombstones) - c.tombstoneLimit
+		for _, t := range c.tombstones[:evicted] {
+			// 之后重新添加过的任务记录已经变化，不会被删除
+			c.statusMap.CompareAndDelete(t.taskId, t.record)
+		}
+		c.tombstones = append(c.tombstones[:0], c.tombstones[evicted:]...)
+	}
+}
+
+// transfer 校验状态转移，合法的时候执行 do 完成转移，并且记录转移以后的状态
+func (c *stateMachineContainer) transfer(task *lighttaskscheduler.Task, to lighttaskscheduler.TaskStatus,
+	do func() (*lighttaskscheduler.Task, error)) (*lighttaskscheduler.Task, error) {
+	lock := c.lockOf(task.TaskId)
+	lock.Lock()
+	defer lock.Unlock()
+	from := c.current(task)
+	if !lighttaskscheduler.CanTransition(from, to) {
+		return task, &lighttaskscheduler.IllegalTransitionError{TaskId: task.TaskId, From: from, To: to}
+	}
+	newTask, err := do()
+	if err != nil {
+		return newTask, err
+	}
+	c.record(task.TaskId, to)
+	return newTask, nil
+}
+
+// AddTask 添加任务
+func (c *stateMachineContainer) AddTask(ctx context.Context, task lighttaskscheduler.Task) (err error) {
+	lock := c.lockOf(task.TaskId)
+	lock.Lock()
+	defer lock.Unlock()
+	from := c.current(&task)
+	if from == lighttaskscheduler.TASK_STATUS_INVALID {
+		// 新建的任务没有设置状态
+		from = lighttaskscheduler.TASK_STATUS_UNSTART
+	}
+	if !lighttaskscheduler.CanTransition(from, lighttaskscheduler.TASK_STATUS_WAITING) {
+		return &lighttaskscheduler.IllegalTransitionError{
+			TaskId: task.TaskId, From: from, To: lighttaskscheduler.TASK_STATUS_WAITING}
+	}
+	if err = c.container.AddTask(ctx, task); err != nil {
+		return err
+	}
+	c.record(task.TaskId, lighttaskscheduler.TASK_STATUS_WAITING)
+	return nil
+}
+
+// GetRunningTask 获取运行中的任务
+func (c *stateMachineContainer) GetRunningTask(ctx context.Context) (tasks []lighttaskscheduler.Task, err error) {
+	if tasks, err = c.container.GetRunningTask(ctx); err != nil {
+		return tasks, err
+	}
+	for _, task := range tasks {
+		// 从持久化恢复的任务，没有经过本容器转移
+		c.statusMap.LoadOrStore(task.TaskId, recordedStatus{status: lighttaskscheduler.TASK_STATUS_RUNNING})
+	}
+	return tasks, nil
+}
+
+// GetRunningTaskCount 获取运行中的任务数
+func (c *stateMachineContainer) GetRunningTaskCount(ctx context.Context) (count int32, err error) {
+	return c.container.GetRunningTaskCount(ctx)
+}
+
+// GetWaitingTask 获取等待中的任务
+func (c *stateMachineContainer) GetWaitingTask(ctx context.Context, limit int32) (
+	tasks []lighttaskscheduler.Task, err error) {
+	if tasks, err = c.container.GetWaitingTask(ctx, limit); err != nil {
+		return tasks, err
+	}
+	for _, task := range tasks {
+		c.statusMap.LoadOrStore(task.TaskId, recordedStatus{status: lighttaskscheduler.TASK_STATUS_WAITING})
+	}
+	return tasks, nil
+}
+
+// GetTask 查询任务，状态优先使用记录的状态，被包装的容器没有实现 TaskGetter 的时候只返回记录的状态，
+// 删除的任务视为不存在
+func (c *stateMachineContainer) GetTask(ctx context.Context, taskId string) (
+	task lighttaskscheduler.Task, ok bool, err error) {
+	if getter, isGetter := c.container.(lighttaskscheduler.TaskGetter); isGetter {
+		if task, ok, err = getter.GetTask(ctx, taskId); err != nil {
+			return task, ok, err
+		}
+	}
+	if v, recorded := c.statusMap.Load(taskId); recorded {
+		status := v.(recordedStatus).status
+		if status == lighttaskscheduler.TASK_STATUS_DELETE {
+			return lighttaskscheduler.Task{}, false, nil
+		}
+		task.TaskId, task.TaskStatus, ok = taskId, status, true
+	}
+	return task, ok, nil
+}
+
+// ToRunningStatus 转移到运行中的状态
+func (c *stateMachineContainer) ToRunningStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, err error) {
+	return c.transfer(task, lighttaskscheduler.TASK_STATUS_RUNNING, func() (*lighttaskscheduler.Task, error) {
+		return c.container.ToRunningStatus(ctx, task)
+	})
+}
+
+// ToStopStatus 转移到停止状态
+func (c *stateMachineContainer) ToStopStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, err error) {
+	return c.transfer(task, lighttaskscheduler.TASK_STATUS_STOPED, func() (*lighttaskscheduler.Task, error) {
+		return c.container.ToStopStatus(ctx, task)
+	})
+}
+
+// ToDeleteStatus 转移到删除状态
+func (c *stateMachineContainer) ToDeleteStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, err error) {
+	return c.transfer(task, lighttaskscheduler.TASK_STATUS_DELETE, func() (*lighttaskscheduler.Task, error) {
+		return c.container.ToDeleteStatus(ctx, task)
+	})
+}
+
+// ToFailedStatus 转移到失败状态
+func (c *stateMachineContainer) ToFailedStatus(ctx context.Context, task *lighttaskscheduler.Task, reason error) (
+	newTask *lighttaskscheduler.Task, err error) {
+	return c.transfer(task, lighttaskscheduler.TASK_STATUS_FAILED, func() (*lighttaskscheduler.Task, error) {
+		return c.container.ToFailedStatus(ctx, task, reason)
+	})
+}
+
+// ToExportStatus 转移到数据导出状态
+func (c *stateMachineContainer) ToExportStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, err error) {
+	return c.transfer(task, lighttaskscheduler.TASK_STATUS_EXPORTING, func() (*lighttaskscheduler.Task, error) {
+		return c.container.ToExportStatus(ctx, task)
+	})
+}
+
+// ToSuccessStatus 转移到执行成功状态
+func (c *stateMachineContainer) ToSuccessStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	newTask *lighttaskscheduler.Task, err error) {
+	return c.transfer(task, lighttaskscheduler.TASK_STATUS_SUCCESS, func() (*lighttaskscheduler.Task, error) {
+		return c.container.ToSuccessStatus(ctx, task)
+	})
+}
+
+// UpdateRunningTaskStatus 更新执行中的任务状态，只允许更新运行中的任务
+func (c *stateMachineContainer) UpdateRunningTaskStatus(ctx context.Context,
+	task *lighttaskscheduler.Task, status lighttaskscheduler.AsyncTaskStatus) error {
+	lock := c.lockOf(task.TaskId)
+	lock.Lock()
+	defer lock.Unlock()
+	if from := c.current(task); from != lighttaskscheduler.TASK_STATUS_RUNNING {
+		return &lighttaskscheduler.IllegalTransitionError{
+			TaskId: task.TaskId, From: from, To: lighttaskscheduler.TASK_STATUS_RUNNING}
+	}
+	return c.container.UpdateRunningTaskStatus(ctx, task, status)
+}
@@ -0,0 +1,218 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+	"github.com/memory-overflow/light-task-scheduler/containertest"
+)
+
+func TestStateMachineContainer(t *testing.T) {
+	containertest.Run(t, func(t *testing.T) lighttaskscheduler.TaskContainer {
+		return MakeStateMachineContainer(memeorycontainer.MakeQueueContainer(16, 10*time.Millisecond))
+	})
+}
+
+// transitionTargets 随机转移的目标状态，WAITING 通过 AddTask 转移
+var transitionTargets = []lighttaskscheduler.TaskStatus{
+	lighttaskscheduler.TASK_STATUS_WAITING,
+	lighttaskscheduler.TASK_STATUS_RUNNING,
+	lighttaskscheduler.TASK_STATUS_EXPORTING,
+	lighttaskscheduler.TASK_STATUS_SUCCESS,
+	lighttaskscheduler.TASK_STATUS_FAILED,
+	lighttaskscheduler.TASK_STATUS_STOPED,
+	lighttaskscheduler.TASK_STATUS_DELETE,
+}
+
+var allStatus = append([]lighttaskscheduler.TaskStatus{
+	lighttaskscheduler.TASK_STATUS_INVALID, lighttaskscheduler.TASK_STATUS_UNSTART}, transitionTargets...)
+
+// applyTransition 通过状态机容器把任务转移到 to 状态
+func applyTransition(ctx context.Context, c *stateMachineContainer, task lighttaskscheduler.Task,
+	to lighttaskscheduler.TaskStatus) error {
+	var err error
+	switch to {
+	case lighttaskscheduler.TASK_STATUS_WAITING:
+		err = c.AddTask(ctx, task)
+	case lighttaskscheduler.TASK_STATUS_RUNNING:
+		if current, ok, _ := c.GetTask(ctx, task.TaskId); ok && current.TaskStatus == lighttaskscheduler.TASK_STATUS_WAITING {
+			// 等待中的任务需要先从等待队列中取出
+			if _, err = c.GetWaitingTask(ctx, 1); err != nil {
+				return err
+			}
+		}
+		_, err = c.ToRunningStatus(ctx, &task)
+	case lighttaskscheduler.TASK_STATUS_EXPORTING:
+		_, err = c.ToExportStatus(ctx, &task)
+	case lighttaskscheduler.TASK_STATUS_SUCCESS:
+		_, err = c.ToSuccessStatus(ctx, &task)
+	case lighttaskscheduler.TASK_STATUS_FAILED:
+		_, err = c.ToFailedStatus(ctx, &task, errors.New("failed"))
+	case lighttaskscheduler.TASK_STATUS_STOPED:
+		_, err = c.ToStopStatus(ctx, &task)
+	case lighttaskscheduler.TASK_STATUS_DELETE:
+		_, err = c.ToDeleteStatus(ctx, &task)
+	}
+	return err
+}
+
+// TestStateMachineContainerRandomTransitions 随机的状态转移序列，非法的转移被拒绝，被包装的容器和状态机保持一致
+func TestStateMachineContainerRandomTransitions(t *testing.T) {
+	ctx := context.Background()
+	for seed := int64(0); seed < 100; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		queue := memeorycontainer.MakeQueueContainer(10, time.Millisecond)
+		c := MakeStateMachineContainer(queue)
+		// 状态机模型中任务的状态，删除以后视为没有开始，先添加任务，之后容器记录了任务的所有状态
+		if err := c.AddTask(ctx, lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+			t.Fatal(err)
+		}
+		model := lighttaskscheduler.TASK_STATUS_WAITING
+		for step := 0; step < 200; step++ {
+			to := transitionTargets[r.Intn(len(transitionTargets))]
+			// 版本为 0 不比较版本，只校验状态机，包括结束的任务在内，调用方传入的状态都不可信
+			task := lighttaskscheduler.Task{TaskId: "task", TaskStatus: allStatus[r.Intn(len(allStatus))]}
+			legal := lighttaskscheduler.CanTransition(model, to)
+			err := applyTransition(ctx, c, task, to)
+			if legal && err != nil {
+				t.Fatalf("seed %d step %d: legal transition %v -> %v failed: %v", seed, step, model, to, err)
+			}
+			if !legal && !errors.Is(err, lighttaskscheduler.ErrIllegalTransition) {
+				t.Fatalf("seed %d step %d: illegal transition %v -> %v not rejected, err: %v", seed, step, model, to, err)
+			}
+			if legal {
+				model = to
+				if model == lighttaskscheduler.TASK_STATUS_DELETE {
+					model = lighttaskscheduler.TASK_STATUS_UNSTART
+				}
+			}
+			checkContainer(t, ctx, queue, model, seed, step)
+		}
+	}
+}
+
+// checkContainer 检查被包装的容器中任务的位置和状态机模型一致
+func checkContainer(t *testing.T, ctx context.Context, queue lighttaskscheduler.TaskGetter,
+	model lighttaskscheduler.TaskStatus, seed int64, step int) {
+	t.Helper()
+	task, ok, err := queue.GetTask(ctx, "task")
+	if err != nil {
+		t.Fatalf("seed %d step %d: GetTask error: %v", seed, step, err)
+	}
+	switch model {
+	case lighttaskscheduler.TASK_STATUS_WAITING, lighttaskscheduler.TASK_STATUS_RUNNING:
+		if !ok || task.TaskStatus != model {
+			t.Fatalf("seed %d step %d: model status %v, container found %v status %v",
+				seed, step, model, ok, task.TaskStatus)
+		}
+	default:
+		if ok {
+			t.Fatalf("seed %d step %d: model status %v, but container has task with status %v",
+				seed, step, model, task.TaskStatus)
+		}
+	}
+	count, _ := queue.(lighttaskscheduler.TaskContainer).GetRunningTaskCount(ctx)
+	if running := model == lighttaskscheduler.TASK_STATUS_RUNNING; running != (count == 1) || count > 1 {
+		t.Fatalf("seed %d step %d: model status %v, running count %d", seed, step, model, count)
+	}
+}
+
+// TestStateMachineContainerConcurrentFinish 并发结束同一个运行中的任务，只有一个结束转移成功
+func TestStateMachineContainerConcurrentFinish(t *testing.T) {
+	ctx := context.Background()
+	for i := 0; i < 50; i++ {
+		queue := memeorycontainer.MakeQueueContainer(10, time.Millisecond)
+		c := MakeStateMachineContainer(queue)
+		if err := c.AddTask(ctx, lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+			t.Fatal(err)
+		}
+		tasks, err := c.GetWaitingTask(ctx, 1)
+		if err != nil || len(tasks) != 1 {
+			t.Fatalf("GetWaitingTask got %d tasks, err: %v", len(tasks), err)
+		}
+		running, err := c.ToRunningStatus(ctx, &tasks[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		var lock sync.Mutex
+		succeeded := 0
+		for _, to := range []lighttaskscheduler.TaskStatus{lighttaskscheduler.TASK_STATUS_SUCCESS,
+			lighttaskscheduler.TASK_STATUS_FAILED, lighttaskscheduler.TASK_STATUS_STOPED,
+			lighttaskscheduler.TASK_STATUS_EXPORTING} {
+			task, to := *running, to
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if applyTransition(ctx, c, task, to) == nil {
+					lock.Lock()
+					succeeded++
+					lock.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if succeeded != 1 {
+			t.Fatalf("%d concurrent transitions of one running task succeeded, want 1", succeeded)
+		}
+	}
+}
+
+// TestStateMachineContainerTerminal 结束和删除的任务记录为墓碑，调用方传入过期的状态也不能转移
+func TestStateMachineContainerTerminal(t *testing.T) {
+	ctx := context.Background()
+	queue := memeorycontainer.MakeQueueContainer(10, time.Millisecond)
+	c := MakeStateMachineContainer(queue)
+	c.SetTombstoneLimit(1)
+	for _, taskId := range []string{"success", "deleted"} {
+		if err := c.AddTask(ctx, lighttaskscheduler.Task{TaskId: taskId}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tasks, err := c.GetWaitingTask(ctx, 1)
+	if err != nil || len(tasks) != 1 || tasks[0].TaskId != "success" {
+		t.Fatalf("GetWaitingTask want [success], got %v, err: %v", tasks, err)
+	}
+	running, err := c.ToRunningStatus(ctx, &tasks[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.ToSuccessStatus(ctx, running); err != nil {
+		t.Fatal(err)
+	}
+	stale := lighttaskscheduler.Task{TaskId: "success", TaskStatus: lighttaskscheduler.TASK_STATUS_RUNNING}
+	if _, err = c.ToRunningStatus(ctx, &stale); !errors.Is(err, lighttaskscheduler.ErrIllegalTransition) {
+		t.Fatalf("ToRunningStatus of success task want illegal transition, got %v", err)
+	}
+	if count, _ := c.GetRunningTaskCount(ctx); count != 0 {
+		t.Fatalf("running count want 0, got %d", count)
+	}
+	if task, ok, _ := c.GetTask(ctx, "success"); !ok || task.TaskStatus != lighttaskscheduler.TASK_STATUS_SUCCESS {
+		t.Fatalf("GetTask want success, got %v %v", ok, task.TaskStatus)
+	}
+
+	if _, err = c.ToDeleteStatus(ctx, &lighttaskscheduler.Task{TaskId: "deleted"}); err != nil {
+		t.Fatal(err)
+	}
+	stale = lighttaskscheduler.Task{TaskId: "deleted", TaskStatus: lighttaskscheduler.TASK_STATUS_WAITING}
+	if _, err = c.ToFailedStatus(ctx, &stale, errors.New("failed")); !errors.Is(err, lighttaskscheduler.ErrIllegalTransition) {
+		t.Fatalf("ToFailedStatus of deleted task want illegal transition, got %v", err)
+	}
+	if _, ok, _ := c.GetTask(ctx, "deleted"); ok {
+		t.Fatal("deleted task is found")
+	}
+	if err = c.AddTask(ctx, stale); err != nil {
+		t.Fatalf("re-add deleted task error: %v", err)
+	}
+
+	// 超过墓碑数量限制以后，最早结束的任务被淘汰，使用调用方传入的状态校验
+	if _, ok, _ := c.GetTask(ctx, "success"); ok {
+		t.Fatal("evicted tombstone is found")
+	}
+}
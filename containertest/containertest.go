@@ -0,0 +1,589 @@
+// Package containertest 任务容器的一致性测试套件，自己实现的任务容器可以在测试中调用 Run，
+// 校验容器的行为是否满足调度器的要求
+
+package containertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+)
+
+// Factory 构造一个新的空任务容器，每个测试用例都会调用一次
+type Factory func(t *testing.T) lighttaskscheduler.TaskContainer
+
+// Suite 测试套件的配置
+type Suite struct {
+	// NewContainer 构造一个新的空任务容器，必填，容器的容量需要大于 Concurrency
+	NewContainer Factory
+
+	// NewTask 构造测试用的任务，容器对 TaskItem 有要求的时候需要配置，默认 TaskItem 为任务 id
+	NewTask func(taskId string) lighttaskscheduler.Task
+
+	// Concurrency 并发测试的任务数，默认 100
+	Concurrency int
+}
+
+// Run 使用默认配置运行测试套件
+func Run(t *testing.T, factory Factory) {
+	RunSuite(t, Suite{NewContainer: factory})
+}
+
+// RunSuite 运行测试套件，覆盖 TaskContainer 的所有方法、调度器依赖的运行中任务数的维护、并发安全，
+// 容器实现了 MemeoryContainer、VersionedContainer、CheckpointStore、FinishedOutbox 的时候同时测试对应的接口
+func RunSuite(t *testing.T, s Suite) {
+	if s.NewContainer == nil {
+		t.Fatal("Suite.NewContainer is nil")
+	}
+	if s.NewTask == nil {
+		s.NewTask = func(taskId string) lighttaskscheduler.Task {
+			return lighttaskscheduler.Task{TaskId: taskId, TaskItem: taskId}
+		}
+	}
+	if s.Concurrency <= 0 {
+		s.Concurrency = 100
+	}
+	cases := []struct {
+		name string
+		f    func(t *testing.T, s Suite)
+	}{
+		{"AddAndGetWaitingTask", testAddAndGetWaitingTask},
+		{"GetWaitingTaskLimit", testGetWaitingTaskLimit},
+		{"DuplicateAdd", testDuplicateAdd},
+		{"RunStoppedTask", testRunStoppedTask},
+		{"RunFinishedTask", testRunFinishedTask},
+		{"ToRunningStatus", testToRunningStatus},
+		{"FinishReleasesRunningSlot", testFinishReleasesRunningSlot},
+		{"ToFailedStatus", testToFailedStatus},
+		{"StopWhileWaiting", testStopWhileWaiting},
+		{"DeleteWhileWaiting", testDeleteWhileWaiting},
+		{"ReAddStoppedTask", testReAddStoppedTask},
+		{"UpdateRunningTaskStatus", testUpdateRunningTaskStatus},
+		{"Concurrent", testConcurrent},
+		{"AddRunningTask", testAddRunningTask},
+		{"TaskVersion", testTaskVersion},
+		{"Checkpoint", testCheckpoint},
+		{"FinishedOutbox", testFinishedOutbox},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.f(t, s)
+		})
+	}
+}
+
+// takeWaiting 从容器中取出 n 个等待中的任务，取不到更多任务的时候提前返回
+func takeWaiting(t *testing.T, c lighttaskscheduler.TaskContainer, n int) []lighttaskscheduler.Task {
+	t.Helper()
+	tasks := []lighttaskscheduler.Task{}
+	for len(tasks) < n {
+		got, err := c.GetWaitingTask(context.Background(), int32(n-len(tasks)))
+		if err != nil {
+			t.Fatalf("GetWaitingTask error: %v", err)
+		}
+		if len(got) == 0 {
+			break
+		}
+		tasks = append(tasks, got...)
+	}
+	return tasks
+}
+
+// addAndRun 添加一个任务，并且转移到运行中的状态
+func addAndRun(t *testing.T, s Suite, c lighttaskscheduler.TaskContainer, taskId string) *lighttaskscheduler.Task {
+	t.Helper()
+	ctx := context.Background()
+	if err := c.AddTask(ctx, s.NewTask(taskId)); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	tasks := takeWaiting(t, c, 1)
+	if len(tasks) != 1 || tasks[0].TaskId != taskId {
+		t.Fatalf("GetWaitingTask want task %s, got %v", taskId, taskIds(tasks))
+	}
+	task, err := c.ToRunningStatus(ctx, &tasks[0])
+	if err != nil {
+		t.Fatalf("ToRunningStatus error: %v", err)
+	}
+	return task
+}
+
+func runningCount(t *testing.T, c lighttaskscheduler.TaskContainer) int32 {
+	t.Helper()
+	count, err := c.GetRunningTaskCount(context.Background())
+	if err != nil {
+		t.Fatalf("GetRunningTaskCount error: %v", err)
+	}
+	return count
+}
+
+func taskIds(tasks []lighttaskscheduler.Task) []string {
+	ids := []string{}
+	for _, task := range tasks {
+		ids = append(ids, task.TaskId)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func testAddAndGetWaitingTask(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	ctx := context.Background()
+	want := []string{"task-1", "task-2", "task-3"}
+	for _, id := range want {
+		if err := c.AddTask(ctx, s.NewTask(id)); err != nil {
+			t.Fatalf("AddTask %s error: %v", id, err)
+		}
+	}
+	tasks := takeWaiting(t, c, len(want))
+	if got := taskIds(tasks); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("GetWaitingTask want %v, got %v", want, got)
+	}
+	for _, task := range tasks {
+		if task.TaskStatus != lighttaskscheduler.TASK_STATUS_WAITING {
+			t.Errorf("waiting task %s has status %v", task.TaskId, task.TaskStatus)
+		}
+	}
+	if count := runningCount(t, c); count != 0 {
+		t.Errorf("running count want 0 before any ToRunningStatus, got %d", count)
+	}
+}
+
+func testGetWaitingTaskLimit(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if err := c.AddTask(ctx, s.NewTask(fmt.Sprintf("task-%d", i))); err != nil {
+			t.Fatalf("AddTask error: %v", err)
+		}
+	}
+	tasks, err := c.GetWaitingTask(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetWaitingTask error: %v", err)
+	}
+	if len(tasks) > 2 {
+		t.Fatalf("GetWaitingTask with limit 2 returns %d tasks", len(tasks))
+	}
+}
+
+func testDuplicateAdd(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	ctx := context.Background()
+	if err := c.AddTask(ctx, s.NewTask("task-1")); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	// 重复添加可以返回错误，也可以覆盖，但是不能被调度两次
+	c.AddTask(ctx, s.NewTask("task-1"))
+	tasks := takeWaiting(t, c, 2)
+	if len(tasks) != 1 {
+		t.Fatalf("duplicate added task must be scheduled once, got %v", taskIds(tasks))
+	}
+	// 已经取出还没有转移到运行中的任务，重复添加也不能被再次调度
+	c.AddTask(ctx, s.NewTask("task-1"))
+	if again := takeWaiting(t, c, 1); len(again) != 0 {
+		t.Fatalf("claimed task is scheduled again after duplicate add, got %v", taskIds(again))
+	}
+
+	// 运行中的任务重复添加，不能被再次调度，运行中的任务数不变
+	addAndRun(t, s, c, "task-2")
+	c.AddTask(ctx, s.NewTask("task-2"))
+	if again := takeWaiting(t, c, 1); len(again) != 0 {
+		t.Fatalf("running task is scheduled again after duplicate add, got %v", taskIds(again))
+	}
+	if count := runningCount(t, c); count != 1 {
+		t.Fatalf("running count want 1 after duplicate add, got %d", count)
+	}
+}
+
+// testRunStoppedTask 取出以后被停止的任务，调度器之后的 ToRunningStatus 必须失败，不能复活任务
+func testRunStoppedTask(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	ctx := context.Background()
+	if err := c.AddTask(ctx, s.NewTask("task-1")); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	tasks := takeWaiting(t, c, 1)
+	if len(tasks) != 1 {
+		t.Fatalf("GetWaitingTask want [task-1], got %v", taskIds(tasks))
+	}
+	stop := s.NewTask("task-1")
+	if _, err := c.ToStopStatus(ctx, &stop); err != nil {
+		t.Fatalf("ToStopStatus error: %v", err)
+	}
+	if _, err := c.ToRunningStatus(ctx, &tasks[0]); err == nil {
+		t.Fatal("ToRunningStatus of a stopped task succeeded")
+	}
+	if count := runningCount(t, c); count != 0 {
+		t.Fatalf("running count want 0, got %d", count)
+	}
+}
+
+// testRunFinishedTask 成功的任务不能再转移到运行中，调用方传入过期的运行中状态也不行
+func testRunFinishedTask(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	ctx := context.Background()
+	task := addAndRun(t, s, c, "task-1")
+	stale := *task
+	if _, err := c.ToSuccessStatus(ctx, task); err != nil {
+		t.Fatalf("ToSuccessStatus error: %v", err)
+	}
+	if _, err := c.ToRunningStatus(ctx, &stale); err == nil {
+		t.Fatal("ToRunningStatus of a success task succeeded")
+	}
+	if count := runningCount(t, c); count != 0 {
+		t.Fatalf("running count want 0, got %d", count)
+	}
+}
+
+func testToRunningStatus(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	task := addAndRun(t, s, c, "task-1")
+	if task.TaskStatus != lighttaskscheduler.TASK_STATUS_RUNNING {
+		t.Errorf("ToRunningStatus returns status %v", task.TaskStatus)
+	}
+	if count := runningCount(t, c); count != 1 {
+		t.Errorf("running count want 1, got %d", count)
+	}
+	running, err := c.GetRunningTask(context.Background())
+	if err != nil {
+		t.Fatalf("GetRunningTask error: %v", err)
+	}
+	if got := taskIds(running); len(got) != 1 || got[0] != "task-1" {
+		t.Fatalf("GetRunningTask want [task-1], got %v", got)
+	}
+	if running[0].TaskStatus != lighttaskscheduler.TASK_STATUS_RUNNING {
+		t.Errorf("running task has status %v", running[0].TaskStatus)
+	}
+	// 重试的时候会对运行中的任务再次调用 ToRunningStatus，运行中的任务数不能增加
+	task.TaskAttemptsTime++
+	if _, err := c.ToRunningStatus(context.Background(), task); err != nil {
+		t.Fatalf("ToRunningStatus on running task error: %v", err)
+	}
+	if count := runningCount(t, c); count != 1 {
+		t.Errorf("running count want 1 after retry, got %d", count)
+	}
+}
+
+func testFinishReleasesRunningSlot(t *testing.T, s Suite) {
+	ctx := context.Background()
+	transitions := []struct {
+		name   string
+		status lighttaskscheduler.TaskStatus
+		f      func(c lighttaskscheduler.TaskContainer, task *lighttaskscheduler.Task) (*lighttaskscheduler.Task, error)
+	}{
+		{"ToExportStatus", lighttaskscheduler.TASK_STATUS_EXPORTING, func(c lighttaskscheduler.TaskContainer,
+			task *lighttaskscheduler.Task) (*lighttaskscheduler.Task, error) {
+			return c.ToExportStatus(ctx, task)
+		}},
+		{"ToSuccessStatus", lighttaskscheduler.TASK_STATUS_SUCCESS, func(c lighttaskscheduler.TaskContainer,
+			task *lighttaskscheduler.Task) (*lighttaskscheduler.Task, error) {
+			return c.ToSuccessStatus(ctx, task)
+		}},
+		{"ToFailedStatus", lighttaskscheduler.TASK_STATUS_FAILED, func(c lighttaskscheduler.TaskContainer,
+			task *lighttaskscheduler.Task) (*lighttaskscheduler.Task, error) {
+			return c.ToFailedStatus(ctx, task, errors.New("failed"))
+		}},
+		{"ToStopStatus", lighttaskscheduler.TASK_STATUS_STOPED, func(c lighttaskscheduler.TaskContainer,
+			task *lighttaskscheduler.Task) (*lighttaskscheduler.Task, error) {
+			return c.ToStopStatus(ctx, task)
+		}},
+		{"ToDeleteStatus", lighttaskscheduler.TASK_STATUS_DELETE, func(c lighttaskscheduler.TaskContainer,
+			task *lighttaskscheduler.Task) (*lighttaskscheduler.Task, error) {
+			return c.ToDeleteStatus(ctx, task)
+		}},
+	}
+	for _, tr := range transitions {
+		tr := tr
+		t.Run(tr.name, func(t *testing.T) {
+			c := s.NewContainer(t)
+			task := addAndRun(t, s, c, "task-1")
+			newTask, err := tr.f(c, task)
+			if err != nil {
+				t.Fatalf("%s error: %v", tr.name, err)
+			}
+			if newTask.TaskStatus != tr.status {
+				t.Errorf("%s returns status %v, want %v", tr.name, newTask.TaskStatus, tr.status)
+			}
+			// 调度器依赖运行中的任务数做并发控制，离开运行中状态的任务，包括导出中的任务，必须释放调度空位
+			if count := runningCount(t, c); count != 0 {
+				t.Errorf("running count want 0 after %s, got %d", tr.name, count)
+			}
+			running, err := c.GetRunningTask(ctx)
+			if err != nil {
+				t.Fatalf("GetRunningTask error: %v", err)
+			}
+			if len(running) != 0 {
+				t.Errorf("GetRunningTask after %s returns %v", tr.name, taskIds(running))
+			}
+		})
+	}
+}
+
+func testToFailedStatus(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	task := addAndRun(t, s, c, "task-1")
+	reason := errors.New("something wrong")
+	newTask, err := c.ToFailedStatus(context.Background(), task, reason)
+	if err != nil {
+		t.Fatalf("ToFailedStatus error: %v", err)
+	}
+	if newTask.FailedReason == nil || newTask.FailedReason.Error() != reason.Error() {
+		t.Errorf("ToFailedStatus want FailedReason %v, got %v", reason, newTask.FailedReason)
+	}
+}
+
+func testStopWhileWaiting(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	ctx := context.Background()
+	for _, id := range []string{"task-1", "task-2"} {
+		if err := c.AddTask(ctx, s.NewTask(id)); err != nil {
+			t.Fatalf("AddTask error: %v", err)
+		}
+	}
+	task := s.NewTask("task-1")
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_WAITING
+	newTask, err := c.ToStopStatus(ctx, &task)
+	if err != nil {
+		t.Fatalf("ToStopStatus on waiting task error: %v", err)
+	}
+	if newTask.TaskStatus != lighttaskscheduler.TASK_STATUS_STOPED {
+		t.Errorf("ToStopStatus returns status %v", newTask.TaskStatus)
+	}
+	// 停止的等待中任务不能再被调度
+	if got := taskIds(takeWaiting(t, c, 2)); len(got) != 1 || got[0] != "task-2" {
+		t.Fatalf("GetWaitingTask after stopping task-1 want [task-2], got %v", got)
+	}
+	if count := runningCount(t, c); count != 0 {
+		t.Errorf("running count want 0, got %d", count)
+	}
+}
+
+func testDeleteWhileWaiting(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	ctx := context.Background()
+	if err := c.AddTask(ctx, s.NewTask("task-1")); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	task := s.NewTask("task-1")
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_WAITING
+	if _, err := c.ToDeleteStatus(ctx, &task); err != nil {
+		t.Fatalf("ToDeleteStatus on waiting task error: %v", err)
+	}
+	if got := takeWaiting(t, c, 1); len(got) != 0 {
+		t.Fatalf("deleted task must not be scheduled, got %v", taskIds(got))
+	}
+}
+
+func testReAddStoppedTask(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	ctx := context.Background()
+	if err := c.AddTask(ctx, s.NewTask("task-1")); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	task := s.NewTask("task-1")
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_WAITING
+	if _, err := c.ToStopStatus(ctx, &task); err != nil {
+		t.Fatalf("ToStopStatus on waiting task error: %v", err)
+	}
+	// 停止以后重新添加的任务需要可以再次调度
+	if err := c.AddTask(ctx, s.NewTask("task-1")); err != nil {
+		t.Fatalf("AddTask after stopping error: %v", err)
+	}
+	if got := taskIds(takeWaiting(t, c, 2)); len(got) != 1 || got[0] != "task-1" {
+		t.Fatalf("GetWaitingTask after re-adding task-1 want [task-1], got %v", got)
+	}
+}
+
+func testUpdateRunningTaskStatus(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	task := addAndRun(t, s, c, "task-1")
+	status := lighttaskscheduler.AsyncTaskStatus{
+		TaskStatus:   lighttaskscheduler.TASK_STATUS_RUNNING,
+		TaskProgress: lighttaskscheduler.TaskProgress{Percent: 50},
+	}
+	if err := c.UpdateRunningTaskStatus(context.Background(), task, status); err != nil {
+		t.Fatalf("UpdateRunningTaskStatus error: %v", err)
+	}
+	if querier, ok := lighttaskscheduler.ContainerAs[lighttaskscheduler.TaskProgressQuerier](c); ok {
+		progress, err := querier.GetTaskProgress(context.Background(), task)
+		if err != nil {
+			t.Fatalf("GetTaskProgress error: %v", err)
+		}
+		if progress.Percent != 50 {
+			t.Errorf("GetTaskProgress want Percent 50, got %v", progress.Percent)
+		}
+	}
+	if count := runningCount(t, c); count != 1 {
+		t.Errorf("running count want 1, got %d", count)
+	}
+}
+
+func testConcurrent(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	ctx := context.Background()
+	n := s.Concurrency
+	wg := sync.WaitGroup{}
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := c.AddTask(ctx, s.NewTask(fmt.Sprintf("task-%d", i))); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent AddTask error: %v", err)
+	}
+	tasks := takeWaiting(t, c, n)
+	if len(tasks) != n {
+		t.Fatalf("GetWaitingTask want %d tasks, got %d", n, len(tasks))
+	}
+	seen := map[string]bool{}
+	for _, task := range tasks {
+		if seen[task.TaskId] {
+			t.Fatalf("task %s is returned twice", task.TaskId)
+		}
+		seen[task.TaskId] = true
+	}
+
+	errs = make(chan error, 2*n)
+	for i := range tasks {
+		wg.Add(1)
+		go func(task lighttaskscheduler.Task) {
+			defer wg.Done()
+			newTask, err := c.ToRunningStatus(ctx, &task)
+			if err != nil {
+				errs <- fmt.Errorf("ToRunningStatus error: %v", err)
+				return
+			}
+			if _, err := c.ToSuccessStatus(ctx, newTask); err != nil {
+				errs <- fmt.Errorf("ToSuccessStatus error: %v", err)
+			}
+		}(tasks[i])
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent transition %v", err)
+	}
+	if count := runningCount(t, c); count != 0 {
+		t.Errorf("running count want 0 after all tasks succeeded, got %d", count)
+	}
+}
+
+func testAddRunningTask(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	mc, ok := lighttaskscheduler.ContainerAs[memeorycontainer.MemeoryContainer](c)
+	if !ok {
+		t.Skip("container does not implement MemeoryContainer")
+	}
+	ctx := context.Background()
+	task := s.NewTask("task-1")
+	task.TaskStatus = lighttaskscheduler.TASK_STATUS_RUNNING
+	// 从持久化容器恢复的时候可能重复添加，不能重复计数
+	for i := 0; i < 2; i++ {
+		if err := mc.AddRunningTask(ctx, task); err != nil {
+			t.Fatalf("AddRunningTask error: %v", err)
+		}
+	}
+	if count := runningCount(t, c); count != 1 {
+		t.Errorf("running count want 1 after AddRunningTask, got %d", count)
+	}
+	if got := takeWaiting(t, c, 1); len(got) != 0 {
+		t.Errorf("running task must not be waiting, got %v", taskIds(got))
+	}
+}
+
+func testTaskVersion(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	versioned, ok := lighttaskscheduler.ContainerAs[lighttaskscheduler.VersionedContainer](c)
+	if !ok || !versioned.SupportTaskVersion() {
+		t.Skip("container does not support task version")
+	}
+	ctx := context.Background()
+	task := addAndRun(t, s, c, "task-1")
+	if task.TaskVersion == 0 {
+		t.Fatalf("versioned container must set TaskVersion")
+	}
+	stale := *task
+	if _, err := c.ToExportStatus(ctx, task); err != nil {
+		t.Fatalf("ToExportStatus error: %v", err)
+	}
+	// 过期版本的状态转移必须被拒绝
+	_, err := c.ToFailedStatus(ctx, &stale, errors.New("stale"))
+	if !lighttaskscheduler.IsVersionConflict(err) {
+		t.Fatalf("transition with stale version want ErrTaskVersionConflict, got %v", err)
+	}
+	if _, err := c.ToSuccessStatus(ctx, task); err != nil {
+		t.Fatalf("ToSuccessStatus with current version error: %v", err)
+	}
+}
+
+func testCheckpoint(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	store, ok := lighttaskscheduler.ContainerAs[lighttaskscheduler.CheckpointStore](c)
+	if !ok {
+		t.Skip("container does not implement CheckpointStore")
+	}
+	ctx := context.Background()
+	task := addAndRun(t, s, c, "task-1")
+	if checkpoint, err := store.GetCheckpoint(ctx, task); err != nil || checkpoint != nil {
+		t.Fatalf("GetCheckpoint before save want nil, got %q, %v", checkpoint, err)
+	}
+	for _, checkpoint := range []string{"step-1", "step-2"} {
+		if err := store.SaveCheckpoint(ctx, task, []byte(checkpoint)); err != nil {
+			t.Fatalf("SaveCheckpoint error: %v", err)
+		}
+	}
+	if checkpoint, err := store.GetCheckpoint(ctx, task); err != nil || string(checkpoint) != "step-2" {
+		t.Fatalf("GetCheckpoint want latest checkpoint step-2, got %q, %v", checkpoint, err)
+	}
+	if err := store.DeleteCheckpoint(ctx, task); err != nil {
+		t.Fatalf("DeleteCheckpoint error: %v", err)
+	}
+	if checkpoint, err := store.GetCheckpoint(ctx, task); err != nil || checkpoint != nil {
+		t.Fatalf("GetCheckpoint after delete want nil, got %q, %v", checkpoint, err)
+	}
+}
+
+func testFinishedOutbox(t *testing.T, s Suite) {
+	c := s.NewContainer(t)
+	outbox, ok := lighttaskscheduler.ContainerAs[lighttaskscheduler.FinishedOutbox](c)
+	if !ok {
+		t.Skip("container does not implement FinishedOutbox")
+	}
+	ctx := context.Background()
+	for _, id := range []string{"task-1", "task-2"} {
+		if err := outbox.PushFinished(ctx, s.NewTask(id)); err != nil {
+			t.Fatalf("PushFinished error: %v", err)
+		}
+	}
+	records, err := outbox.NextFinished(ctx, 10, 1<<62)
+	if err != nil {
+		t.Fatalf("NextFinished error: %v", err)
+	}
+	if len(records) != 2 || records[0].Task.TaskId != "task-1" || records[1].Task.TaskId != "task-2" {
+		t.Fatalf("NextFinished want task-1, task-2 in order, got %v", records)
+	}
+	// 没有 Ack 并且没有超过 ackTimeout 的记录不能再次投递
+	if again, err := outbox.NextFinished(ctx, 10, 1<<62); err != nil || len(again) != 0 {
+		t.Fatalf("NextFinished before ack timeout want no record, got %v, %v", again, err)
+	}
+	if err := outbox.AckFinished(ctx, []string{records[0].RecordId, records[1].RecordId}); err != nil {
+		t.Fatalf("AckFinished error: %v", err)
+	}
+	// 超过 ackTimeout 以后，已经 Ack 的记录也不能再次投递
+	if again, err := outbox.NextFinished(ctx, 10, 0); err != nil || len(again) != 0 {
+		t.Fatalf("NextFinished after ack want no record, got %v, %v", again, err)
+	}
+}
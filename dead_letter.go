@@ -0,0 +1,197 @@
+package lighttaskscheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TaskAttempt 任务的一次执行记录
+type TaskAttempt struct {
+	Attempt      int32     // 第几次执行，对应 Task.TaskAttemptsTime
+	StartTime    time.Time // 本次执行的开始时间
+	EndTime      time.Time // 本次执行的结束时间
+	FailedReason string    // 本次执行失败的原因
+}
+
+// DeadLetterRecord 死信记录，任务重试次数达到 MaxFailedAttempts 以后仍然失败，会记录到死信存储
+type DeadLetterRecord struct {
+	Task         Task          // 失败时候的任务
+	Attempts     []TaskAttempt // 完整的执行记录
+	FailedReason string        // 最终失败的原因
+	DeadTime     time.Time     // 进入死信的时间
+}
+
+// DeadLetterFilter 死信查询过滤条件，零值的字段表示不过滤
+type DeadLetterFilter struct {
+	TaskIds        []string  // 指定任务 id
+	Since, Until   time.Time // 进入死信的时间范围 [Since, Until)
+	ReasonContains string    // 任意一次失败原因包含该字符串
+	Limit          int       // 最多返回的记录数
+}
+
+// Match 判断死信记录是否满足过滤条件，不处理 Limit，供死信存储实现复用
+func (f DeadLetterFilter) Match(record DeadLetterRecord) bool {
+	if len(f.TaskIds) > 0 {
+		found := false
+		for _, id := range f.TaskIds {
+			if id == record.Task.TaskId {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && record.DeadTime.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !record.DeadTime.Before(f.Until) {
+		return false
+	}
+	if f.ReasonContains != "" {
+		if strings.Contains(record.FailedReason, f.ReasonContains) {
+			return true
+		}
+		for _, attempt := range record.Attempts {
+			if strings.Contains(attempt.FailedReason, f.ReasonContains) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// DeadLetterStore 死信存储接口，框架提供了内存和 sql 两种实现，参考 deadletter 包
+type DeadLetterStore interface {
+	// Add 添加一条死信记录，相同任务 id 的记录覆盖
+	Add(ctx context.Context, record DeadLetterRecord) (err error)
+
+	// List 按照进入死信的时间顺序查询死信记录
+	List(ctx context.Context, filter DeadLetterFilter) (records []DeadLetterRecord, err error)
+
+	// Delete 删除死信记录
+	Delete(ctx context.Context, taskIds []string) (err error)
+}
+
+// attemptRecorder 记录执行中任务每一次失败的执行记录，任务最终失败的时候生成死信的执行历史
+type attemptRecorder struct {
+	lock     sync.Mutex
+	attempts map[string][]TaskAttempt // taskId -> []TaskAttempt
+}
+
+func (r *attemptRecorder) record(task *Task, reason error, now time.Time) {
+	attempt := TaskAttempt{
+		Attempt:   task.TaskAttemptsTime,
+		StartTime: task.TaskStartTime,
+		EndTime:   now,
+	}
+	if reason != nil {
+		attempt.FailedReason = reason.Error()
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.attempts == nil {
+		r.attempts = map[string][]TaskAttempt{}
+	}
+	r.attempts[task.TaskId] = append(r.attempts[task.TaskId], attempt)
+}
+
+func (r *attemptRecorder) take(taskId string) []TaskAttempt {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	attempts := r.attempts[taskId]
+	delete(r.attempts, taskId)
+	return attempts
+}
+
+var errDeadLetterDisabled = fmt.Errorf("dead letter store is not configured")
+
+// ListDeadLetters 查询死信记录
+func (s *TaskScheduler) ListDeadLetters(ctx context.Context, filter DeadLetterFilter) ([]DeadLetterRecord, error) {
+	store := s.Config().DeadLetterStore
+	if store == nil {
+		return nil, errDeadLetterDisabled
+	}
+	return store.List(ctx, filter)
+}
+
+// RedriveDeadLetters 把满足条件的死信任务重新加入等待队列，重试次数清零
+// modify 可以在重新加入队列前修改任务参数，为 nil 表示不修改，返回成功重新加入队列的任务数
+func (s *TaskScheduler) RedriveDeadLetters(ctx context.Context, filter DeadLetterFilter,
+	modify func(task *Task)) (count int, err error) {
+	store := s.Config().DeadLetterStore
+	if store == nil {
+		return 0, errDeadLetterDisabled
+	}
+	records, err := store.List(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	for _, record := range records {
+		if _, ok := record.Task.TaskItem.(json.RawMessage); ok {
+			// sql 死信存储没有配置 ItemDecoder 的时候 TaskItem 是序列化的数据，执行器无法识别，不能重新加入队列
+			return 0, fmt.Errorf("TaskItem of dead letter task %s is not decoded, "+
+				"dead letter store must be configured with an item decoder to redrive", record.Task.TaskId)
+		}
+	}
+	for _, record := range records {
+		task := record.Task
+		task.TaskStatus = TASK_STATUS_UNSTART
+		task.FailedReason = nil
+		task.TaskAttemptsTime = 0
+		task.TaskStartTime, task.TaskEnbTime = time.Time{}, time.Time{}
+		// 版本、检查点和心跳都是上一次执行的数据，重新加入队列以后从头执行
+		task.TaskVersion = 0
+		task.TaskCheckpoint = nil
+		task.TaskHeartbeatTime = time.Time{}
+		if modify != nil {
+			modify(&task)
+		}
+		s.deleteCheckpoint(ctx, &task)
+		if err := s.AddTask(ctx, task); err != nil {
+			return count, err
+		}
+		if err := store.Delete(ctx, []string{record.Task.TaskId}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// recordAttempt 记录任务的一次失败执行，只有配置了死信存储才记录
+func (s *TaskScheduler) recordAttempt(task *Task, reason error) {
+	if s.Config().DeadLetterStore != nil {
+		s.attempts.record(task, reason, s.clock.Now())
+	}
+}
+
+// exhausted 任务重试耗尽以后最终失败，转移到失败状态并且记录死信
+func (s *TaskScheduler) exhausted(ctx context.Context, task *Task, reason error) (*Task, error) {
+	store := s.Config().DeadLetterStore
+	if store == nil {
+		return s.failed(ctx, task, reason)
+	}
+	s.attempts.record(task, reason, s.clock.Now())
+	attempts := s.attempts.take(task.TaskId)
+	return s.failWith(ctx, task, reason, func(ctx context.Context, newTask *Task) {
+		record := DeadLetterRecord{
+			Task:     *newTask,
+			Attempts: attempts,
+			DeadTime: s.clock.Now(),
+		}
+		if reason != nil {
+			record.FailedReason = reason.Error()
+		}
+		if err := store.Add(ctx, record); err != nil {
+			log.Printf("add task %s to dead letter store error: %v\n", task.TaskId, err)
+		}
+	})
+}
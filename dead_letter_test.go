@@ -0,0 +1,125 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+	"github.com/memory-overflow/light-task-scheduler/deadletter"
+)
+
+// TestDeadLetter 只有重试次数耗尽的任务进入死信，启动失败和超时的任务直接失败
+func TestDeadLetter(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		script   actuatortest.Script
+		starts   int
+		attempts int // 死信中的执行记录数，0 表示没有进入死信
+	}{
+		{
+			name:     "retry exhausted",
+			script:   actuatortest.Script{Duration: time.Second, Err: errors.New("run error")},
+			starts:   3,
+			attempts: 3,
+		},
+		{
+			name:   "succeed after retry",
+			script: actuatortest.Script{Duration: time.Second, FailedAttempts: 2},
+			starts: 3,
+		},
+		{
+			name:   "start error",
+			script: actuatortest.Script{StartErr: errors.New("start error")},
+			starts: 1,
+		},
+		{
+			name:   "timeout",
+			script: actuatortest.Script{Duration: 2 * time.Hour},
+			starts: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store := deadletter.MakeMemoryStore()
+			h := makeHarness(t, tc.script, func(c *lighttaskscheduler.Config) {
+				c.MaxFailedAttempts = 2
+				c.DeadLetterStore = store
+			})
+			h.add(t, lighttaskscheduler.Task{TaskId: "task"})
+			h.schedule(t)
+			for i := 0; i < 4; i++ {
+				h.clock.Advance(time.Hour)
+				h.poll(t)
+			}
+			if starts := h.actuator.Starts("task"); starts != tc.starts {
+				t.Fatalf("Starts want %d, got %d", tc.starts, starts)
+			}
+			if _, ok := h.finished()["task"]; !ok {
+				t.Fatal("task is not finished")
+			}
+			records, err := h.sch.ListDeadLetters(context.Background(), lighttaskscheduler.DeadLetterFilter{})
+			if err != nil {
+				t.Fatalf("ListDeadLetters error: %v", err)
+			}
+			if tc.attempts == 0 {
+				if len(records) != 0 {
+					t.Fatalf("task is added to dead letter store: %+v", records)
+				}
+				return
+			}
+			if len(records) != 1 || len(records[0].Attempts) != tc.attempts {
+				t.Fatalf("dead letter want 1 record with %d attempts, got %+v", tc.attempts, records)
+			}
+		})
+	}
+}
+
+// TestRedriveDeadLetters 重新加入队列的任务清空上一次执行的版本、检查点和心跳
+func TestRedriveDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	store := deadletter.MakeMemoryStore()
+	h := makeHarness(t, actuatortest.Script{Duration: time.Second}, func(c *lighttaskscheduler.Config) {
+		c.DeadLetterStore = store
+	})
+	checkpointStore, ok := lighttaskscheduler.ContainerAs[lighttaskscheduler.CheckpointStore](h.container)
+	if !ok {
+		t.Fatal("container does not implement CheckpointStore")
+	}
+	task := lighttaskscheduler.Task{
+		TaskId:            "task",
+		TaskStatus:        lighttaskscheduler.TASK_STATUS_FAILED,
+		TaskAttemptsTime:  3,
+		TaskVersion:       100,
+		TaskCheckpoint:    []byte("checkpoint"),
+		TaskHeartbeatTime: h.clock.Now(),
+		TaskStartTime:     h.clock.Now(),
+		FailedReason:      errors.New("run error"),
+	}
+	if err := checkpointStore.SaveCheckpoint(ctx, &task, task.TaskCheckpoint); err != nil {
+		t.Fatalf("SaveCheckpoint error: %v", err)
+	}
+	if err := store.Add(ctx, lighttaskscheduler.DeadLetterRecord{Task: task, DeadTime: h.clock.Now()}); err != nil {
+		t.Fatalf("add dead letter error: %v", err)
+	}
+	count, err := h.sch.RedriveDeadLetters(ctx, lighttaskscheduler.DeadLetterFilter{}, nil)
+	if err != nil || count != 1 {
+		t.Fatalf("RedriveDeadLetters want 1, got %d, err: %v", count, err)
+	}
+	if records, _ := store.List(ctx, lighttaskscheduler.DeadLetterFilter{}); len(records) != 0 {
+		t.Fatalf("redriven dead letter is not deleted: %+v", records)
+	}
+	if checkpoint, _ := checkpointStore.GetCheckpoint(ctx, &task); checkpoint != nil {
+		t.Fatalf("checkpoint of redriven task is not deleted: %s", checkpoint)
+	}
+	tasks, err := h.container.GetWaitingTask(ctx, 1)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("GetWaitingTask want 1 task, got %d, err: %v", len(tasks), err)
+	}
+	redriven := tasks[0]
+	if redriven.TaskVersion != 1 || redriven.TaskCheckpoint != nil || !redriven.TaskHeartbeatTime.IsZero() ||
+		redriven.TaskAttemptsTime != 0 || !redriven.TaskStartTime.IsZero() || redriven.FailedReason != nil {
+		t.Fatalf("redriven task is not reset: %+v", redriven)
+	}
+}
@@ -0,0 +1,59 @@
+package deadletter
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+)
+
+// memoryStore 内存死信存储，不可持久化，多进程无法共享数据
+type memoryStore struct {
+	lock    sync.RWMutex
+	records map[string]lighttaskscheduler.DeadLetterRecord // taskId -> 死信记录
+}
+
+// MakeMemoryStore 构造内存死信存储
+func MakeMemoryStore() *memoryStore {
+	return &memoryStore{
+		records: map[string]lighttaskscheduler.DeadLetterRecord{},
+	}
+}
+
+// Add 添加死信记录
+func (m *memoryStore) Add(ctx context.Context, record lighttaskscheduler.DeadLetterRecord) (err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.records[record.Task.TaskId] = record
+	return nil
+}
+
+// List 查询死信记录
+func (m *memoryStore) List(ctx context.Context, filter lighttaskscheduler.DeadLetterFilter) (
+	records []lighttaskscheduler.DeadLetterRecord, err error) {
+	m.lock.RLock()
+	for _, record := range m.records {
+		if filter.Match(record) {
+			records = append(records, record)
+		}
+	}
+	m.lock.RUnlock()
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].DeadTime.Before(records[j].DeadTime)
+	})
+	if filter.Limit > 0 && len(records) > filter.Limit {
+		records = records[:filter.Limit]
+	}
+	return records, nil
+}
+
+// Delete 删除死信记录
+func (m *memoryStore) Delete(ctx context.Context, taskIds []string) (err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, id := range taskIds {
+		delete(m.records, id)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ItemDecoder 把 json 序列化的 TaskItem 反序列化成业务的任务对象
+type ItemDecoder func(data []byte) (item interface{}, err error)
+
+// deadLetterModel 死信数据表结构
+type deadLetterModel struct {
+	TaskId           string     `gorm:"primary_key;type:varchar(255)"`
+	TaskPriority     int        `gorm:"default:0"`
+	TaskItem         string     `gorm:"type:longtext"` // json 序列化的 TaskItem
+	TaskStartTime    *time.Time `gorm:"default:NULL"`
+	TaskEnbTime      *time.Time `gorm:"default:NULL"`
+	TaskAttemptsTime int32      `gorm:"default:0"`
+	Attempts         string     `gorm:"type:longtext"` // json 序列化的执行记录
+	FailedReason     string     `gorm:"type:varchar(4096);default:''"`
+	DeadTime         time.Time  `gorm:"index:dead_time_idx"`
+}
+
+// TableName 死信数据表名
+func (deadLetterModel) TableName() string {
+	return "dead_letter"
+}
+
+// sqlStore sql db 死信存储，可持久化，多进程可以共享数据
+type sqlStore struct {
+	db         *gorm.DB
+	decodeItem ItemDecoder
+}
+
+// MakeSQLStore 构造 sql 死信存储，自动 migrate 死信数据表
+// TaskItem 使用 json 序列化存储，decodeItem 用来反序列化成业务的任务对象，
+// 为 nil 的时候 TaskItem 返回 json.RawMessage，这时不能通过 RedriveDeadLetters 重新加入队列
+func MakeSQLStore(db *gorm.DB, decodeItem ItemDecoder) (*sqlStore, error) {
+	if err := db.AutoMigrate(&deadLetterModel{}); err != nil {
+		return nil, errors.New("migrate table failed: " + err.Error())
+	}
+	return &sqlStore{db: db, decodeItem: decodeItem}, nil
+}
+
+// Add 添加死信记录
+func (s *sqlStore) Add(ctx context.Context, record lighttaskscheduler.DeadLetterRecord) (err error) {
+	item, err := json.Marshal(record.Task.TaskItem)
+	if err != nil {
+		return fmt.Errorf("marshal TaskItem error: %v", err)
+	}
+	attempts, err := json.Marshal(record.Attempts)
+	if err != nil {
+		return fmt.Errorf("marshal attempts error: %v", err)
+	}
+	model := deadLetterModel{
+		TaskId:           record.Task.TaskId,
+		TaskPriority:     record.Task.TaskPriority,
+		TaskItem:         string(item),
+		TaskStartTime:    timePtr(record.Task.TaskStartTime),
+		TaskEnbTime:      timePtr(record.Task.TaskEnbTime),
+		TaskAttemptsTime: record.Task.TaskAttemptsTime,
+		Attempts:         string(attempts),
+		FailedReason:     record.FailedReason,
+		DeadTime:         record.DeadTime,
+	}
+	if err = s.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&model).Error; err != nil {
+		return fmt.Errorf("db create error: %v", err)
+	}
+	return nil
+}
+
+// List 查询死信记录
+func (s *sqlStore) List(ctx context.Context, filter lighttaskscheduler.DeadLetterFilter) (
+	records []lighttaskscheduler.DeadLetterRecord, err error) {
+	db := s.db.WithContext(ctx).Model(&deadLetterModel{})
+	if len(filter.TaskIds) > 0 {
+		db = db.Where("task_id in ?", filter.TaskIds)
+	}
+	if !filter.Since.IsZero() {
+		db = db.Where("dead_time >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		db = db.Where("dead_time < ?", filter.Until)
+	}
+	if filter.ReasonContains != "" {
+		like := "%" + filter.ReasonContains + "%"
+		db = db.Where("failed_reason like ? or attempts like ?", like, like)
+	}
+	if filter.Limit > 0 {
+		db = db.Limit(filter.Limit)
+	}
+	models := []deadLetterModel{}
+	if err = db.Order("dead_time asc").Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("db find error: %v", err)
+	}
+	for _, model := range models {
+		record, err := s.toRecord(model)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Delete 删除死信记录
+func (s *sqlStore) Delete(ctx context.Context, taskIds []string) (err error) {
+	if len(taskIds) == 0 {
+		return nil
+	}
+	if err = s.db.WithContext(ctx).Where("task_id in ?", taskIds).Delete(&deadLetterModel{}).Error; err != nil {
+		return fmt.Errorf("db delete error: %v", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) toRecord(model deadLetterModel) (record lighttaskscheduler.DeadLetterRecord, err error) {
+	var item interface{} = json.RawMessage(model.TaskItem)
+	if s.decodeItem != nil {
+		if item, err = s.decodeItem([]byte(model.TaskItem)); err != nil {
+			return record, fmt.Errorf("decode TaskItem of task %s error: %v", model.TaskId, err)
+		}
+	}
+	if err = json.Unmarshal([]byte(model.Attempts), &record.Attempts); err != nil {
+		return record, fmt.Errorf("unmarshal attempts of task %s error: %v", model.TaskId, err)
+	}
+	record.Task = lighttaskscheduler.Task{
+		TaskId:           model.TaskId,
+		TaskPriority:     model.TaskPriority,
+		TaskItem:         item,
+		TaskStatus:       lighttaskscheduler.TASK_STATUS_FAILED,
+		TaskAttemptsTime: model.TaskAttemptsTime,
+	}
+	if model.TaskStartTime != nil {
+		record.Task.TaskStartTime = *model.TaskStartTime
+	}
+	if model.TaskEnbTime != nil {
+		record.Task.TaskEnbTime = *model.TaskEnbTime
+	}
+	if model.FailedReason != "" {
+		record.Task.FailedReason = errors.New(model.FailedReason)
+	}
+	record.FailedReason = model.FailedReason
+	record.DeadTime = model.DeadTime
+	return record, nil
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,133 @@
+package lighttaskscheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DurationEstimator 任务执行时间的估计器，调度器在任务成功以后调用 Observe 学习，
+// Estimate 可以用于短作业优先的调度策略和等待中任务的预计时间
+type DurationEstimator interface {
+	// Observe 记录一个执行成功的任务，执行时间为 TaskEnbTime - TaskStartTime
+	Observe(task Task)
+
+	// Estimate 预计任务的执行时间，没有任何历史数据的时候返回 0
+	Estimate(task Task) time.Duration
+}
+
+// DurationStat 一类任务的执行时间统计
+type DurationStat struct {
+	Mean  time.Duration `json:"mean"`  // 执行时间的指数移动平均
+	Count int64         `json:"count"` // 学习过的任务数
+}
+
+// EstimatorState 执行时间估计器的状态，可以序列化以后持久化，重启以后通过 Restore 恢复
+type EstimatorState struct {
+	Keys   map[string]DurationStat `json:"keys"`   // 每一类任务的统计
+	Global DurationStat            `json:"global"` // 所有任务的统计，没有见过的任务类型使用该统计
+}
+
+// durationEstimator 按照任务类型分别学习执行时间的指数移动平均
+type durationEstimator struct {
+	keyFunc func(task Task) string
+	alpha   float64
+
+	lock   sync.RWMutex
+	keys   map[string]DurationStat
+	global DurationStat
+}
+
+// TaskTypeKey 默认的任务分类方式，按照 TaskItem 的类型分类
+func TaskTypeKey(task Task) string {
+	return fmt.Sprintf("%T", task.TaskItem)
+}
+
+// MakeDurationEstimator 构造执行时间估计器，keyFunc 决定任务的分类，为 nil 的时候使用 TaskTypeKey，
+// alpha 是指数移动平均的权重，取值 (0, 1]，越大越偏向最近的任务，为 0 的时候默认 0.2
+func MakeDurationEstimator(keyFunc func(task Task) string, alpha float64) (*durationEstimator, error) {
+	if alpha < 0 || alpha > 1 {
+		return nil, fmt.Errorf("alpha must be in (0, 1]")
+	}
+	if alpha == 0 {
+		alpha = 0.2
+	}
+	if keyFunc == nil {
+		keyFunc = TaskTypeKey
+	}
+	return &durationEstimator{keyFunc: keyFunc, alpha: alpha, keys: map[string]DurationStat{}}, nil
+}
+
+// update 把一次执行时间加入统计
+func (e *durationEstimator) update(stat DurationStat, d time.Duration) DurationStat {
+	if stat.Count == 0 {
+		stat.Mean = d
+	} else {
+		stat.Mean = time.Duration(e.alpha*float64(d) + (1-e.alpha)*float64(stat.Mean))
+	}
+	stat.Count++
+	return stat
+}
+
+// Observe 记录一个执行成功的任务
+func (e *durationEstimator) Observe(task Task) {
+	if task.TaskStartTime.IsZero() || task.TaskEnbTime.Before(task.TaskStartTime) {
+		return
+	}
+	d := task.TaskEnbTime.Sub(task.TaskStartTime)
+	key := e.keyFunc(task)
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.keys[key] = e.update(e.keys[key], d)
+	e.global = e.update(e.global, d)
+}
+
+// Estimate 预计任务的执行时间，没有见过的任务类型使用所有任务的统计
+func (e *durationEstimator) Estimate(task Task) time.Duration {
+	key := e.keyFunc(task)
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	if stat, ok := e.keys[key]; ok && stat.Count > 0 {
+		return stat.Mean
+	}
+	return e.global.Mean
+}
+
+// Snapshot 导出估计器的状态
+func (e *durationEstimator) Snapshot() EstimatorState {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	state := EstimatorState{Keys: make(map[string]DurationStat, len(e.keys)), Global: e.global}
+	for key, stat := range e.keys {
+		state.Keys[key] = stat
+	}
+	return state
+}
+
+// Restore 恢复估计器的状态，覆盖当前的统计
+func (e *durationEstimator) Restore(state EstimatorState) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.keys = make(map[string]DurationStat, len(state.Keys))
+	for key, stat := range state.Keys {
+		e.keys[key] = stat
+	}
+	e.global = state.Global
+}
+
+// Save 把估计器的状态以 json 格式写入 w
+func (e *durationEstimator) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(e.Snapshot())
+}
+
+// Load 从 json 格式的 r 恢复估计器的状态
+func (e *durationEstimator) Load(r io.Reader) error {
+	var state EstimatorState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return fmt.Errorf("decode estimator state error: %v", err)
+	}
+	e.Restore(state)
+	return nil
+}
@@ -0,0 +1,20 @@
+package lighttaskscheduler
+
+import "errors"
+
+// ErrTaskVersionConflict 任务版本冲突，任务在容器中的版本和转移状态时传入的 Task.TaskVersion 不一致，
+// 说明任务已经被其他调度流程（回调、轮询或者其他副本）转移过状态，本次状态转移被拒绝
+var ErrTaskVersionConflict = errors.New("task version conflict, task status may has been changed")
+
+// IsVersionConflict 判断错误是否是任务版本冲突
+func IsVersionConflict(err error) bool {
+	return errors.Is(err, ErrTaskVersionConflict)
+}
+
+// ErrTaskExists 添加的任务已经在容器中等待、运行或者导出，同一个任务 id 同时只能有一个未结束的任务
+var ErrTaskExists = errors.New("task already exists")
+
+// IsTaskExists 判断错误是否是重复添加任务
+func IsTaskExists(err error) bool {
+	return errors.Is(err, ErrTaskExists)
+}
@@ -19,15 +19,11 @@ type AddTask struct {
 	A, B      int32
 }
 
-func add(ctx context.Context, ftask *lighttaskscheduler.Task) (data interface{}, err error) {
-	task, ok := ftask.TaskItem.(AddTask)
-	if !ok {
-		return nil, fmt.Errorf("TaskItem not be set to AddTask")
-	}
+func add(ctx context.Context, ftask *lighttaskscheduler.Task, task AddTask) (data int32, err error) {
 	log.Printf("start run task %s, Attempts: %d\n", ftask.TaskId, ftask.TaskAttemptsTime)
 	// 模拟 25 % 的概率出错
 	if rand.Intn(4) == 0 {
-		return nil, fmt.Errorf("error test")
+		return 0, fmt.Errorf("error test")
 	}
 	// 模拟 25% 概率超时
 	time.Sleep(time.Duration(rand.Intn(4000))*time.Millisecond + 2*time.Second)
@@ -41,7 +37,7 @@ type callbackReceiver struct {
 // modePolling 默认模式，状态轮询模式
 func modePolling() *lighttaskscheduler.TaskScheduler {
 	container := memeorycontainer.MakeQueueContainer(10000, 100*time.Millisecond)
-	actuator, err := actuator.MakeFucntionActuator(add, nil)
+	actuator, err := actuator.MakeTypedFucntionActuator(add, nil)
 	if err != nil {
 		log.Fatal("make fucntionActuato error: ", err)
 	}
@@ -74,7 +70,7 @@ func (rec addCallbackReceiver) GetCallbackChannel(ctx context.Context) chan ligh
 func modeCallback() *lighttaskscheduler.TaskScheduler {
 	container := memeorycontainer.MakeQueueContainer(10000, 100*time.Millisecond)
 	taskChannel := make(chan lighttaskscheduler.Task, 10000)
-	actuator, err := actuator.MakeFucntionActuator(add, nil)
+	actuator, err := actuator.MakeTypedFucntionActuator(add, nil)
 	actuator.SetCallbackChannel(taskChannel)
 	if err != nil {
 		log.Fatal("make fucntionActuato error: ", err)
@@ -105,7 +101,7 @@ func modeCallback() *lighttaskscheduler.TaskScheduler {
 func modePollingCallback() *lighttaskscheduler.TaskScheduler {
 	container := memeorycontainer.MakeQueueContainer(10000, 100*time.Millisecond)
 	taskChannel := make(chan lighttaskscheduler.Task, 10000)
-	actuator, err := actuator.MakeFucntionActuator(add, nil)
+	actuator, err := actuator.MakeTypedFucntionActuator(add, nil)
 	actuator.SetCallbackChannel(taskChannel)
 	if err != nil {
 		log.Fatal("make fucntionActuato error: ", err)
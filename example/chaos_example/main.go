@@ -0,0 +1,205 @@
+// 在故障注入的任务容器、执行器、数据持久化和回调接收器上运行调度器，校验调度器的不变量：
+// 1. 添加成功的任务不会丢失，最终都会以成功或者失败结束，并且只结束一次
+// 2. 任务容器中运行中的任务数不会超过 TaskLimit
+// 校验失败的时候进程以非 0 退出，可以在 CI 中使用不同的种子运行：go run ./example/chaos_example -seed 42
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuator"
+	"github.com/memory-overflow/light-task-scheduler/chaos"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+)
+
+// memoryPersistencer 内存中的数据持久化
+type memoryPersistencer struct {
+	data sync.Map
+}
+
+func (p *memoryPersistencer) DataPersistence(ctx context.Context, task *lighttaskscheduler.Task,
+	data interface{}) (err error) {
+	p.data.Store(task.TaskId, data)
+	return nil
+}
+
+func (p *memoryPersistencer) GetPersistenceData(ctx context.Context, task *lighttaskscheduler.Task) (
+	data interface{}, err error) {
+	data, ok := p.data.Load(task.TaskId)
+	if !ok {
+		return nil, fmt.Errorf("not found data of task %s", task.TaskId)
+	}
+	return data, nil
+}
+
+func (p *memoryPersistencer) DeletePersistenceData(ctx context.Context, task *lighttaskscheduler.Task) (err error) {
+	p.data.Delete(task.TaskId)
+	return nil
+}
+
+type callbackReceiver struct {
+	taskChannel chan lighttaskscheduler.Task
+}
+
+func (rec callbackReceiver) GetCallbackChannel(ctx context.Context) chan lighttaskscheduler.Task {
+	return rec.taskChannel
+}
+
+// containerMethods 注入故障的容器方法
+var containerMethods = []string{
+	"AddTask", "GetRunningTask", "GetRunningTaskCount", "GetWaitingTask", "ToRunningStatus",
+	"ToExportStatus", "ToSuccessStatus", "ToFailedStatus", "UpdateRunningTaskStatus",
+}
+
+func must[T any](t T, err error) T {
+	if err != nil {
+		log.Fatal(err)
+	}
+	return t
+}
+
+func main() {
+	seed := flag.Int64("seed", time.Now().UnixNano(), "故障注入的随机种子")
+	taskCount := flag.Int("tasks", 200, "任务数")
+	taskLimit := flag.Int("limit", 5, "任务并发限制")
+	timeout := flag.Duration("timeout", time.Minute, "等待所有任务结束的最长时间")
+	flag.Parse()
+	log.Printf("chaos seed %d\n", *seed)
+
+	// 执行函数，10% 的概率失败
+	var runLock sync.Mutex
+	r := rand.New(rand.NewSource(*seed))
+	run := func(ctx context.Context, task *lighttaskscheduler.Task) (data interface{}, err error) {
+		runLock.Lock()
+		d := time.Duration(10+r.Intn(40)) * time.Millisecond
+		fail := r.Intn(10) == 0
+		runLock.Unlock()
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if fail {
+			return nil, fmt.Errorf("run task %s failed", task.TaskId)
+		}
+		return task.TaskId, nil
+	}
+	taskChannel := make(chan lighttaskscheduler.Task, 10000)
+	fucntionActuator := must(actuator.MakeFucntionActuator(run, nil))
+	fucntionActuator.SetCallbackChannel(taskChannel)
+	queueContainer := memeorycontainer.MakeQueueContainer(10000, 10*time.Millisecond)
+
+	// 容器所有方法 5% 的概率出错，执行器查询状态变慢，导出的过程中崩溃，回调丢失和重复
+	container := must(chaos.MakeContainer(queueContainer, chaos.Config{
+		Seed: *seed, ErrorRate: 0.05, LatencyRate: 0.1, Latency: 20 * time.Millisecond,
+		Methods: containerMethods,
+	}))
+	chaosActuator := must(chaos.MakeActuator(fucntionActuator, chaos.Config{
+		Seed: *seed + 1, ErrorRate: 0.05, LatencyRate: 0.3, Latency: 100 * time.Millisecond,
+	}))
+	persistencer := must(chaos.MakePersistencer(&memoryPersistencer{}, chaos.Config{
+		Seed: *seed + 2, ErrorRate: 0.05, LostResponseRate: 0.05,
+	}))
+	receiver := must(chaos.MakeCallbackReceiver(callbackReceiver{taskChannel: taskChannel}, chaos.Config{
+		Seed: *seed + 3, DropRate: 0.2, DuplicateRate: 0.2, LatencyRate: 0.1, Latency: 50 * time.Millisecond,
+	}))
+
+	sch := must(lighttaskscheduler.MakeScheduler(container, chaosActuator, persistencer,
+		lighttaskscheduler.Config{
+			TaskLimit:              int32(*taskLimit),
+			TaskTimeout:            5 * time.Second,
+			MaxFailedAttempts:      3,
+			SchedulingPollInterval: 10 * time.Millisecond,
+			StatePollInterval:      50 * time.Millisecond,
+			EnableStateCallback:    true,
+			CallbackReceiver:       receiver,
+			EnableFinshedTaskList:  true,
+		}))
+	defer sch.Close()
+
+	// 采样运行中的任务数，直接读取被包装的容器，不受故障注入影响
+	var maxRunning int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for ctx.Err() == nil {
+			if count, _ := queueContainer.GetRunningTaskCount(ctx); count > atomic.LoadInt32(&maxRunning) {
+				atomic.StoreInt32(&maxRunning, count)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	added := map[string]bool{}
+	for i := 0; i < *taskCount; i++ {
+		taskId := strconv.Itoa(i)
+		// 添加任务的错误会返回给调用方，调用方负责重试
+		var err error
+		for retry := 0; retry < 10; retry++ {
+			if err = sch.AddTask(context.Background(), lighttaskscheduler.Task{TaskId: taskId}); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Printf("add task %s error: %v\n", taskId, err)
+			continue
+		}
+		added[taskId] = true
+	}
+
+	violations := []string{}
+	finished := map[string]lighttaskscheduler.TaskStatus{}
+	deadline := time.After(*timeout)
+	for len(finished) < len(added) {
+		select {
+		case task := <-sch.FinshedTasks():
+			if status, ok := finished[task.TaskId]; ok {
+				violations = append(violations, fmt.Sprintf("task %s finished twice, %v and %v",
+					task.TaskId, status, task.TaskStatus))
+				continue
+			}
+			finished[task.TaskId] = task.TaskStatus
+		case <-deadline:
+			for taskId := range added {
+				if _, ok := finished[taskId]; !ok {
+					violations = append(violations, fmt.Sprintf("task %s lost", taskId))
+					finished[taskId] = lighttaskscheduler.TASK_STATUS_INVALID
+				}
+			}
+		}
+	}
+	cancel()
+	if max := atomic.LoadInt32(&maxRunning); max > int32(*taskLimit) {
+		violations = append(violations, fmt.Sprintf("running task count %d exceeds limit %d", max, *taskLimit))
+	}
+
+	success := 0
+	for _, status := range finished {
+		if status == lighttaskscheduler.TASK_STATUS_SUCCESS {
+			success++
+		}
+	}
+	log.Printf("tasks %d, success %d, failed %d, max running %d\n",
+		len(added), success, len(finished)-success, atomic.LoadInt32(&maxRunning))
+	log.Printf("container chaos %+v\n", container.Stats())
+	log.Printf("actuator chaos %+v\n", chaosActuator.Stats())
+	log.Printf("persistencer chaos %+v\n", persistencer.Stats())
+	log.Printf("callback chaos %+v\n", receiver.Stats())
+	for _, v := range violations {
+		log.Printf("violation: %s\n", v)
+	}
+	if len(violations) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,157 @@
+// 调度器压测，基于函数执行器和队列容器，按照配置的组合依次运行：
+// 1. 调度吞吐：添加大量任务，统计吞吐、调度延时和端到端延时的分位数，以及每个任务的内存分配
+// 2. 状态轮询：保持大量运行中的任务，统计一轮状态轮询的耗时、内存分配和 goroutine 峰值
+// 指定 -profile 目录以后，每个组合会输出 CPU 和内存的 pprof 文件，可以通过 go tool pprof 分析
+// go run ./example/loadtest_example -tasks 10000 -limits 10,100,1000 -running 1000,10000 -profile /tmp/lts
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/memory-overflow/light-task-scheduler/loadtest"
+)
+
+func parseInts(s string) (values []int) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatalf("invalid number %q: %v", v, err)
+		}
+		values = append(values, i)
+	}
+	return values
+}
+
+func parseDurations(s string) (values []time.Duration) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("invalid duration %q: %v", v, err)
+		}
+		values = append(values, d)
+	}
+	return values
+}
+
+// profile 执行 f，profileDir 不为空的时候输出 CPU 和内存的 pprof 文件
+func profile(profileDir, name string, f func() error) error {
+	if profileDir == "" {
+		return f()
+	}
+	cpu, err := os.Create(filepath.Join(profileDir, name+".cpu.pprof"))
+	if err != nil {
+		return err
+	}
+	defer cpu.Close()
+	if err := pprof.StartCPUProfile(cpu); err != nil {
+		return err
+	}
+	err = f()
+	pprof.StopCPUProfile()
+	if err != nil {
+		return err
+	}
+	heap, err := os.Create(filepath.Join(profileDir, name+".heap.pprof"))
+	if err != nil {
+		return err
+	}
+	defer heap.Close()
+	runtime.GC()
+	return pprof.Lookup("allocs").WriteTo(heap, 0)
+}
+
+func main() {
+	tasks := flag.Int("tasks", 10000, "调度吞吐压测的任务数，为 0 的时候跳过")
+	limits := flag.String("limits", "10,100,1000", "任务并发限制，逗号分隔")
+	durations := flag.String("durations", "0,10ms", "任务的执行时间，逗号分隔")
+	modes := flag.String("modes", "poll,callback,poll+callback", "任务状态维护模式，逗号分隔")
+	schedInterval := flag.Duration("sched-interval", 10*time.Millisecond, "调度轮询间隔")
+	stateInterval := flag.Duration("state-interval", 10*time.Millisecond, "状态轮询间隔")
+	running := flag.String("running", "1000,10000", "状态轮询压测的运行中任务数，逗号分隔，为空的时候跳过")
+	polls := flag.Int("polls", 50, "状态轮询压测测量的轮询次数")
+	profileDir := flag.String("profile", "", "输出 pprof 文件的目录，为空的时候不输出")
+	timeout := flag.Duration("timeout", 5*time.Minute, "每个组合的超时时间")
+	flag.Parse()
+	if *profileDir != "" {
+		if err := os.MkdirAll(*profileDir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	failed := false
+	run := func(name string, f func(ctx context.Context) (fmt.Stringer, error)) {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		err := profile(*profileDir, name, func() error {
+			report, err := f(ctx)
+			if err == nil {
+				fmt.Println(report)
+			}
+			return err
+		})
+		if err != nil {
+			log.Printf("%s error: %v\n", name, err)
+			failed = true
+		}
+	}
+
+	if *tasks > 0 {
+		fmt.Println("== dispatch ==")
+		for _, mode := range strings.Split(*modes, ",") {
+			for _, limit := range parseInts(*limits) {
+				for _, duration := range parseDurations(*durations) {
+					opts := loadtest.DispatchOptions{
+						Tasks:                  *tasks,
+						TaskLimit:              int32(limit),
+						TaskDuration:           duration,
+						SchedulingPollInterval: *schedInterval,
+						StatePollInterval:      *stateInterval,
+					}
+					switch strings.TrimSpace(mode) {
+					case "poll":
+					case "callback":
+						opts.Callback, opts.DisableStatePoll = true, true
+					case "poll+callback":
+						opts.Callback = true
+					default:
+						log.Fatalf("unknown mode %q", mode)
+					}
+					name := fmt.Sprintf("dispatch-%s-limit%d-%v", strings.ReplaceAll(mode, "+", "-"), limit, duration)
+					run(name, func(ctx context.Context) (fmt.Stringer, error) {
+						return loadtest.RunDispatch(ctx, opts)
+					})
+				}
+			}
+		}
+	}
+
+	if len(parseInts(*running)) > 0 {
+		fmt.Println("== poll ==")
+		for _, n := range parseInts(*running) {
+			opts := loadtest.PollOptions{RunningTasks: n, Polls: *polls}
+			run(fmt.Sprintf("poll-running%d", n), func(ctx context.Context) (fmt.Stringer, error) {
+				return loadtest.RunPoll(ctx, opts)
+			})
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
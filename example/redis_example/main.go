@@ -0,0 +1,139 @@
+// 多个调度器副本共享同一个 redis 任务容器，副本之间按照优先级分配任务，运行中任务总数受 TaskLimit 限制，
+// 其中一个副本在执行任务的过程中退出，租约过期以后它的任务由其他副本重新调度。
+// 默认使用进程内的 miniredis，可以通过 -addr 指定真实的 redis：go run ./example/redis_example -addr 127.0.0.1:6379
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuator"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+	"github.com/redis/go-redis/v9"
+)
+
+// SleepTask 模拟耗时的任务
+type SleepTask struct {
+	Duration time.Duration
+}
+
+func sleep(ctx context.Context, ftask *lighttaskscheduler.Task, task SleepTask) (string, error) {
+	select {
+	case <-time.After(task.Duration):
+		return ftask.TaskId, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func makeScheduler(client redis.UniversalClient, prefix string, limit int32) *lighttaskscheduler.TaskScheduler {
+	container := memeorycontainer.MakeRedisContainer(client, prefix, 100*time.Millisecond)
+	container.SetLeaseTTL(2 * time.Second)
+	container.SetItemFactory(func() interface{} { return &SleepTask{} })
+	act, err := actuator.MakeTypedFucntionActuator(sleep, nil)
+	if err != nil {
+		log.Fatal("make fucntionActuator error: ", err)
+	}
+	sch, err := lighttaskscheduler.MakeScheduler(container, act, nil, lighttaskscheduler.Config{
+		TaskLimit:              limit,
+		TaskTimeout:            10 * time.Second,
+		EnableFinshedTaskList:  true,
+		SchedulingPollInterval: 50 * time.Millisecond,
+		StatePollInterval:      50 * time.Millisecond,
+	})
+	if err != nil {
+		log.Fatal("make scheduler error: ", err)
+	}
+	return sch
+}
+
+func main() {
+	addr := flag.String("addr", "", "redis 地址，为空的时候使用进程内的 miniredis")
+	prefix := flag.String("prefix", "lts-example", "redis key 的前缀")
+	replicas := flag.Int("replicas", 3, "调度器副本数")
+	taskCount := flag.Int("tasks", 30, "任务数")
+	limit := flag.Int("limit", 4, "所有副本的任务并发限制")
+	flag.Parse()
+
+	if *addr == "" {
+		mr, err := miniredis.Run()
+		if err != nil {
+			log.Fatal("run miniredis error: ", err)
+		}
+		defer mr.Close()
+		*addr = mr.Addr()
+	}
+	client := redis.NewClient(&redis.Options{Addr: *addr})
+	defer client.Close()
+
+	ctx := context.Background()
+	schedulers := make([]*lighttaskscheduler.TaskScheduler, *replicas)
+	for i := range schedulers {
+		schedulers[i] = makeScheduler(client, *prefix, int32(*limit))
+	}
+	for i := 0; i < *taskCount; i++ {
+		task := lighttaskscheduler.Task{
+			TaskId:       fmt.Sprintf("task-%d", i),
+			TaskPriority: i % 3,
+			TaskItem:     SleepTask{Duration: 300 * time.Millisecond},
+		}
+		if err := schedulers[i%len(schedulers)].AddTask(ctx, task); err != nil {
+			log.Fatalf("add task %s error: %v", task.TaskId, err)
+		}
+	}
+
+	var lock sync.Mutex
+	finished := map[string]lighttaskscheduler.TaskStatus{}
+	var wg sync.WaitGroup
+	for i, sch := range schedulers {
+		i, sch := i, sch
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range sch.FinshedTasks() {
+				lock.Lock()
+				if _, ok := finished[task.TaskId]; ok {
+					log.Printf("task %s finished more than once\n", task.TaskId)
+				}
+				finished[task.TaskId] = task.TaskStatus
+				log.Printf("replica %d: task %s priority %d finished, status %v\n",
+					i, task.TaskId, task.TaskPriority, task.TaskStatus)
+				lock.Unlock()
+			}
+		}()
+	}
+
+	// 第一个副本运行一段时间以后退出，它运行中的任务在租约过期以后由其他副本重新调度
+	time.Sleep(500 * time.Millisecond)
+	log.Println("replica 0 exits")
+	schedulers[0].Close()
+
+	deadline := time.Now().Add(time.Minute)
+	for time.Now().Before(deadline) {
+		lock.Lock()
+		n := len(finished)
+		lock.Unlock()
+		if n >= *taskCount {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	for _, sch := range schedulers[1:] {
+		sch.Close()
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	log.Printf("%d/%d tasks finished\n", len(finished), *taskCount)
+	if len(finished) < *taskCount {
+		os.Exit(1)
+	}
+}
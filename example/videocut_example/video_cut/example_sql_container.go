@@ -148,6 +148,35 @@ func (e *videoCutSqlContainer) GetWaitingTask(ctx context.Context, limit int32)
 	return tasks, nil
 }
 
+// GetFinishedTask 获取结束时间早于 before 的已结束任务，用于清理过期任务
+func (e *videoCutSqlContainer) GetFinishedTask(ctx context.Context, status framework.TaskStatus,
+	before time.Time, limit int32) (tasks []framework.Task, err error) {
+	db := e.db
+	taskRecords := []VideoCutTask{}
+	if err = db.Where("status = ? and end_time < ?", status, before).
+		Order("end_time asc").
+		Limit(int(limit)).Find(&taskRecords).Error; err != nil {
+		err = fmt.Errorf("db find error: %v", err)
+		log.Println(err)
+		return nil, err
+	}
+	for _, taskRecord := range taskRecords {
+		task := framework.Task{
+			TaskId:     taskRecord.TaskId,
+			TaskItem:   taskRecord,
+			TaskStatus: taskRecord.Status,
+		}
+		if taskRecord.StartAt != nil {
+			task.TaskStartTime = *taskRecord.StartAt
+		}
+		if taskRecord.EndAt != nil {
+			task.TaskEnbTime = *taskRecord.EndAt
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
 // ToRunningStatus 转移到运行中的状态
 func (e *videoCutSqlContainer) ToRunningStatus(ctx context.Context, ftask *framework.Task) (
 	newTask *framework.Task, err error) {
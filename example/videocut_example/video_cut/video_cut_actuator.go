@@ -104,7 +104,6 @@ func (e *VideoCutActuator) GetAsyncTaskStatus(ctx context.Context, tasks []frame
 				status[index] = framework.AsyncTaskStatus{
 					TaskStatus:   framework.TASK_STATUS_FAILED,
 					FailedReason: errors.New("system error: TaskItem not be set to VideoCutTask"),
-					Progress:     float32(0.0),
 				}
 				return
 			}
@@ -118,7 +117,6 @@ func (e *VideoCutActuator) GetAsyncTaskStatus(ctx context.Context, tasks []frame
 				log.Printf("[fTaskId:%s, cutTaskId:%s]get task status: %v\n", tasks[index].TaskId, task.TaskId, err)
 				status[index] = framework.AsyncTaskStatus{
 					TaskStatus: framework.TASK_STATUS_RUNNING,
-					Progress:   float32(0.0),
 				} // 网络错误，当成继续执行
 				return
 			}
@@ -126,24 +124,21 @@ func (e *VideoCutActuator) GetAsyncTaskStatus(ctx context.Context, tasks []frame
 				status[index] = framework.AsyncTaskStatus{
 					TaskStatus:   framework.TASK_STATUS_FAILED,
 					FailedReason: fmt.Errorf("get task status failed: " + rsp.Reason),
-					Progress:     float32(0.0),
 				}
 				return
 			}
 			if rsp.Status == TASK_STATUS_RUNNING {
 				status[index] = framework.AsyncTaskStatus{
 					TaskStatus: framework.TASK_STATUS_RUNNING,
-					Progress:   float32(0.0),
 				}
 			} else if rsp.Status == TASK_STATUS_SUCCESS {
 				status[index] = framework.AsyncTaskStatus{
-					TaskStatus: framework.TASK_STATUS_SUCCESS,
-					Progress:   float32(100.0),
+					TaskStatus:   framework.TASK_STATUS_SUCCESS,
+					TaskProgress: framework.TaskProgress{Percent: 100},
 				}
 			} else if rsp.Status == TASK_STATUS_FAILED {
 				status[index] = framework.AsyncTaskStatus{
 					TaskStatus: framework.TASK_STATUS_FAILED,
-					Progress:   float32(0.0),
 				}
 			}
 		}(i)
@@ -0,0 +1,174 @@
+package fakeclock
+
+import (
+	"sync"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+)
+
+// fakeClock 手动推进的时钟，用于测试，时间只有调用 Advance 或者 Set 的时候才会变化，
+// 时间推进以后到期的 After 和 Ticker 会被触发
+type fakeClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters []*waiter
+	added   chan struct{} // 有新的等待者的通知
+}
+
+// waiter 等待时间到期的 After 或者 Ticker
+type waiter struct {
+	deadline time.Time
+	period   time.Duration // Ticker 的周期，After 为 0
+	c        chan time.Time
+	stopped  bool
+}
+
+// MakeFakeClock 构造从 now 开始的手动时钟
+func MakeFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{
+		now:   now,
+		added: make(chan struct{}, 1),
+	}
+}
+
+// Now 当前时间
+func (f *fakeClock) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.now
+}
+
+// After 时间推进 d 以后返回当前时间，d 小于等于 0 的时候立即返回
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	w := &waiter{deadline: f.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.c <- f.now
+		return w.c
+	}
+	f.addWaiter(w)
+	return w.c
+}
+
+// NewTicker 新建周期为 d 的定时器，和 time.Ticker 一样，消费不及时的时候会丢弃多余的触发
+func (f *fakeClock) NewTicker(d time.Duration) lighttaskscheduler.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	w := &waiter{deadline: f.now.Add(d), period: d, c: make(chan time.Time, 1)}
+	f.addWaiter(w)
+	return &fakeTicker{clock: f, waiter: w}
+}
+
+// Advance 把时间推进 d，并且触发所有到期的 After 和 Ticker
+func (f *fakeClock) Advance(d time.Duration) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.setLocked(f.now.Add(d))
+}
+
+// Set 把时间设置为 t，t 早于当前时间的时候忽略
+func (f *fakeClock) Set(t time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if t.Before(f.now) {
+		return
+	}
+	f.setLocked(t)
+}
+
+// WaiterCount 等待中的 After 和 Ticker 数量
+func (f *fakeClock) WaiterCount() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil 阻塞直到等待中的 After 和 Ticker 数量达到 n，
+// 用于在 Advance 之前确认被测试的 goroutine 已经开始等待时间
+func (f *fakeClock) BlockUntil(n int) {
+	for {
+		if f.WaiterCount() >= n {
+			return
+		}
+		<-f.added
+	}
+}
+
+func (f *fakeClock) addWaiter(w *waiter) {
+	f.waiters = append(f.waiters, w)
+	select {
+	case f.added <- struct{}{}:
+	default:
+	}
+}
+
+func (f *fakeClock) setLocked(t time.Time) {
+	f.now = t
+	waiters := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.stopped {
+			continue
+		}
+		if w.deadline.After(t) {
+			waiters = append(waiters, w)
+			continue
+		}
+		select {
+		case w.c <- t:
+		default:
+		}
+		if w.period > 0 {
+			// 跳过已经错过的周期
+			for !w.deadline.After(t) {
+				w.deadline = w.deadline.Add(w.period)
+			}
+			waiters = append(waiters, w)
+		}
+	}
+	f.waiters = waiters
+}
+
+// fakeTicker 手动时钟的定时器
+type fakeTicker struct {
+	clock  *fakeClock
+	waiter *waiter
+}
+
+// C 定时器的 channel
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.waiter.c
+}
+
+// Reset 修改定时器的周期，下一次触发为当前时间加 d
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	t.waiter.period = d
+	t.waiter.deadline = t.clock.now.Add(d)
+	if t.waiter.stopped {
+		t.waiter.stopped = false
+		t.clock.addWaiter(t.waiter)
+	}
+}
+
+// Stop 停止定时器
+func (t *fakeTicker) Stop() {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+	t.waiter.stopped = true
+	waiters := t.clock.waiters[:0]
+	for _, w := range t.clock.waiters {
+		if w != t.waiter {
+			waiters = append(waiters, w)
+		}
+	}
+	t.clock.waiters = waiters
+}
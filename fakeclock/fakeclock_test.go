@@ -0,0 +1,150 @@
+package fakeclock_test
+
+import (
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/fakeclock"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fired 返回 channel 中已经到达的时间，没有到达返回 false
+func fired(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestAfter(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		d       time.Duration
+		advance []time.Duration // 每次推进的时间
+		fired   []bool          // 每次推进以后是否触发，推进之前的检查在第一个
+	}{
+		{"zero", 0, nil, []bool{true}},
+		{"negative", -time.Second, nil, []bool{true}},
+		{"before deadline", time.Second, []time.Duration{time.Second - 1}, []bool{false, false}},
+		{"at deadline", time.Second, []time.Duration{time.Second}, []bool{false, true}},
+		{"step by step", time.Second, []time.Duration{time.Second / 2, time.Second / 2}, []bool{false, false, true}},
+		{"fire once", time.Second, []time.Duration{time.Second, time.Second}, []bool{false, true, false}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := fakeclock.MakeFakeClock(start)
+			c := clock.After(tc.d)
+			for i, want := range tc.fired {
+				if i > 0 {
+					clock.Advance(tc.advance[i-1])
+				}
+				if got, ok := fired(c); ok != want {
+					t.Fatalf("step %d: After fired want %v, got %v", i, want, ok)
+				} else if ok && got != clock.Now() {
+					t.Fatalf("step %d: After returns %v, want %v", i, got, clock.Now())
+				}
+			}
+			if n := clock.WaiterCount(); n != 0 && tc.fired[len(tc.fired)-1] {
+				t.Fatalf("fired After is still waiting, WaiterCount %d", n)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	clock := fakeclock.MakeFakeClock(start)
+	c := clock.After(time.Minute)
+	clock.Set(start.Add(-time.Hour))
+	if now := clock.Now(); !now.Equal(start) {
+		t.Fatalf("Set to the past changes time to %v", now)
+	}
+	clock.Set(start.Add(time.Hour))
+	if now := clock.Now(); !now.Equal(start.Add(time.Hour)) {
+		t.Fatalf("Now after Set want %v, got %v", start.Add(time.Hour), now)
+	}
+	if _, ok := fired(c); !ok {
+		t.Fatal("After is not fired by Set")
+	}
+}
+
+func TestTicker(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		actions func(ticker lighttaskscheduler.Ticker)
+		advance []time.Duration
+		fired   []bool
+	}{
+		{
+			name:    "period",
+			advance: []time.Duration{time.Second / 2, time.Second / 2, time.Second},
+			fired:   []bool{false, true, true},
+		},
+		{
+			// 消费不及时的时候丢弃错过的周期，下一次触发仍然按照原来的周期对齐
+			name:    "drop missed ticks",
+			advance: []time.Duration{3*time.Second + time.Second/2, time.Second / 2},
+			fired:   []bool{true, true},
+		},
+		{
+			name:    "stop",
+			actions: func(ticker lighttaskscheduler.Ticker) { ticker.Stop() },
+			advance: []time.Duration{time.Second, time.Second},
+			fired:   []bool{false, false},
+		},
+		{
+			name:    "reset",
+			actions: func(ticker lighttaskscheduler.Ticker) { ticker.Reset(3 * time.Second) },
+			advance: []time.Duration{time.Second, 2 * time.Second},
+			fired:   []bool{false, true},
+		},
+		{
+			name: "reset after stop",
+			actions: func(ticker lighttaskscheduler.Ticker) {
+				ticker.Stop()
+				ticker.Reset(2 * time.Second)
+			},
+			advance: []time.Duration{time.Second, time.Second},
+			fired:   []bool{false, true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := fakeclock.MakeFakeClock(start)
+			ticker := clock.NewTicker(time.Second)
+			defer ticker.Stop()
+			if tc.actions != nil {
+				tc.actions(ticker)
+			}
+			for i, d := range tc.advance {
+				clock.Advance(d)
+				if _, ok := fired(ticker.C()); ok != tc.fired[i] {
+					t.Fatalf("step %d: Ticker fired want %v, got %v", i, tc.fired[i], ok)
+				}
+			}
+		})
+	}
+}
+
+func TestBlockUntil(t *testing.T) {
+	clock := fakeclock.MakeFakeClock(start)
+	done := make(chan time.Time)
+	for i := 0; i < 2; i++ {
+		go func() {
+			done <- <-clock.After(time.Minute)
+		}()
+	}
+	clock.BlockUntil(2)
+	clock.Advance(time.Minute)
+	for i := 0; i < 2; i++ {
+		select {
+		case now := <-done:
+			if !now.Equal(start.Add(time.Minute)) {
+				t.Fatalf("After returns %v, want %v", now, start.Add(time.Minute))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("After is not fired after BlockUntil and Advance")
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+)
+
+// TestFinishedChannelFull 已完成任务的 channel 满了以后丢弃最早的任务，假时钟下不会阻塞
+func TestFinishedChannelFull(t *testing.T) {
+	const total = 10010
+	h := makeHarness(t, actuatortest.Script{StartErr: errors.New("start error")}, func(c *lighttaskscheduler.Config) {
+		c.TaskLimit = total
+	})
+	for i := 0; i < total; i++ {
+		h.add(t, lighttaskscheduler.Task{TaskId: fmt.Sprintf("task-%d", i)})
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := h.sch.ScheduleOnce(context.Background()); err != nil {
+			t.Errorf("ScheduleOnce error: %v", err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("ScheduleOnce is blocked by the full finished task channel")
+	}
+	finished := h.finished()
+	if len(finished) != 10000 {
+		t.Fatalf("finished tasks want 10000, got %d", len(finished))
+	}
+	if _, ok := finished[fmt.Sprintf("task-%d", total-1)]; !ok {
+		t.Fatal("latest finished task is dropped")
+	}
+}
@@ -0,0 +1,95 @@
+package lighttaskscheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// FinishedRecord 任务完成记录
+type FinishedRecord struct {
+	RecordId      string    // 记录的唯一 id，用于 Ack
+	Task          Task      // 完成的任务
+	FinishedTime  time.Time // 任务完成的时间
+	DeliveryCount int32     // 已经投递的次数
+}
+
+// FinishedOutbox 已完成任务的发件箱，任务容器可以选择实现该接口，
+// 实现以后任务完成记录保存在任务容器中，通过 NextFinished 和 Ack 保证每条完成记录至少投递一次
+type FinishedOutbox interface {
+	// PushFinished 保存一条任务完成记录
+	PushFinished(ctx context.Context, task Task) (err error)
+
+	// NextFinished 按照完成时间顺序获取最多 limit 条未确认的完成记录，
+	// 返回的记录在 ackTimeout 内不会被再次投递，超过 ackTimeout 没有 Ack 的记录会被重新投递
+	NextFinished(ctx context.Context, limit int32, ackTimeout time.Duration) (records []FinishedRecord, err error)
+
+	// AckFinished 确认完成记录已经处理，确认后的记录删除
+	AckFinished(ctx context.Context, recordIds []string) (err error)
+}
+
+const defaultFinishedAckTimeout = time.Minute
+
+// finishedOutbox 调度器配置的发件箱，没有开启返回 nil
+func (s *TaskScheduler) finishedOutbox() FinishedOutbox {
+	if !s.Config().EnableFinishedOutbox {
+		return nil
+	}
+	outbox, _ := ContainerAs[FinishedOutbox](s.Container)
+	return outbox
+}
+
+// NextFinished 获取未确认的任务完成记录，没有记录的时候阻塞等待，直到有新的完成记录或者 ctx 结束
+// 处理完成以后需要调用 Ack 确认，超过 Config.FinishedAckTimeout 没有确认的记录会被重新投递
+func (s *TaskScheduler) NextFinished(ctx context.Context, limit int32) ([]FinishedRecord, error) {
+	outbox := s.finishedOutbox()
+	if outbox == nil {
+		return nil, fmt.Errorf("finished outbox is not enabled")
+	}
+	ackTimeout := s.Config().FinishedAckTimeout
+	if ackTimeout <= 0 {
+		ackTimeout = defaultFinishedAckTimeout
+	}
+	// 多副本共享容器的时候，其他副本完成的任务不会通知到本副本，需要定期检查
+	ticker := s.clock.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		records, err := outbox.NextFinished(ctx, limit, ackTimeout)
+		if err != nil || len(records) > 0 {
+			return records, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-s.ctx.Done():
+			return nil, fmt.Errorf("scheduler closed")
+		case <-s.finishedNotify:
+		case <-ticker.C():
+		}
+	}
+}
+
+// Ack 确认任务完成记录已经处理
+func (s *TaskScheduler) Ack(ctx context.Context, recordIds ...string) error {
+	outbox := s.finishedOutbox()
+	if outbox == nil {
+		return fmt.Errorf("finished outbox is not enabled")
+	}
+	return outbox.AckFinished(ctx, recordIds)
+}
+
+// pushFinished 保存任务完成记录，并且通知等待中的 NextFinished
+func (s *TaskScheduler) pushFinished(ctx context.Context, task *Task) error {
+	outbox := s.finishedOutbox()
+	if outbox == nil {
+		return nil
+	}
+	if err := outbox.PushFinished(ctx, *task); err != nil {
+		return err
+	}
+	select {
+	case s.finishedNotify <- struct{}{}:
+	default:
+	}
+	return nil
+}
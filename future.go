@@ -0,0 +1,132 @@
+package lighttaskscheduler
+
+import (
+	"context"
+	"sync"
+)
+
+// TaskResult 任务最终的结果
+type TaskResult struct {
+	// 任务结束时候的状态，TaskStatus 为 TASK_STATUS_SUCCESS、TASK_STATUS_FAILED 或者 TASK_STATUS_STOPED
+	Task Task
+	// 任务成功并且配置了数据持久化的时候，通过 Persistencer.GetPersistenceData 查询的结果
+	Output interface{}
+	// 查询持久化结果的错误
+	OutputErr error
+}
+
+// Future 等待一个任务结束的结果，通过 TaskScheduler.Watch 获取
+// 不管任务是通过轮询还是回调结束，都会得到结果
+type Future struct {
+	taskId string
+	done   chan struct{}
+	result TaskResult
+}
+
+// TaskId 等待的任务 id
+func (f *Future) TaskId() string {
+	return f.taskId
+}
+
+// Done 任务结束以后关闭的 channel
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result 获取任务结果，需要在 Done 关闭以后调用
+func (f *Future) Result() TaskResult {
+	return f.result
+}
+
+// futureSet 所有等待中的 Future，taskId -> []*Future
+type futureSet struct {
+	lock    sync.Mutex
+	futures map[string][]*Future
+}
+
+func (fs *futureSet) add(f *Future) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	if fs.futures == nil {
+		fs.futures = map[string][]*Future{}
+	}
+	fs.futures[f.taskId] = append(fs.futures[f.taskId], f)
+}
+
+func (fs *futureSet) remove(f *Future) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	futures := fs.futures[f.taskId]
+	for i := range futures {
+		if futures[i] == f {
+			futures = append(futures[:i], futures[i+1:]...)
+			break
+		}
+	}
+	if len(futures) == 0 {
+		delete(fs.futures, f.taskId)
+	} else {
+		fs.futures[f.taskId] = futures
+	}
+}
+
+func (fs *futureSet) take(taskId string) []*Future {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+	futures := fs.futures[taskId]
+	delete(fs.futures, taskId)
+	return futures
+}
+
+// Watch 等待任务结束，必须在任务结束之前调用，否则无法得到结果，
+// 建议在 AddTask 之前调用，或者直接使用 SubmitAndWait
+func (s *TaskScheduler) Watch(taskId string) *Future {
+	f := &Future{
+		taskId: taskId,
+		done:   make(chan struct{}),
+	}
+	s.futures.add(f)
+	return f
+}
+
+// Unwatch 取消等待任务结束
+func (s *TaskScheduler) Unwatch(f *Future) {
+	s.futures.remove(f)
+}
+
+// Wait 阻塞等待任务结束，ctx 结束以后取消等待并且返回 ctx 的错误
+func (s *TaskScheduler) Wait(ctx context.Context, f *Future) (TaskResult, error) {
+	select {
+	case <-f.done:
+		return f.result, nil
+	case <-ctx.Done():
+		s.Unwatch(f)
+		return TaskResult{}, ctx.Err()
+	}
+}
+
+// SubmitAndWait 添加一个任务，并且阻塞等待任务结束，ctx 结束以后返回 ctx 的错误，任务仍然会继续调度
+func (s *TaskScheduler) SubmitAndWait(ctx context.Context, task Task) (TaskResult, error) {
+	f := s.Watch(task.TaskId)
+	if err := s.AddTask(ctx, task); err != nil {
+		s.Unwatch(f)
+		return TaskResult{}, err
+	}
+	return s.Wait(ctx, f)
+}
+
+// resolveFutures 任务结束，通知所有等待的 Future
+func (s *TaskScheduler) resolveFutures(ctx context.Context, task *Task) {
+	futures := s.futures.take(task.TaskId)
+	if len(futures) == 0 {
+		return
+	}
+	result := TaskResult{Task: *task}
+	if task.TaskStatus == TASK_STATUS_SUCCESS && s.Persistencer != nil {
+		result.Output, result.OutputErr = s.Persistencer.GetPersistenceData(ctx, task)
+	}
+	for _, f := range futures {
+		f.result = result
+		close(f.done)
+	}
+}
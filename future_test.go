@@ -0,0 +1,155 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+)
+
+// mapPersistencer 测试用的数据持久化，结果保存在内存中
+type mapPersistencer struct {
+	data sync.Map
+}
+
+func (p *mapPersistencer) DataPersistence(ctx context.Context, task *lighttaskscheduler.Task, data interface{}) error {
+	p.data.Store(task.TaskId, data)
+	return nil
+}
+
+func (p *mapPersistencer) GetPersistenceData(ctx context.Context, task *lighttaskscheduler.Task) (interface{}, error) {
+	data, ok := p.data.Load(task.TaskId)
+	if !ok {
+		return nil, fmt.Errorf("not found data for task %s", task.TaskId)
+	}
+	return data, nil
+}
+
+func (p *mapPersistencer) DeletePersistenceData(ctx context.Context, task *lighttaskscheduler.Task) error {
+	p.data.Delete(task.TaskId)
+	return nil
+}
+
+// resolved 返回已经结束的 Future 的结果
+func resolved(t *testing.T, f *lighttaskscheduler.Future) lighttaskscheduler.TaskResult {
+	t.Helper()
+	select {
+	case <-f.Done():
+		return f.Result()
+	default:
+		t.Fatalf("future of task %s is not resolved", f.TaskId())
+		return lighttaskscheduler.TaskResult{}
+	}
+}
+
+// TestFutureResolve 任务成功、失败、启动失败和停止的时候 Future 都会得到结果，成功的任务可以得到持久化的结果
+func TestFutureResolve(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		script       actuatortest.Script
+		persistencer lighttaskscheduler.TaskdataPersistencer
+		stop         bool
+		status       lighttaskscheduler.TaskStatus
+		output       interface{}
+	}{
+		{
+			name:         "success with output",
+			script:       actuatortest.Script{Duration: time.Second, Output: "output"},
+			persistencer: &mapPersistencer{},
+			status:       lighttaskscheduler.TASK_STATUS_SUCCESS,
+			output:       "output",
+		},
+		{
+			name:   "success without persistencer",
+			script: actuatortest.Script{Duration: time.Second, Output: "output"},
+			status: lighttaskscheduler.TASK_STATUS_SUCCESS,
+		},
+		{
+			name:   "failed",
+			script: actuatortest.Script{Duration: time.Second, Err: errors.New("run error")},
+			status: lighttaskscheduler.TASK_STATUS_FAILED,
+		},
+		{
+			name:   "start error",
+			script: actuatortest.Script{StartErr: errors.New("start error")},
+			status: lighttaskscheduler.TASK_STATUS_FAILED,
+		},
+		{
+			name:   "stopped",
+			script: actuatortest.Script{Duration: time.Hour},
+			stop:   true,
+			status: lighttaskscheduler.TASK_STATUS_STOPED,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := makeHarnessPersistent(t, memeorycontainer.MakeQueueContainer(16, time.Millisecond),
+				tc.persistencer, tc.script, nil)
+			futures := []*lighttaskscheduler.Future{h.sch.Watch("task"), h.sch.Watch("task")}
+			h.add(t, lighttaskscheduler.Task{TaskId: "task"})
+			h.schedule(t)
+			if tc.stop {
+				if err := h.sch.StopTask(context.Background(), &lighttaskscheduler.Task{TaskId: "task"}); err != nil {
+					t.Fatalf("StopTask error: %v", err)
+				}
+			}
+			h.clock.Advance(time.Second)
+			h.poll(t)
+			// 同一个任务的所有 Future 得到相同的结果
+			for _, f := range futures {
+				result := resolved(t, f)
+				if result.Task.TaskId != "task" || result.Task.TaskStatus != tc.status {
+					t.Fatalf("result want task %v, got %s %v", tc.status, result.Task.TaskId, result.Task.TaskStatus)
+				}
+				if result.Output != tc.output || result.OutputErr != nil {
+					t.Fatalf("result output want %v, got %v, err: %v", tc.output, result.Output, result.OutputErr)
+				}
+				if tc.status == lighttaskscheduler.TASK_STATUS_FAILED && result.Task.FailedReason == nil {
+					t.Fatal("failed result has no FailedReason")
+				}
+			}
+		})
+	}
+}
+
+// TestFutureUnwatch 取消等待的 Future 不会得到结果，ctx 结束的时候 Wait 返回 ctx 的错误
+func TestFutureUnwatch(t *testing.T) {
+	h := makeHarness(t, actuatortest.Script{Duration: time.Second}, nil)
+	unwatched, watched := h.sch.Watch("task"), h.sch.Watch("task")
+	h.sch.Unwatch(unwatched)
+	canceled := h.sch.Watch("task")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.sch.Wait(ctx, canceled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait with canceled ctx want context.Canceled, got %v", err)
+	}
+	h.add(t, lighttaskscheduler.Task{TaskId: "task"})
+	h.schedule(t)
+	h.clock.Advance(time.Second)
+	h.poll(t)
+	if result := resolved(t, watched); result.Task.TaskStatus != lighttaskscheduler.TASK_STATUS_SUCCESS {
+		t.Fatalf("result want SUCCESS, got %v", result.Task.TaskStatus)
+	}
+	for _, f := range []*lighttaskscheduler.Future{unwatched, canceled} {
+		select {
+		case <-f.Done():
+			t.Fatal("unwatched future is resolved")
+		default:
+		}
+	}
+}
+
+// TestSubmitAndWaitAddError 添加任务失败的时候 SubmitAndWait 直接返回错误
+func TestSubmitAndWaitAddError(t *testing.T) {
+	h := makeHarness(t, actuatortest.Script{Duration: time.Second}, nil)
+	h.add(t, lighttaskscheduler.Task{TaskId: "task"})
+	_, err := h.sch.SubmitAndWait(context.Background(), lighttaskscheduler.Task{TaskId: "task"})
+	if !lighttaskscheduler.IsTaskExists(err) {
+		t.Fatalf("SubmitAndWait duplicate task want ErrTaskExists, got %v", err)
+	}
+}
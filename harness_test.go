@@ -0,0 +1,129 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+	"github.com/memory-overflow/light-task-scheduler/fakeclock"
+)
+
+// fakeActuator actuatortest 假执行器的方法集合
+type fakeActuator interface {
+	lighttaskscheduler.TaskActuator
+	SetClock(clock lighttaskscheduler.Clock)
+	SetScript(taskId string, script actuatortest.Script)
+	SetCallbackChannel(callbackChannel chan lighttaskscheduler.Task)
+	DeliverCallbacks(ctx context.Context) (count int, err error)
+	Starts(taskId string) int
+	Stops(taskId string) int
+	StartCheckpoints(taskId string) [][]byte
+}
+
+// harness 手动模式的调度器，使用假时钟和假执行器，每一步调度都由测试驱动，结果是确定的
+type harness struct {
+	sch   *lighttaskscheduler.TaskScheduler
+	clock interface {
+		lighttaskscheduler.Clock
+		Advance(d time.Duration)
+	}
+	actuator  fakeActuator
+	container lighttaskscheduler.TaskContainer
+}
+
+// makeHarness 构造手动模式的调度器，没有配置脚本的任务按照 script 执行，update 不为 nil 的时候用来修改默认配置
+func makeHarness(t *testing.T, script actuatortest.Script, update func(c *lighttaskscheduler.Config)) *harness {
+	t.Helper()
+	return makeHarnessWith(t, memeorycontainer.MakeQueueContainer(16, time.Millisecond), script, update)
+}
+
+// makeHarnessWith 使用指定的任务容器构造手动模式的调度器
+func makeHarnessWith(t *testing.T, container lighttaskscheduler.TaskContainer, script actuatortest.Script,
+	update func(c *lighttaskscheduler.Config)) *harness {
+	t.Helper()
+	return makeHarnessPersistent(t, container, nil, script, update)
+}
+
+// makeHarnessPersistent 使用指定的任务容器和数据持久化构造手动模式的调度器
+func makeHarnessPersistent(t *testing.T, container lighttaskscheduler.TaskContainer,
+	persistencer lighttaskscheduler.TaskdataPersistencer, script actuatortest.Script,
+	update func(c *lighttaskscheduler.Config)) *harness {
+	t.Helper()
+	clock := fakeclock.MakeFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	actuator := actuatortest.MakeFakeActuator(script)
+	actuator.SetClock(clock)
+	if c, ok := container.(interface {
+		SetClock(clock lighttaskscheduler.Clock)
+	}); ok {
+		// 任务的开始时间由容器设置，需要和调度器使用同一个时钟
+		c.SetClock(clock)
+	}
+	config := lighttaskscheduler.Config{
+		TaskLimit:             2,
+		TaskTimeout:           time.Hour,
+		Clock:                 clock,
+		ManualStep:            true,
+		EnableFinshedTaskList: true,
+	}
+	if update != nil {
+		update(&config)
+	}
+	sch, err := lighttaskscheduler.MakeScheduler(container, actuator, persistencer, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sch.Close)
+	return &harness{sch: sch, clock: clock, actuator: actuator, container: container}
+}
+
+func (h *harness) add(t *testing.T, tasks ...lighttaskscheduler.Task) {
+	t.Helper()
+	for _, task := range tasks {
+		if err := h.sch.AddTask(context.Background(), task); err != nil {
+			t.Fatalf("AddTask %s error: %v", task.TaskId, err)
+		}
+	}
+}
+
+func (h *harness) schedule(t *testing.T) lighttaskscheduler.StepReport {
+	t.Helper()
+	report, err := h.sch.ScheduleOnce(context.Background())
+	if err != nil {
+		t.Fatalf("ScheduleOnce error: %v", err)
+	}
+	return report
+}
+
+func (h *harness) poll(t *testing.T) lighttaskscheduler.StepReport {
+	t.Helper()
+	report, err := h.sch.PollOnce(context.Background())
+	if err != nil {
+		t.Fatalf("PollOnce error: %v", err)
+	}
+	return report
+}
+
+// finished 取出所有已经结束的任务，taskId -> 任务
+func (h *harness) finished() map[string]*lighttaskscheduler.Task {
+	tasks := map[string]*lighttaskscheduler.Task{}
+	for {
+		select {
+		case task := <-h.sch.FinshedTasks():
+			tasks[task.TaskId] = task
+		default:
+			return tasks
+		}
+	}
+}
+
+func (h *harness) running(t *testing.T) int32 {
+	t.Helper()
+	count, err := h.container.GetRunningTaskCount(context.Background())
+	if err != nil {
+		t.Fatalf("GetRunningTaskCount error: %v", err)
+	}
+	return count
+}
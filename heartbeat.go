@@ -0,0 +1,35 @@
+package lighttaskscheduler
+
+import "time"
+
+// heartbeat 记录任务的心跳时间，只保留最新的心跳，只有开启心跳检测才记录，零值表示没有心跳
+func (s *TaskScheduler) heartbeat(task *Task, t time.Time) {
+	if s.Config().HeartbeatTimeout <= 0 || t.IsZero() {
+		return
+	}
+	if v, ok := s.heartbeats.Load(task.TaskId); ok && v.(time.Time).After(t) {
+		return
+	}
+	s.heartbeats.Store(task.TaskId, t)
+}
+
+// resetHeartbeat 任务开始一次新的执行，清除之前执行的心跳，收到这次执行的第一次心跳以后才开始心跳检测
+func (s *TaskScheduler) resetHeartbeat(task *Task) {
+	s.heartbeats.Delete(task.TaskId)
+}
+
+// lost 判断运行中的任务心跳是否已经超时，没有收到过心跳的任务不做判断，避免不上报心跳的执行器的任务被误判为丢失
+func (s *TaskScheduler) lost(task *Task, config Config) bool {
+	if config.HeartbeatTimeout <= 0 {
+		return false
+	}
+	v, ok := s.heartbeats.Load(task.TaskId)
+	if !ok {
+		return false
+	}
+	if v.(time.Time).Add(config.HeartbeatTimeout).Before(s.clock.Now()) {
+		s.heartbeats.Delete(task.TaskId)
+		return true
+	}
+	return false
+}
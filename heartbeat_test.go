@@ -0,0 +1,80 @@
+package lighttaskscheduler_test
+
+import (
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+)
+
+// TestHeartbeatLoss 心跳超时的任务停止以后按照失败重试，心跳正常或者没有开启心跳检测的任务不受影响
+func TestHeartbeatLoss(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		script   actuatortest.Script
+		timeout  time.Duration // HeartbeatTimeout
+		attempts int32         // MaxFailedAttempts
+		status   lighttaskscheduler.TaskStatus
+		starts   int
+		stops    int
+	}{
+		{
+			name:    "heartbeat keeps",
+			script:  actuatortest.Script{Duration: 10 * time.Minute},
+			timeout: 30 * time.Second,
+			status:  lighttaskscheduler.TASK_STATUS_SUCCESS,
+			starts:  1,
+		},
+		{
+			name:    "heartbeat lost",
+			script:  actuatortest.Script{Duration: 10 * time.Minute, HeartbeatFor: time.Minute},
+			timeout: 30 * time.Second,
+			status:  lighttaskscheduler.TASK_STATUS_FAILED,
+			starts:  1,
+			stops:   1,
+		},
+		{
+			name:     "heartbeat lost with retry",
+			script:   actuatortest.Script{Duration: 10 * time.Minute, HeartbeatFor: time.Minute},
+			timeout:  30 * time.Second,
+			attempts: 1,
+			status:   lighttaskscheduler.TASK_STATUS_FAILED,
+			starts:   2,
+			stops:    2,
+		},
+		{
+			name:   "heartbeat check disabled",
+			script: actuatortest.Script{Duration: 10 * time.Minute, HeartbeatFor: time.Minute},
+			status: lighttaskscheduler.TASK_STATUS_SUCCESS,
+			starts: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := makeHarness(t, tc.script, func(c *lighttaskscheduler.Config) {
+				c.HeartbeatTimeout = tc.timeout
+				c.MaxFailedAttempts = tc.attempts
+			})
+			h.add(t, lighttaskscheduler.Task{TaskId: "task"})
+			var finished *lighttaskscheduler.Task
+			for i := 0; i < 150 && finished == nil; i++ {
+				h.schedule(t)
+				h.clock.Advance(10 * time.Second)
+				h.poll(t)
+				finished = h.finished()["task"]
+			}
+			if finished == nil {
+				t.Fatal("task is not finished")
+			}
+			if finished.TaskStatus != tc.status {
+				t.Fatalf("task status want %v, got %v, reason: %v", tc.status, finished.TaskStatus, finished.FailedReason)
+			}
+			if starts, stops := h.actuator.Starts("task"), h.actuator.Stops("task"); starts != tc.starts || stops != tc.stops {
+				t.Fatalf("Starts and Stops want %d, %d, got %d, %d", tc.starts, tc.stops, starts, stops)
+			}
+			if running := h.running(t); running != 0 {
+				t.Fatalf("running tasks want 0, got %d", running)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package lighttaskscheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// TaskEvent 任务的一次状态变化记录
+type TaskEvent struct {
+	TaskId   string     // 任务 id
+	Status   TaskStatus // 变化以后的状态，RUNNING 到 RUNNING 表示任务失败以后重试
+	Attempt  int32      // 变化时任务的执行次数，对应 Task.TaskAttemptsTime
+	Reason   string     // 任务失败或者重试的原因
+	Operator string     // 操作人，通过 WithOperator 设置，调度器自动触发的状态变化为空
+	Time     time.Time  // 状态变化的时间
+}
+
+// HistoryStore 任务状态变化历史存储，框架提供了内存和 sql 两种实现，参考 history 包
+type HistoryStore interface {
+	// Append 追加一条状态变化记录
+	Append(ctx context.Context, event TaskEvent) (err error)
+
+	// List 按照时间顺序查询任务的所有状态变化记录
+	List(ctx context.Context, taskId string) (events []TaskEvent, err error)
+
+	// Delete 删除任务的所有状态变化记录
+	Delete(ctx context.Context, taskIds []string) (err error)
+}
+
+// HistoryRetention 历史记录保留策略，零值的字段表示不限制
+type HistoryRetention struct {
+	MaxEventsPerTask int           // 每个任务最多保留的记录数，超过以后删除最早的记录
+	MaxAge           time.Duration // 记录最长保留时间
+}
+
+type operatorKey struct{}
+
+// WithOperator 在 ctx 中设置操作人，StopTask、AddTask 等用户触发的状态变化会记录到历史中
+func WithOperator(ctx context.Context, operator string) context.Context {
+	return context.WithValue(ctx, operatorKey{}, operator)
+}
+
+// OperatorFrom 获取 ctx 中设置的操作人
+func OperatorFrom(ctx context.Context) string {
+	operator, _ := ctx.Value(operatorKey{}).(string)
+	return operator
+}
+
+// TaskHistory 查询任务的状态变化历史
+func (s *TaskScheduler) TaskHistory(ctx context.Context, taskId string) ([]TaskEvent, error) {
+	store := s.Config().HistoryStore
+	if store == nil {
+		return nil, fmt.Errorf("history store is not configured")
+	}
+	return store.List(ctx, taskId)
+}
+
+// recordHistory 记录任务状态变化到历史存储和手动模式的单步结果，都没有的时候忽略
+func (s *TaskScheduler) recordHistory(ctx context.Context, task *Task, status TaskStatus, reason error) {
+	store := s.Config().HistoryStore
+	report := stepReportFrom(ctx)
+	if store == nil && report == nil {
+		return
+	}
+	event := TaskEvent{
+		TaskId:   task.TaskId,
+		Status:   status,
+		Attempt:  task.TaskAttemptsTime,
+		Operator: OperatorFrom(ctx),
+		Time:     s.clock.Now(),
+	}
+	if reason != nil {
+		event.Reason = reason.Error()
+	}
+	if report != nil {
+		report.addEvent(event)
+	}
+	if store == nil {
+		return
+	}
+	if err := store.Append(ctx, event); err != nil {
+		log.Printf("append history of task %s error: %v\n", task.TaskId, err)
+	}
+}
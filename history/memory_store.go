@@ -0,0 +1,93 @@
+package history
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+)
+
+// memoryStore 内存历史存储，不可持久化，多进程无法共享数据
+type memoryStore struct {
+	retention lighttaskscheduler.HistoryRetention
+	lock      sync.RWMutex
+	events    map[string][]lighttaskscheduler.TaskEvent // taskId -> 按照时间顺序的状态变化记录
+	lastSweep time.Time
+	clock     lighttaskscheduler.Clock
+}
+
+// MakeMemoryStore 构造内存历史存储，retention 配置历史记录的保留策略
+func MakeMemoryStore(retention lighttaskscheduler.HistoryRetention) *memoryStore {
+	return &memoryStore{
+		retention: retention,
+		events:    map[string][]lighttaskscheduler.TaskEvent{},
+		lastSweep: lighttaskscheduler.RealClock.Now(),
+		clock:     lighttaskscheduler.RealClock,
+	}
+}
+
+// SetClock 设置历史存储计算记录过期使用的时钟，需要和调度器的 Config.Clock 一致
+func (m *memoryStore) SetClock(clock lighttaskscheduler.Clock) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.clock = lighttaskscheduler.ClockOrReal(clock)
+	m.lastSweep = m.clock.Now()
+}
+
+// Append 追加状态变化记录
+func (m *memoryStore) Append(ctx context.Context, event lighttaskscheduler.TaskEvent) (err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	events := append(m.events[event.TaskId], event)
+	if limit := m.retention.MaxEventsPerTask; limit > 0 && len(events) > limit {
+		events = append([]lighttaskscheduler.TaskEvent{}, events[len(events)-limit:]...)
+	}
+	m.events[event.TaskId] = events
+	if now := m.clock.Now(); m.retention.MaxAge > 0 && now.Sub(m.lastSweep) > time.Minute {
+		// 最多每分钟清理一次过期的记录
+		m.sweep(now.Add(-m.retention.MaxAge))
+		m.lastSweep = now
+	}
+	return nil
+}
+
+// List 查询任务的状态变化记录
+func (m *memoryStore) List(ctx context.Context, taskId string) (
+	events []lighttaskscheduler.TaskEvent, err error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	now := m.clock.Now()
+	for _, event := range m.events[taskId] {
+		if m.retention.MaxAge > 0 && now.Sub(event.Time) > m.retention.MaxAge {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// Delete 删除任务的状态变化记录
+func (m *memoryStore) Delete(ctx context.Context, taskIds []string) (err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, id := range taskIds {
+		delete(m.events, id)
+	}
+	return nil
+}
+
+// sweep 删除 before 之前的记录，需要持有写锁
+func (m *memoryStore) sweep(before time.Time) {
+	for id, events := range m.events {
+		i := 0
+		for i < len(events) && events[i].Time.Before(before) {
+			i++
+		}
+		if i == len(events) {
+			delete(m.events, id)
+		} else if i > 0 {
+			m.events[id] = append([]lighttaskscheduler.TaskEvent{}, events[i:]...)
+		}
+	}
+}
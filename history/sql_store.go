@@ -0,0 +1,140 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"gorm.io/gorm"
+)
+
+// historyModel 历史数据表结构
+type historyModel struct {
+	Id         int64     `gorm:"primary_key;auto_increment"`
+	TaskId     string    `gorm:"type:varchar(255);index:task_id_idx"`
+	TaskStatus int32     `gorm:"default:0"`
+	Attempt    int32     `gorm:"default:0"`
+	Reason     string    `gorm:"type:varchar(4096);default:''"`
+	Operator   string    `gorm:"type:varchar(255);default:''"`
+	EventTime  time.Time `gorm:"index:event_time_idx"`
+}
+
+// TableName 历史数据表名
+func (historyModel) TableName() string {
+	return "task_history"
+}
+
+// sqlStore sql db 历史存储，可持久化，多进程可以共享数据
+type sqlStore struct {
+	db        *gorm.DB
+	retention lighttaskscheduler.HistoryRetention
+
+	lock      sync.Mutex
+	lastSweep time.Time
+	clock     lighttaskscheduler.Clock
+}
+
+// MakeSQLStore 构造 sql 历史存储，自动 migrate 历史数据表，retention 配置历史记录的保留策略
+func MakeSQLStore(db *gorm.DB, retention lighttaskscheduler.HistoryRetention) (*sqlStore, error) {
+	if err := db.AutoMigrate(&historyModel{}); err != nil {
+		return nil, errors.New("migrate table failed: " + err.Error())
+	}
+	return &sqlStore{db: db, retention: retention, lastSweep: lighttaskscheduler.RealClock.Now(),
+		clock: lighttaskscheduler.RealClock}, nil
+}
+
+// SetClock 设置历史存储计算记录过期使用的时钟，需要和调度器的 Config.Clock 一致
+func (s *sqlStore) SetClock(clock lighttaskscheduler.Clock) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.clock = lighttaskscheduler.ClockOrReal(clock)
+	s.lastSweep = s.clock.Now()
+}
+
+// Append 追加状态变化记录
+func (s *sqlStore) Append(ctx context.Context, event lighttaskscheduler.TaskEvent) (err error) {
+	model := historyModel{
+		TaskId:     event.TaskId,
+		TaskStatus: int32(event.Status),
+		Attempt:    event.Attempt,
+		Reason:     event.Reason,
+		Operator:   event.Operator,
+		EventTime:  event.Time,
+	}
+	if err = s.db.WithContext(ctx).Create(&model).Error; err != nil {
+		return fmt.Errorf("db create error: %v", err)
+	}
+	if limit := s.retention.MaxEventsPerTask; limit > 0 {
+		// 删除超过保留数量的最早的记录，mysql 不支持 in 子查询中直接使用 limit，需要再包一层派生表
+		latest := s.db.Model(&historyModel{}).Select("id").Where("task_id = ?", event.TaskId).
+			Order("id desc").Limit(limit)
+		keep := s.db.Table("(?) AS latest", latest).Select("id")
+		if err = s.db.WithContext(ctx).Where("task_id = ? AND id NOT IN (?)", event.TaskId, keep).
+			Delete(&historyModel{}).Error; err != nil {
+			return fmt.Errorf("db delete error: %v", err)
+		}
+	}
+	if s.retention.MaxAge > 0 && s.needSweep() {
+		// 最多每分钟清理一次过期的记录
+		if err = s.db.WithContext(ctx).Where("event_time < ?", s.now().Add(-s.retention.MaxAge)).
+			Delete(&historyModel{}).Error; err != nil {
+			return fmt.Errorf("db delete error: %v", err)
+		}
+	}
+	return nil
+}
+
+// List 查询任务的状态变化记录
+func (s *sqlStore) List(ctx context.Context, taskId string) (
+	events []lighttaskscheduler.TaskEvent, err error) {
+	db := s.db.WithContext(ctx).Where("task_id = ?", taskId)
+	if s.retention.MaxAge > 0 {
+		db = db.Where("event_time >= ?", s.now().Add(-s.retention.MaxAge))
+	}
+	models := []historyModel{}
+	if err = db.Order("id asc").Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("db find error: %v", err)
+	}
+	for _, model := range models {
+		events = append(events, lighttaskscheduler.TaskEvent{
+			TaskId:   model.TaskId,
+			Status:   lighttaskscheduler.TaskStatus(model.TaskStatus),
+			Attempt:  model.Attempt,
+			Reason:   model.Reason,
+			Operator: model.Operator,
+			Time:     model.EventTime,
+		})
+	}
+	return events, nil
+}
+
+// Delete 删除任务的状态变化记录
+func (s *sqlStore) Delete(ctx context.Context, taskIds []string) (err error) {
+	if len(taskIds) == 0 {
+		return nil
+	}
+	if err = s.db.WithContext(ctx).Where("task_id in ?", taskIds).Delete(&historyModel{}).Error; err != nil {
+		return fmt.Errorf("db delete error: %v", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) needSweep() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.clock.Now()
+	if now.Sub(s.lastSweep) < time.Minute {
+		return false
+	}
+	s.lastSweep = now
+	return true
+}
+
+func (s *sqlStore) now() time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.clock.Now()
+}
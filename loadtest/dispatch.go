@@ -0,0 +1,144 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuator"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+)
+
+// DispatchOptions 调度吞吐压测的配置
+type DispatchOptions struct {
+	Tasks                  int           // 任务数
+	TaskLimit              int32         // 任务并发限制
+	TaskDuration           time.Duration // 每个任务的执行时间，为 0 的时候立即结束
+	SchedulingPollInterval time.Duration // 调度轮询间隔
+	StatePollInterval      time.Duration // 状态轮询间隔
+	Callback               bool          // 是否开启回调
+	DisableStatePoll       bool          // 是否关闭状态轮询，需要开启回调
+}
+
+// String 压测配置的简短描述
+func (o DispatchOptions) String() string {
+	mode := "poll"
+	if o.Callback && o.DisableStatePoll {
+		mode = "callback"
+	} else if o.Callback {
+		mode = "poll+callback"
+	}
+	return fmt.Sprintf("tasks=%d limit=%d duration=%v sched=%v state=%v mode=%s",
+		o.Tasks, o.TaskLimit, o.TaskDuration, o.SchedulingPollInterval, o.StatePollInterval, mode)
+}
+
+// DispatchReport 调度吞吐压测的结果
+type DispatchReport struct {
+	Options         DispatchOptions
+	Elapsed         time.Duration // 从添加第一个任务到所有任务结束的时间
+	Throughput      float64       // 每秒结束的任务数
+	Success, Failed int
+	DispatchLatency Percentiles // 从添加任务到任务开始执行的延时
+	EndToEndLatency Percentiles // 从添加任务到调度器返回任务结束的延时
+	MallocsPerTask  uint64      // 平均每个任务的内存分配次数
+	BytesPerTask    uint64      // 平均每个任务分配的内存字节数
+}
+
+// String 格式化压测结果
+func (r DispatchReport) String() string {
+	return fmt.Sprintf("%v\n  elapsed=%v throughput=%.0f tasks/s success=%d failed=%d\n"+
+		"  dispatch %v\n  end-to-end %v\n  allocs/task=%d bytes/task=%d",
+		r.Options, r.Elapsed, r.Throughput, r.Success, r.Failed,
+		r.DispatchLatency, r.EndToEndLatency, r.MallocsPerTask, r.BytesPerTask)
+}
+
+type callbackReceiver struct {
+	taskChannel chan lighttaskscheduler.Task
+}
+
+func (rec callbackReceiver) GetCallbackChannel(ctx context.Context) chan lighttaskscheduler.Task {
+	return rec.taskChannel
+}
+
+// RunDispatch 添加 Tasks 个任务，等待所有任务结束，测量调度的吞吐和延时
+func RunDispatch(ctx context.Context, opts DispatchOptions) (report DispatchReport, err error) {
+	if opts.Tasks <= 0 || opts.TaskLimit <= 0 {
+		return report, fmt.Errorf("Tasks and TaskLimit must be positive")
+	}
+	report.Options = opts
+	added := make([]int64, opts.Tasks)      // 任务添加的时间
+	dispatched := make([]int64, opts.Tasks) // 任务开始执行的时间
+	run := func(ctx context.Context, task *lighttaskscheduler.Task) (data interface{}, err error) {
+		i := task.TaskItem.(int)
+		atomic.CompareAndSwapInt64(&dispatched[i], 0, time.Now().UnixNano())
+		if opts.TaskDuration > 0 {
+			select {
+			case <-time.After(opts.TaskDuration):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return i, nil
+	}
+	fucntionActuator, err := actuator.MakeFucntionActuator(run, nil)
+	if err != nil {
+		return report, err
+	}
+	config := lighttaskscheduler.Config{
+		TaskLimit:              opts.TaskLimit,
+		SchedulingPollInterval: opts.SchedulingPollInterval,
+		StatePollInterval:      opts.StatePollInterval,
+		DisableStatePoll:       opts.DisableStatePoll,
+		EnableFinshedTaskList:  true,
+	}
+	if opts.Callback {
+		taskChannel := make(chan lighttaskscheduler.Task, opts.Tasks)
+		fucntionActuator.SetCallbackChannel(taskChannel)
+		config.EnableStateCallback = true
+		config.CallbackReceiver = callbackReceiver{taskChannel: taskChannel}
+	}
+	container := memeorycontainer.MakeQueueContainer(uint32(opts.Tasks), time.Millisecond)
+	sch, err := lighttaskscheduler.MakeScheduler(container, fucntionActuator, nil, config)
+	if err != nil {
+		return report, err
+	}
+	defer sch.Close()
+
+	allocs := startAllocCounter()
+	begin := time.Now()
+	for i := 0; i < opts.Tasks; i++ {
+		added[i] = time.Now().UnixNano()
+		if err := sch.AddTask(ctx, lighttaskscheduler.Task{TaskId: strconv.Itoa(i), TaskItem: i}); err != nil {
+			return report, fmt.Errorf("add task %d error: %v", i, err)
+		}
+	}
+	endToEnd := make([]time.Duration, 0, opts.Tasks)
+	for len(endToEnd) < opts.Tasks {
+		select {
+		case task := <-sch.FinshedTasks():
+			endToEnd = append(endToEnd, time.Duration(time.Now().UnixNano()-added[task.TaskItem.(int)]))
+			if task.TaskStatus == lighttaskscheduler.TASK_STATUS_SUCCESS {
+				report.Success++
+			} else {
+				report.Failed++
+			}
+		case <-ctx.Done():
+			return report, fmt.Errorf("%d of %d tasks finished: %v", len(endToEnd), opts.Tasks, ctx.Err())
+		}
+	}
+	report.Elapsed = time.Since(begin)
+	report.MallocsPerTask, report.BytesPerTask = allocs.per(opts.Tasks)
+	report.Throughput = float64(opts.Tasks) / report.Elapsed.Seconds()
+	dispatch := make([]time.Duration, 0, opts.Tasks)
+	for i := range dispatched {
+		if d := atomic.LoadInt64(&dispatched[i]); d > 0 {
+			dispatch = append(dispatch, time.Duration(d-added[i]))
+		}
+	}
+	report.DispatchLatency = percentilesOf(dispatch)
+	report.EndToEndLatency = percentilesOf(endToEnd)
+	return report, nil
+}
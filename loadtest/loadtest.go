@@ -0,0 +1,54 @@
+// Package loadtest 调度器的压测工具，基于函数执行器和队列容器，测量任务调度的吞吐、调度延时，
+// 以及大量运行中任务时一轮状态轮询的耗时和内存分配
+
+package loadtest
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// Percentiles 延时分布
+type Percentiles struct {
+	P50, P90, P99, Max time.Duration
+}
+
+// String 格式化延时分布
+func (p Percentiles) String() string {
+	return fmt.Sprintf("p50=%v p90=%v p99=%v max=%v", p.P50, p.P90, p.P99, p.Max)
+}
+
+// percentilesOf 计算延时分布，会对 samples 排序
+func percentilesOf(samples []time.Duration) Percentiles {
+	if len(samples) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	at := func(q float64) time.Duration {
+		return samples[int(q*float64(len(samples)-1))]
+	}
+	return Percentiles{P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: samples[len(samples)-1]}
+}
+
+// allocCounter 统计一段时间内的内存分配
+type allocCounter struct {
+	mallocs, bytes uint64
+}
+
+func startAllocCounter() allocCounter {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return allocCounter{mallocs: m.Mallocs, bytes: m.TotalAlloc}
+}
+
+// per 从开始统计到现在，平均每个 n 的内存分配次数和字节数
+func (c allocCounter) per(n int) (mallocs, bytes uint64) {
+	if n <= 0 {
+		return 0, 0
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return (m.Mallocs - c.mallocs) / uint64(n), (m.TotalAlloc - c.bytes) / uint64(n)
+}
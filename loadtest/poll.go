@@ -0,0 +1,145 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuator"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+)
+
+// PollOptions 状态轮询压测的配置
+type PollOptions struct {
+	RunningTasks int // 运行中的任务数
+	Polls        int // 测量的轮询次数
+}
+
+// PollReport 状态轮询压测的结果
+type PollReport struct {
+	Options        PollOptions
+	PollLatency    Percentiles // 一轮状态轮询的耗时
+	MallocsPerPoll uint64      // 平均每轮轮询的内存分配次数
+	BytesPerPoll   uint64      // 平均每轮轮询分配的内存字节数
+	PeakGoroutines int         // 轮询过程中 goroutine 数的峰值
+}
+
+// String 格式化压测结果
+func (r PollReport) String() string {
+	return fmt.Sprintf("running=%d polls=%d\n  poll %v\n  allocs/poll=%d bytes/poll=%d peak goroutines=%d",
+		r.Options.RunningTasks, r.Options.Polls, r.PollLatency, r.MallocsPerPoll, r.BytesPerPoll, r.PeakGoroutines)
+}
+
+// pollTimer 记录执行器每次被查询所有运行中任务状态的时间，相邻两次的间隔就是一轮轮询的耗时
+type pollTimer struct {
+	lighttaskscheduler.TaskActuator
+	running int
+
+	lock   sync.Mutex
+	polls  []time.Time
+	notify chan struct{}
+}
+
+func (p *pollTimer) GetAsyncTaskStatus(ctx context.Context, tasks []lighttaskscheduler.Task) (
+	status []lighttaskscheduler.AsyncTaskStatus, err error) {
+	if len(tasks) == p.running {
+		p.lock.Lock()
+		p.polls = append(p.polls, time.Now())
+		p.lock.Unlock()
+		select {
+		case p.notify <- struct{}{}:
+		default:
+		}
+	}
+	return p.TaskActuator.GetAsyncTaskStatus(ctx, tasks)
+}
+
+// RunPoll 启动 RunningTasks 个一直运行的任务，状态轮询间隔为 0 连续轮询，测量每一轮轮询的耗时和内存分配
+func RunPoll(ctx context.Context, opts PollOptions) (report PollReport, err error) {
+	if opts.RunningTasks <= 0 || opts.Polls <= 0 {
+		return report, fmt.Errorf("RunningTasks and Polls must be positive")
+	}
+	report.Options = opts
+	run := func(ctx context.Context, task *lighttaskscheduler.Task) (data interface{}, err error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	fucntionActuator, err := actuator.MakeFucntionActuator(run, nil)
+	if err != nil {
+		return report, err
+	}
+	timer := &pollTimer{TaskActuator: fucntionActuator, running: opts.RunningTasks, notify: make(chan struct{}, 1)}
+	container := memeorycontainer.MakeQueueContainer(uint32(opts.RunningTasks), time.Millisecond)
+	sch, err := lighttaskscheduler.MakeScheduler(container, timer, nil, lighttaskscheduler.Config{
+		TaskLimit:              int32(opts.RunningTasks),
+		SchedulingPollInterval: 10 * time.Millisecond,
+		StatePollInterval:      0,
+	})
+	if err != nil {
+		return report, err
+	}
+	defer sch.Close()
+	for i := 0; i < opts.RunningTasks; i++ {
+		if err := sch.AddTask(ctx, lighttaskscheduler.Task{TaskId: strconv.Itoa(i)}); err != nil {
+			return report, fmt.Errorf("add task %d error: %v", i, err)
+		}
+	}
+
+	// 等待所有任务开始运行以后开始统计
+	waitPolls := func(n int) error {
+		for {
+			timer.lock.Lock()
+			count := len(timer.polls)
+			timer.lock.Unlock()
+			if count >= n {
+				return nil
+			}
+			select {
+			case <-timer.notify:
+			case <-ctx.Done():
+				return fmt.Errorf("%d of %d polls finished: %v", count, n, ctx.Err())
+			}
+		}
+	}
+	if err := waitPolls(1); err != nil {
+		return report, err
+	}
+	timer.lock.Lock()
+	start := len(timer.polls)
+	timer.lock.Unlock()
+
+	allocs := startAllocCounter()
+	sampleCtx, stopSample := context.WithCancel(ctx)
+	defer stopSample()
+	peak := make(chan int, 1)
+	go func() {
+		max := 0
+		for sampleCtx.Err() == nil {
+			if n := runtime.NumGoroutine(); n > max {
+				max = n
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+		peak <- max
+	}()
+	if err := waitPolls(start + opts.Polls); err != nil {
+		return report, err
+	}
+	report.MallocsPerPoll, report.BytesPerPoll = allocs.per(opts.Polls)
+	stopSample()
+	report.PeakGoroutines = <-peak
+
+	timer.lock.Lock()
+	polls := timer.polls[start-1 : start+opts.Polls]
+	timer.lock.Unlock()
+	latency := make([]time.Duration, 0, opts.Polls)
+	for i := 1; i < len(polls); i++ {
+		latency = append(latency, polls[i].Sub(polls[i-1]))
+	}
+	report.PollLatency = percentilesOf(latency)
+	return report, nil
+}
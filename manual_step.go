@@ -0,0 +1,126 @@
+package lighttaskscheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// StepReport 手动模式下一步调度的结果
+type StepReport struct {
+	Events    []TaskEvent // 本步发生的任务状态变化，按照发生的顺序
+	Callbacks int         // DrainCallbacks 处理的回调数
+	Errors    []error     // 调用任务容器或者执行器出错
+}
+
+// TaskIds 本步转移到 status 状态的任务 id，按照发生的顺序
+func (r StepReport) TaskIds(status TaskStatus) (taskIds []string) {
+	for _, event := range r.Events {
+		if event.Status == status {
+			taskIds = append(taskIds, event.TaskId)
+		}
+	}
+	return taskIds
+}
+
+// stepRecorder 通过 ctx 传递，收集一步调度中发生的状态变化和错误
+type stepRecorder struct {
+	lock   sync.Mutex
+	report StepReport
+}
+
+type stepRecorderKey struct{}
+
+func stepReportFrom(ctx context.Context) *stepRecorder {
+	recorder, _ := ctx.Value(stepRecorderKey{}).(*stepRecorder)
+	return recorder
+}
+
+func (r *stepRecorder) addEvent(event TaskEvent) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.report.Events = append(r.report.Events, event)
+}
+
+func (r *stepRecorder) addError(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.report.Errors = append(r.report.Errors, err)
+}
+
+// stepError 手动模式下记录一步调度中的错误
+func (s *TaskScheduler) stepError(ctx context.Context, err error) {
+	if recorder := stepReportFrom(ctx); recorder != nil {
+		recorder.addError(err)
+	}
+}
+
+// step 手动执行一步调度，返回这一步的结果
+func (s *TaskScheduler) step(ctx context.Context, name string, f func(ctx context.Context) int) (StepReport, error) {
+	if !s.Config().ManualStep {
+		return StepReport{}, fmt.Errorf("%s is only available when Config.ManualStep is true", name)
+	}
+	recorder := &stepRecorder{}
+	callbacks := f(context.WithValue(ctx, stepRecorderKey{}, recorder))
+	s.wg.Wait()
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.report.Callbacks = callbacks
+	return recorder.report, nil
+}
+
+// hasWaitingTask 手动模式下判断容器中是否有等待中的任务，容器为空的时候 GetWaitingTask 会按照容器的时钟等待新任务，
+// 容器和调度器共用手动时钟的时候没有人推进时间，ScheduleOnce 会一直阻塞，没有实现 WaitingTaskCounter 的容器视为有任务
+func (s *TaskScheduler) hasWaitingTask(ctx context.Context) bool {
+	counter, ok := ContainerAs[WaitingTaskCounter](s.Container)
+	if !ok {
+		return true
+	}
+	count, err := counter.GetWaitingTaskCount(ctx)
+	if err != nil {
+		s.stepError(ctx, fmt.Errorf("GetWaitingTaskCount error: %v", err))
+		return false
+	}
+	return count > 0
+}
+
+// ScheduleOnce 手动模式下执行一次任务调度，从等待队列中取出任务并且启动，返回时所有任务都已经处理完成
+func (s *TaskScheduler) ScheduleOnce(ctx context.Context) (StepReport, error) {
+	return s.step(ctx, "ScheduleOnce", func(ctx context.Context) int {
+		s.scheduleOnce(ctx)
+		return 0
+	})
+}
+
+// PollOnce 手动模式下执行一次任务状态轮询，处理运行中任务的超时、失败重试、成功导出
+func (s *TaskScheduler) PollOnce(ctx context.Context) (StepReport, error) {
+	return s.step(ctx, "PollOnce", func(ctx context.Context) int {
+		s.updateOnce(ctx)
+		return 0
+	})
+}
+
+// DrainCallbacks 手动模式下处理回调 channel 中所有已经到达的回调，不会等待新的回调
+func (s *TaskScheduler) DrainCallbacks(ctx context.Context) (StepReport, error) {
+	receiver := s.Config().CallbackReceiver
+	if receiver == nil {
+		return StepReport{}, fmt.Errorf("CallbackReceiver is not configured")
+	}
+	s.callbackOnce.Do(func() {
+		s.callbackChannel = receiver.GetCallbackChannel(s.ctx)
+	})
+	return s.step(ctx, "DrainCallbacks", func(ctx context.Context) (count int) {
+		for {
+			select {
+			case t, ok := <-s.callbackChannel:
+				if !ok {
+					return count
+				}
+				s.handleCallback(ctx, t)
+				count++
+			default:
+				return count
+			}
+		}
+	})
+}
@@ -0,0 +1,178 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+)
+
+// TestManualStep 每一步返回的 StepReport 按照发生的顺序包含这一步所有的状态变化，并发数不超过 TaskLimit
+func TestManualStep(t *testing.T) {
+	type step struct {
+		poll    bool          // false 表示 ScheduleOnce
+		advance time.Duration // 执行之前推进的时间
+		status  lighttaskscheduler.TaskStatus
+		want    []string // 转移到 status 的任务 id
+		running int32    // 执行以后运行中的任务数
+	}
+	for _, tc := range []struct {
+		name  string
+		tasks int
+		steps []step
+	}{
+		{
+			name:  "empty",
+			tasks: 0,
+			steps: []step{
+				{status: lighttaskscheduler.TASK_STATUS_RUNNING},
+				{poll: true, advance: time.Second, status: lighttaskscheduler.TASK_STATUS_SUCCESS},
+			},
+		},
+		{
+			name:  "limit",
+			tasks: 3,
+			steps: []step{
+				{status: lighttaskscheduler.TASK_STATUS_RUNNING, want: []string{"task-0", "task-1"}, running: 2},
+				{status: lighttaskscheduler.TASK_STATUS_RUNNING, running: 2},
+				{poll: true, advance: time.Second - 1, status: lighttaskscheduler.TASK_STATUS_SUCCESS, running: 2},
+				{poll: true, advance: 1, status: lighttaskscheduler.TASK_STATUS_SUCCESS,
+					want: []string{"task-0", "task-1"}},
+				{status: lighttaskscheduler.TASK_STATUS_RUNNING, want: []string{"task-2"}, running: 1},
+				{poll: true, advance: time.Second, status: lighttaskscheduler.TASK_STATUS_SUCCESS,
+					want: []string{"task-2"}},
+				{status: lighttaskscheduler.TASK_STATUS_RUNNING},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := makeHarness(t, actuatortest.Script{Duration: time.Second}, nil)
+			for i := 0; i < tc.tasks; i++ {
+				h.add(t, lighttaskscheduler.Task{TaskId: fmt.Sprintf("task-%d", i)})
+			}
+			for i, s := range tc.steps {
+				h.clock.Advance(s.advance)
+				var report lighttaskscheduler.StepReport
+				if s.poll {
+					report = h.poll(t)
+				} else {
+					report = h.schedule(t)
+				}
+				if len(report.Errors) != 0 {
+					t.Fatalf("step %d errors: %v", i, report.Errors)
+				}
+				got := report.TaskIds(s.status)
+				if s.poll {
+					// 轮询按照 GetRunningTask 返回的顺序处理，队列容器返回的运行中任务没有顺序
+					sort.Strings(got)
+				}
+				if fmt.Sprint(got) != fmt.Sprint(s.want) {
+					t.Fatalf("step %d: tasks to %v want %v, got %v", i, s.status, s.want, got)
+				}
+				if running := h.running(t); running != s.running {
+					t.Fatalf("step %d: running tasks want %d, got %d", i, s.running, running)
+				}
+			}
+		})
+	}
+}
+
+// TestManualStepNotBlock 容器和调度器共用手动时钟的时候，没有等待中的任务 ScheduleOnce 也会立即返回
+func TestManualStepNotBlock(t *testing.T) {
+	h := makeHarness(t, actuatortest.Script{Duration: time.Second}, nil)
+	done := make(chan error, 1)
+	go func() {
+		_, err := h.sch.ScheduleOnce(context.Background())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ScheduleOnce error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ScheduleOnce blocks on an empty container")
+	}
+}
+
+// TestManualStepDisabled 没有开启手动模式的时候不能手动驱动
+func TestManualStepDisabled(t *testing.T) {
+	sch, err := lighttaskscheduler.MakeScheduler(memeorycontainer.MakeQueueContainer(16, time.Millisecond),
+		actuatortest.MakeFakeActuator(actuatortest.Script{}), nil, lighttaskscheduler.Config{TaskLimit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sch.Close()
+	ctx := context.Background()
+	for name, step := range map[string]func(ctx context.Context) (lighttaskscheduler.StepReport, error){
+		"ScheduleOnce": sch.ScheduleOnce,
+		"PollOnce":     sch.PollOnce,
+	} {
+		if _, err := step(ctx); err == nil {
+			t.Fatalf("%s is available without ManualStep", name)
+		}
+	}
+}
+
+// TestManualStepErrors 调用执行器出错的时候记录到 StepReport
+func TestManualStepErrors(t *testing.T) {
+	h := makeHarness(t, actuatortest.Script{StartErr: errors.New("start error")}, nil)
+	h.add(t, lighttaskscheduler.Task{TaskId: "task"})
+	report := h.schedule(t)
+	if got := report.TaskIds(lighttaskscheduler.TASK_STATUS_FAILED); fmt.Sprint(got) != "[task]" {
+		t.Fatalf("tasks to FAILED want [task], got %v", got)
+	}
+	for _, event := range report.Events {
+		if event.Status == lighttaskscheduler.TASK_STATUS_FAILED && !strings.Contains(event.Reason, "start error") {
+			t.Fatalf("FAILED event reason want start error, got %q", event.Reason)
+		}
+	}
+}
+
+// TestTaskTimeoutBoundary 超时只由调度器的时钟决定，超过 TaskTimeout 以后的第一次轮询才判定超时
+func TestTaskTimeoutBoundary(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		duration time.Duration
+		advance  time.Duration
+		status   lighttaskscheduler.TaskStatus // TASK_STATUS_RUNNING 表示没有结束
+	}{
+		{"before timeout", 2 * time.Minute, time.Minute - time.Nanosecond, lighttaskscheduler.TASK_STATUS_RUNNING},
+		{"at timeout", 2 * time.Minute, time.Minute, lighttaskscheduler.TASK_STATUS_RUNNING},
+		{"after timeout", 2 * time.Minute, time.Minute + time.Nanosecond, lighttaskscheduler.TASK_STATUS_FAILED},
+		{"finish at timeout", time.Minute, time.Minute, lighttaskscheduler.TASK_STATUS_SUCCESS},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := makeHarness(t, actuatortest.Script{Duration: tc.duration}, func(c *lighttaskscheduler.Config) {
+				c.TaskTimeout = time.Minute
+			})
+			h.add(t, lighttaskscheduler.Task{TaskId: "task"})
+			h.schedule(t)
+			h.clock.Advance(tc.advance)
+			h.poll(t)
+			task := h.finished()["task"]
+			if tc.status == lighttaskscheduler.TASK_STATUS_RUNNING {
+				if task != nil || h.running(t) != 1 {
+					t.Fatalf("task want running, got finished %+v", task)
+				}
+				return
+			}
+			if task == nil || task.TaskStatus != tc.status {
+				t.Fatalf("task want %v, got %+v", tc.status, task)
+			}
+			if tc.status == lighttaskscheduler.TASK_STATUS_FAILED {
+				if !strings.Contains(task.FailedReason.Error(), "超时") || h.actuator.Stops("task") != 1 {
+					t.Fatalf("timeout task want stopped with timeout reason, got %v, Stops %d",
+						task.FailedReason, h.actuator.Stops("task"))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package lighttaskscheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TaskProgress 任务执行进度
+type TaskProgress struct {
+	Percent     float64            // 完成百分比，取值 [0, 100]
+	CurrentStep int32              // 当前执行到的步骤
+	TotalSteps  int32              // 总步骤数，0 表示任务没有步骤的概念
+	Message     string             // 进度描述
+	Metrics     map[string]float64 // 业务自定义的指标，比如已处理的帧数
+	ETA         time.Time          // 预计完成时间，零值表示未知
+	UpdateTime  time.Time          // 进度更新时间
+}
+
+// EstimateETA 根据任务开始时间和完成百分比，线性估算预计完成时间，无法估算的时候返回零值
+func (p TaskProgress) EstimateETA(startTime, now time.Time) time.Time {
+	if p.Percent <= 0 || p.Percent >= 100 || startTime.IsZero() {
+		return time.Time{}
+	}
+	elapsed := now.Sub(startTime)
+	return now.Add(time.Duration(float64(elapsed) * (100 - p.Percent) / p.Percent))
+}
+
+// isZero 进度是否没有设置
+func (p TaskProgress) isZero() bool {
+	return p.Percent == 0 && p.CurrentStep == 0 && p.TotalSteps == 0 && p.Message == "" &&
+		len(p.Metrics) == 0 && p.ETA.IsZero() && p.UpdateTime.IsZero()
+}
+
+// GetProgress 返回执行器上报的进度，没有设置 TaskProgress 的时候兼容废弃的 Progress 字段，
+// Progress 为数值的时候视为完成百分比，为 TaskProgress 的时候直接使用，其他类型忽略
+func (s AsyncTaskStatus) GetProgress() TaskProgress {
+	if !s.TaskProgress.isZero() {
+		return s.TaskProgress
+	}
+	switch p := s.Progress.(type) {
+	case TaskProgress:
+		return p
+	case *TaskProgress:
+		if p != nil {
+			return *p
+		}
+	case float32:
+		return TaskProgress{Percent: float64(p)}
+	case float64:
+		return TaskProgress{Percent: p}
+	case int:
+		return TaskProgress{Percent: float64(p)}
+	case int32:
+		return TaskProgress{Percent: float64(p)}
+	case int64:
+		return TaskProgress{Percent: float64(p)}
+	}
+	return TaskProgress{}
+}
+
+// TaskProgressQuerier 任务进度查询接口，任务容器可以选择实现该接口，
+// 实现以后可以通过 TaskScheduler.GetTaskProgress 查询执行中的任务进度
+type TaskProgressQuerier interface {
+	// GetTaskProgress 查询任务最新的执行进度，进度通过 UpdateRunningTaskStatus 保存
+	GetTaskProgress(ctx context.Context, task *Task) (progress TaskProgress, err error)
+}
+
+// GetTaskProgress 查询执行中的任务进度，需要任务容器实现 TaskProgressQuerier
+func (s *TaskScheduler) GetTaskProgress(ctx context.Context, task *Task) (TaskProgress, error) {
+	querier, ok := ContainerAs[TaskProgressQuerier](s.Container)
+	if !ok {
+		return TaskProgress{}, fmt.Errorf("container does not implement TaskProgressQuerier")
+	}
+	return querier.GetTaskProgress(ctx, task)
+}
@@ -0,0 +1,65 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+)
+
+// TestGetProgress 兼容废弃的 Progress 字段，数值视为完成百分比，同时设置的时候以 TaskProgress 为准
+func TestGetProgress(t *testing.T) {
+	typed := lighttaskscheduler.TaskProgress{Percent: 40, CurrentStep: 2, TotalSteps: 5, Message: "step 2"}
+	for _, tc := range []struct {
+		name   string
+		status lighttaskscheduler.AsyncTaskStatus
+		want   float64 // 期望的完成百分比
+		step   int32
+	}{
+		{"unset", lighttaskscheduler.AsyncTaskStatus{}, 0, 0},
+		{"legacy float32", lighttaskscheduler.AsyncTaskStatus{Progress: float32(0.0)}, 0, 0},
+		{"legacy float64", lighttaskscheduler.AsyncTaskStatus{Progress: 50.0}, 50, 0},
+		{"legacy int", lighttaskscheduler.AsyncTaskStatus{Progress: 30}, 30, 0},
+		{"legacy TaskProgress", lighttaskscheduler.AsyncTaskStatus{Progress: typed}, 40, 2},
+		{"legacy *TaskProgress", lighttaskscheduler.AsyncTaskStatus{Progress: &typed}, 40, 2},
+		{"legacy nil *TaskProgress", lighttaskscheduler.AsyncTaskStatus{
+			Progress: (*lighttaskscheduler.TaskProgress)(nil)}, 0, 0},
+		{"legacy unknown type", lighttaskscheduler.AsyncTaskStatus{Progress: "50%"}, 0, 0},
+		{"typed", lighttaskscheduler.AsyncTaskStatus{TaskProgress: typed}, 40, 2},
+		{"typed wins", lighttaskscheduler.AsyncTaskStatus{Progress: float32(90), TaskProgress: typed}, 40, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.status.GetProgress(); got.Percent != tc.want || got.CurrentStep != tc.step {
+				t.Fatalf("GetProgress want percent %v step %d, got %+v", tc.want, tc.step, got)
+			}
+		})
+	}
+}
+
+// TestLegacyProgressStored 使用废弃 Progress 字段的执行器上报的进度也能被任务容器保存和查询
+func TestLegacyProgressStored(t *testing.T) {
+	ctx := context.Background()
+	container := memeorycontainer.MakeQueueContainer(16, time.Millisecond)
+	task := lighttaskscheduler.Task{TaskId: "task"}
+	if err := container.AddTask(ctx, task); err != nil {
+		t.Fatalf("AddTask error: %v", err)
+	}
+	tasks, err := container.GetWaitingTask(ctx, 1)
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("GetWaitingTask want 1 task, got %d, err: %v", len(tasks), err)
+	}
+	running, err := container.ToRunningStatus(ctx, &tasks[0])
+	if err != nil {
+		t.Fatalf("ToRunningStatus error: %v", err)
+	}
+	status := lighttaskscheduler.AsyncTaskStatus{TaskStatus: lighttaskscheduler.TASK_STATUS_RUNNING, Progress: float32(25)}
+	if err := container.UpdateRunningTaskStatus(ctx, running, status); err != nil {
+		t.Fatalf("UpdateRunningTaskStatus error: %v", err)
+	}
+	progress, err := container.GetTaskProgress(ctx, running)
+	if err != nil || progress.Percent != 25 {
+		t.Fatalf("GetTaskProgress want 25%%, got %+v, err: %v", progress, err)
+	}
+}
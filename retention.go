@@ -0,0 +1,220 @@
+package lighttaskscheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// FinishedTaskLister 可以查询已经结束的任务的任务容器，任务容器可以选择实现该接口，
+// 实现以后调度器可以按照 Config.Retention 定期清理过期的任务
+type FinishedTaskLister interface {
+	// GetFinishedTask 获取状态为 status 并且结束时间早于 before 的任务，最多 limit 个
+	GetFinishedTask(ctx context.Context, status TaskStatus, before time.Time, limit int32) (tasks []Task, err error)
+}
+
+// FinishedTaskListSupporter 转发查询的任务容器可以选择实现该接口，比如组合容器，
+// 被转发的容器不支持查询已经结束的任务的时候返回 false，调度器视为没有实现 FinishedTaskLister
+type FinishedTaskListSupporter interface {
+	SupportFinishedTaskList() bool
+}
+
+// finishedTaskLister 查找任务容器实现的 FinishedTaskLister，转发查询的容器不支持的时候 ok 为 false
+func finishedTaskLister(container TaskContainer) (lister FinishedTaskLister, ok bool) {
+	if lister, ok = ContainerAs[FinishedTaskLister](container); !ok {
+		return nil, false
+	}
+	if supporter, isSupporter := lister.(FinishedTaskListSupporter); isSupporter && !supporter.SupportFinishedTaskList() {
+		return nil, false
+	}
+	return lister, true
+}
+
+// OutputCleaner 执行器可以选择实现该接口，清理任务的时候删除执行器中缓存的任务结果
+type OutputCleaner interface {
+	// DeleteOutput 删除任务在执行器中的结果
+	DeleteOutput(ctx context.Context, task *Task) (err error)
+}
+
+const (
+	defaultRetentionInterval  = time.Hour
+	defaultRetentionBatchSize = 1000
+)
+
+// RetentionConfig 已结束任务的保留策略
+type RetentionConfig struct {
+	// 每种结束状态的保留时间，从任务结束时间开始计算，支持 TASK_STATUS_SUCCESS、TASK_STATUS_FAILED、TASK_STATUS_STOPED，
+	// 没有配置的状态不清理
+	TTL map[TaskStatus]time.Duration
+
+	// 清理的时间周期，默认 1 小时
+	Interval time.Duration
+
+	// 每种状态每个周期最多清理的任务数，默认 1000
+	BatchSize int32
+
+	// 只统计和记录日志，不实际删除任务
+	DryRun bool
+}
+
+func (c *RetentionConfig) check() error {
+	if c.Interval < 0 || c.BatchSize < 0 {
+		return fmt.Errorf("unreasonable retention config, Interval and BatchSize must not be negative")
+	}
+	for status, ttl := range c.TTL {
+		if status != TASK_STATUS_SUCCESS && status != TASK_STATUS_FAILED && status != TASK_STATUS_STOPED {
+			return fmt.Errorf("unreasonable retention config, status %v is not a finished status", status)
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("unreasonable retention config, TTL of status %v must be positive", status)
+		}
+	}
+	return nil
+}
+
+func (c *RetentionConfig) interval() time.Duration {
+	if c == nil || c.Interval <= 0 {
+		return defaultRetentionInterval
+	}
+	return c.Interval
+}
+
+func (c *RetentionConfig) batchSize() int32 {
+	if c.BatchSize <= 0 {
+		return defaultRetentionBatchSize
+	}
+	return c.BatchSize
+}
+
+// RetentionReport 一次清理的结果
+type RetentionReport struct {
+	DryRun bool
+	Tasks  []Task  // 清理的任务，DryRun 的时候为将要清理的任务
+	Errors []error // 清理过程中的错误，出错的任务下个周期重新清理
+}
+
+// RetentionStats 清理的累计统计
+type RetentionStats struct {
+	Runs               int64                // 清理的次数
+	LastRunTime        time.Time            // 最近一次清理的时间
+	Purged             map[TaskStatus]int64 // 每种状态清理的任务数，DryRun 的时候为将要清理的任务数
+	PersistenceDeleted int64                // 删除的持久化结果数
+	Errors             int64                // 清理出错的次数
+}
+
+// retentionRecorder 记录清理的累计统计
+type retentionRecorder struct {
+	lock  sync.Mutex
+	stats RetentionStats
+}
+
+func (r *retentionRecorder) record(report RetentionReport, persistenceDeleted int64, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stats.Runs++
+	r.stats.LastRunTime = now
+	if r.stats.Purged == nil {
+		r.stats.Purged = map[TaskStatus]int64{}
+	}
+	for _, task := range report.Tasks {
+		r.stats.Purged[task.TaskStatus]++
+	}
+	r.stats.PersistenceDeleted += persistenceDeleted
+	r.stats.Errors += int64(len(report.Errors))
+}
+
+func (r *retentionRecorder) snapshot() RetentionStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	stats := r.stats
+	stats.Purged = map[TaskStatus]int64{}
+	for status, count := range r.stats.Purged {
+		stats.Purged[status] = count
+	}
+	return stats
+}
+
+// PurgeExpired 立即按照 Config.Retention 清理一次过期的任务，需要任务容器实现 FinishedTaskLister
+// 清理任务的时候依次删除任务的持久化结果、执行器中缓存的结果、检查点、状态变化历史，最后从任务容器中删除任务
+func (s *TaskScheduler) PurgeExpired(ctx context.Context) (report RetentionReport, err error) {
+	config := s.Config()
+	if config.Retention == nil {
+		return report, fmt.Errorf("retention is not configured")
+	}
+	lister, ok := finishedTaskLister(s.Container)
+	if !ok {
+		return report, fmt.Errorf("container does not implement FinishedTaskLister")
+	}
+	report.DryRun = config.Retention.DryRun
+	var persistenceDeleted int64
+	now := s.clock.Now()
+	for status, ttl := range config.Retention.TTL {
+		tasks, err := lister.GetFinishedTask(ctx, status, now.Add(-ttl), config.Retention.batchSize())
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("get finished task with status %v error: %v", status, err))
+			continue
+		}
+		for i := range tasks {
+			task := tasks[i]
+			if report.DryRun {
+				report.Tasks = append(report.Tasks, task)
+				continue
+			}
+			// 删除的时候任务容器会修改任务的状态，统计使用清理之前的状态
+			purged := task
+			deleted, err := s.purge(ctx, &purged, config)
+			if deleted {
+				persistenceDeleted++
+			}
+			if err != nil {
+				report.Errors = append(report.Errors, err)
+				continue
+			}
+			report.Tasks = append(report.Tasks, task)
+		}
+	}
+	s.retention.record(report, persistenceDeleted, now)
+	if len(report.Tasks) > 0 || len(report.Errors) > 0 {
+		log.Printf("retention purged %d tasks, dry run: %v, errors: %v\n", len(report.Tasks), report.DryRun, report.Errors)
+	}
+	return report, nil
+}
+
+// purge 删除一个过期的任务，persistenceDeleted 表示是否删除了持久化结果
+func (s *TaskScheduler) purge(ctx context.Context, task *Task, config Config) (persistenceDeleted bool, err error) {
+	if task.TaskStatus == TASK_STATUS_SUCCESS && s.Persistencer != nil {
+		if err = s.Persistencer.DeletePersistenceData(ctx, task); err != nil {
+			return false, fmt.Errorf("delete persistence data of task %s error: %v", task.TaskId, err)
+		}
+		persistenceDeleted = true
+	}
+	if cleaner, ok := s.Actuator.(OutputCleaner); ok {
+		if err = cleaner.DeleteOutput(ctx, task); err != nil {
+			return persistenceDeleted, fmt.Errorf("delete output of task %s error: %v", task.TaskId, err)
+		}
+	}
+	s.deleteCheckpoint(ctx, task)
+	if config.HistoryStore != nil {
+		if err = config.HistoryStore.Delete(ctx, []string{task.TaskId}); err != nil {
+			return persistenceDeleted, fmt.Errorf("delete history of task %s error: %v", task.TaskId, err)
+		}
+	}
+	if _, err = s.Container.ToDeleteStatus(ctx, task); err != nil {
+		return persistenceDeleted, fmt.Errorf("delete task %s error: %v", task.TaskId, err)
+	}
+	return persistenceDeleted, nil
+}
+
+// purgeLoop 定期清理过期的任务
+func (s *TaskScheduler) purgeLoop() {
+	s.pollLoop(func(c Config) time.Duration { return c.Retention.interval() }, func(ctx context.Context) {
+		if s.Config().Retention == nil {
+			return
+		}
+		if _, err := s.PurgeExpired(ctx); err != nil {
+			log.Printf("retention purge error: %v\n", err)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+	"github.com/memory-overflow/light-task-scheduler/container"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+)
+
+// finishedContainer 测试用的任务容器，包装队列容器，记录已经结束的任务，实现 FinishedTaskLister
+type finishedContainer struct {
+	lighttaskscheduler.TaskContainer
+	clock lighttaskscheduler.Clock
+
+	lock     sync.Mutex
+	finished map[string]lighttaskscheduler.Task
+}
+
+func makeFinishedContainer() *finishedContainer {
+	return &finishedContainer{
+		TaskContainer: memeorycontainer.MakeQueueContainer(16, time.Millisecond),
+		clock:         lighttaskscheduler.RealClock,
+		finished:      map[string]lighttaskscheduler.Task{},
+	}
+}
+
+func (c *finishedContainer) Unwrap() lighttaskscheduler.TaskContainer {
+	return c.TaskContainer
+}
+
+func (c *finishedContainer) record(task *lighttaskscheduler.Task, err error) (*lighttaskscheduler.Task, error) {
+	if err == nil {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		finished := *task
+		finished.TaskEnbTime = c.clock.Now()
+		c.finished[task.TaskId] = finished
+	}
+	return task, err
+}
+
+func (c *finishedContainer) ToSuccessStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	*lighttaskscheduler.Task, error) {
+	return c.record(c.TaskContainer.ToSuccessStatus(ctx, task))
+}
+
+func (c *finishedContainer) ToFailedStatus(ctx context.Context, task *lighttaskscheduler.Task, reason error) (
+	*lighttaskscheduler.Task, error) {
+	return c.record(c.TaskContainer.ToFailedStatus(ctx, task, reason))
+}
+
+func (c *finishedContainer) ToStopStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	*lighttaskscheduler.Task, error) {
+	return c.record(c.TaskContainer.ToStopStatus(ctx, task))
+}
+
+func (c *finishedContainer) ToDeleteStatus(ctx context.Context, task *lighttaskscheduler.Task) (
+	*lighttaskscheduler.Task, error) {
+	newTask, err := c.TaskContainer.ToDeleteStatus(ctx, task)
+	if err == nil {
+		c.lock.Lock()
+		delete(c.finished, task.TaskId)
+		c.lock.Unlock()
+	}
+	return newTask, err
+}
+
+func (c *finishedContainer) GetFinishedTask(ctx context.Context, status lighttaskscheduler.TaskStatus,
+	before time.Time, limit int32) (tasks []lighttaskscheduler.Task, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, task := range c.finished {
+		if task.TaskStatus == status && task.TaskEnbTime.Before(before) && len(tasks) < int(limit) {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+func (c *finishedContainer) finishedIds() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ids := []string{}
+	for id := range c.finished {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// TestRetentionRequiresLister 任务容器不能查询已经结束的任务的时候不能配置 Retention，避免清理静默失效
+func TestRetentionRequiresLister(t *testing.T) {
+	retention := &lighttaskscheduler.RetentionConfig{
+		TTL: map[lighttaskscheduler.TaskStatus]time.Duration{lighttaskscheduler.TASK_STATUS_SUCCESS: time.Hour},
+	}
+	for _, tc := range []struct {
+		name      string
+		container lighttaskscheduler.TaskContainer
+		ok        bool
+	}{
+		{"queue", memeorycontainer.MakeQueueContainer(16, time.Millisecond), false},
+		{"combination without lister", container.MakeCombinationContainer(
+			memeorycontainer.MakeQueueContainer(16, time.Millisecond),
+			memeorycontainer.MakeQueueContainer(16, time.Millisecond)), false},
+		{"lister", makeFinishedContainer(), true},
+		{"state machine with lister", container.MakeStateMachineContainer(makeFinishedContainer()), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := lighttaskscheduler.MakeScheduler(tc.container, actuatortest.MakeFakeActuator(actuatortest.Script{}),
+				nil, lighttaskscheduler.Config{TaskLimit: 1, ManualStep: true, Retention: retention})
+			if tc.ok != (err == nil) {
+				t.Fatalf("MakeScheduler with Retention want ok %v, got err: %v", tc.ok, err)
+			}
+		})
+	}
+}
+
+// TestPurgeExpired 只清理超过保留时间的任务，DryRun 的时候只统计不删除
+func TestPurgeExpired(t *testing.T) {
+	for _, dryRun := range []bool{true, false} {
+		name := "purge"
+		if dryRun {
+			name = "dry run"
+		}
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			c := makeFinishedContainer()
+			h := makeHarnessWith(t, c, actuatortest.Script{Duration: time.Second}, func(config *lighttaskscheduler.Config) {
+				config.Retention = &lighttaskscheduler.RetentionConfig{
+					TTL: map[lighttaskscheduler.TaskStatus]time.Duration{
+						lighttaskscheduler.TASK_STATUS_SUCCESS: time.Hour,
+					},
+					DryRun: dryRun,
+				}
+			})
+			c.clock = h.clock
+			run := func(taskIds ...string) {
+				for _, id := range taskIds {
+					h.add(t, lighttaskscheduler.Task{TaskId: id})
+				}
+				h.schedule(t)
+				h.clock.Advance(time.Second)
+				h.poll(t)
+			}
+			run("old-1", "old-2")
+			h.clock.Advance(30 * time.Minute)
+			run("new")
+			h.clock.Advance(40 * time.Minute)
+			if got := c.finishedIds(); len(got) != 3 {
+				t.Fatalf("finished tasks want 3, got %v", got)
+			}
+
+			report, err := h.sch.PurgeExpired(ctx)
+			if err != nil {
+				t.Fatalf("PurgeExpired error: %v", err)
+			}
+			if report.DryRun != dryRun || len(report.Tasks) != 2 || len(report.Errors) != 0 {
+				t.Fatalf("PurgeExpired want 2 expired tasks, got %+v", report)
+			}
+			for _, task := range report.Tasks {
+				if task.TaskId != "old-1" && task.TaskId != "old-2" {
+					t.Fatalf("task %s is not expired", task.TaskId)
+				}
+			}
+			want := []string{"new"}
+			if dryRun {
+				want = []string{"new", "old-1", "old-2"}
+			}
+			if got := c.finishedIds(); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("finished tasks after purge want %v, got %v", want, got)
+			}
+			stats := h.sch.Stats().Retention
+			if stats.Runs != 1 || stats.Purged[lighttaskscheduler.TASK_STATUS_SUCCESS] != 2 {
+				t.Fatalf("retention stats want 1 run and 2 purged, got %+v", stats)
+			}
+		})
+	}
+}
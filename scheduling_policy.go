@@ -0,0 +1,166 @@
+package lighttaskscheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SchedulingPolicy 调度策略，从等待任务的候选窗口中选择本次需要启动的任务以及启动的顺序
+type SchedulingPolicy interface {
+	// Select 从 candidates 中选择最多 limit 个任务，按照启动的顺序返回，candidates 是任务容器返回的顺序，
+	// 返回不在 candidates 中或者重复的任务会被忽略，没有被选择的任务放回等待队列
+	Select(ctx context.Context, candidates []Task, limit int) []Task
+}
+
+// WaitingTaskRestorer 取出等待任务会从等待队列中删除的任务容器，实现该接口以后可以配置大于空闲并发数的调度窗口，
+// 调度策略没有选中的任务通过 RestoreWaitingTask 放回等待队列
+type WaitingTaskRestorer interface {
+	// RestoreWaitingTask 把 GetWaitingTask 取出但是没有调度的任务放回等待队列的头部，保持 tasks 的顺序
+	RestoreWaitingTask(ctx context.Context, tasks []Task) error
+}
+
+// fifoPolicy 先进先出，按照任务容器返回的顺序调度
+type fifoPolicy struct{}
+
+// MakeFIFOPolicy 构造先进先出的调度策略，和不配置调度策略的行为一致
+func MakeFIFOPolicy() SchedulingPolicy {
+	return fifoPolicy{}
+}
+
+// Select 选择前 limit 个任务
+func (fifoPolicy) Select(ctx context.Context, candidates []Task, limit int) []Task {
+	if len(candidates) > limit {
+		return candidates[:limit]
+	}
+	return candidates
+}
+
+// priorityPolicy 严格优先级，TaskPriority 大的任务先调度，优先级相同的按照任务容器返回的顺序
+type priorityPolicy struct{}
+
+// MakePriorityPolicy 构造严格优先级的调度策略，优先级只在候选窗口内比较
+func MakePriorityPolicy() SchedulingPolicy {
+	return priorityPolicy{}
+}
+
+// Select 按照优先级从高到低选择 limit 个任务
+func (priorityPolicy) Select(ctx context.Context, candidates []Task, limit int) []Task {
+	tasks := append([]Task{}, candidates...)
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].TaskPriority > tasks[j].TaskPriority
+	})
+	return fifoPolicy{}.Select(ctx, tasks, limit)
+}
+
+// shortestJobFirstPolicy 短作业优先，预计执行时间短的任务先调度
+type shortestJobFirstPolicy struct {
+	estimate func(task Task) time.Duration
+}
+
+// MakeShortestJobFirstPolicy 构造短作业优先的调度策略，estimate 返回任务预计的执行时间，
+// 返回值小于等于 0 表示无法预计，这些任务排在可以预计的任务后面，按照任务容器返回的顺序
+func MakeShortestJobFirstPolicy(estimate func(task Task) time.Duration) (SchedulingPolicy, error) {
+	if estimate == nil {
+		return nil, fmt.Errorf("estimate is nil")
+	}
+	return shortestJobFirstPolicy{estimate: estimate}, nil
+}
+
+// Select 按照预计执行时间从短到长选择 limit 个任务
+func (p shortestJobFirstPolicy) Select(ctx context.Context, candidates []Task, limit int) []Task {
+	type item struct {
+		task     Task
+		duration time.Duration
+	}
+	items := make([]item, len(candidates))
+	for i, task := range candidates {
+		items[i] = item{task: task, duration: p.estimate(task)}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if (items[i].duration > 0) != (items[j].duration > 0) {
+			return items[i].duration > 0
+		}
+		return items[i].duration < items[j].duration
+	})
+	tasks := make([]Task, len(items))
+	for i := range items {
+		tasks[i] = items[i].task
+	}
+	return fifoPolicy{}.Select(ctx, tasks, limit)
+}
+
+// randomPolicy 随机选择
+type randomPolicy struct {
+	lock sync.Mutex
+	rand *rand.Rand
+}
+
+// MakeRandomPolicy 构造随机选择的调度策略，相同的种子和相同的候选任务选择的结果相同
+func MakeRandomPolicy(seed int64) SchedulingPolicy {
+	return &randomPolicy{rand: rand.New(rand.NewSource(seed))}
+}
+
+// Select 随机选择 limit 个任务
+func (p *randomPolicy) Select(ctx context.Context, candidates []Task, limit int) []Task {
+	tasks := append([]Task{}, candidates...)
+	p.lock.Lock()
+	p.rand.Shuffle(len(tasks), func(i, j int) {
+		tasks[i], tasks[j] = tasks[j], tasks[i]
+	})
+	p.lock.Unlock()
+	return fifoPolicy{}.Select(ctx, tasks, limit)
+}
+
+// schedulingWindow 本次从任务容器取出的候选任务数，任务容器不能放回任务的时候只取空闲的并发数
+func (s *TaskScheduler) schedulingWindow(config Config, slots int32) int32 {
+	if _, ok := ContainerAs[WaitingTaskRestorer](s.Container); ok && config.SchedulingWindow > slots {
+		return config.SchedulingWindow
+	}
+	return slots
+}
+
+// selectTasks 按照调度策略从候选任务中选择最多 limit 个任务，没有选中的任务放回等待队列，
+// 任务容器不能放回任务的时候，没有选中的任务排在选中的任务后面继续调度，调度策略只决定顺序
+func (s *TaskScheduler) selectTasks(ctx context.Context, config Config, candidates []Task, limit int) []Task {
+	policy := config.SchedulingPolicy
+	if policy == nil {
+		policy = fifoPolicy{}
+	}
+	candidateMap := make(map[string]bool, len(candidates))
+	for _, task := range candidates {
+		candidateMap[task.TaskId] = true
+	}
+	selected := make([]Task, 0, limit)
+	for _, task := range policy.Select(ctx, candidates, limit) {
+		if len(selected) >= limit {
+			break
+		}
+		if candidateMap[task.TaskId] {
+			delete(candidateMap, task.TaskId) // 同时去重
+			selected = append(selected, task)
+		}
+	}
+	if len(candidateMap) == 0 {
+		return selected
+	}
+	rest := make([]Task, 0, len(candidateMap))
+	for _, task := range candidates {
+		if candidateMap[task.TaskId] {
+			rest = append(rest, task)
+		}
+	}
+	restorer, ok := ContainerAs[WaitingTaskRestorer](s.Container)
+	if !ok {
+		return append(selected, rest...)
+	}
+	if err := restorer.RestoreWaitingTask(ctx, rest); err != nil {
+		log.Printf("restore %d waiting tasks error: %v\n", len(rest), err)
+		s.stepError(ctx, fmt.Errorf("RestoreWaitingTask error: %v", err))
+	}
+	return selected
+}
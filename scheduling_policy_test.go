@@ -0,0 +1,102 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+)
+
+// reversedPolicy 测试用的调度策略，倒序选择，并且返回重复和不在候选窗口中的任务
+type reversedPolicy struct{}
+
+func (reversedPolicy) Select(ctx context.Context, candidates []lighttaskscheduler.Task,
+	limit int) []lighttaskscheduler.Task {
+	tasks := []lighttaskscheduler.Task{{TaskId: "unknown"}}
+	for i := len(candidates) - 1; i >= 0; i-- {
+		tasks = append(tasks, candidates[i], candidates[i])
+	}
+	return tasks
+}
+
+// randomOrder 按照调度器的调度过程模拟随机策略的启动顺序，每一轮的候选任务是剩下的任务，保持添加的顺序
+func randomOrder(seed int64, taskIds []string, limit int) []string {
+	policy := lighttaskscheduler.MakeRandomPolicy(seed)
+	rest := []lighttaskscheduler.Task{}
+	for _, id := range taskIds {
+		rest = append(rest, lighttaskscheduler.Task{TaskId: id})
+	}
+	order := []string{}
+	for len(rest) > 0 {
+		selected := map[string]bool{}
+		for _, task := range policy.Select(context.Background(), rest, limit) {
+			order = append(order, task.TaskId)
+			selected[task.TaskId] = true
+		}
+		left := []lighttaskscheduler.Task{}
+		for _, task := range rest {
+			if !selected[task.TaskId] {
+				left = append(left, task)
+			}
+		}
+		rest = left
+	}
+	return order
+}
+
+// TestSchedulingPolicy 调度策略在调度窗口内决定任务的启动顺序
+func TestSchedulingPolicy(t *testing.T) {
+	priorities := map[string]int{"a": 1, "b": 3, "c": 2, "d": 3}
+	durations := map[string]time.Duration{"a": 4 * time.Second, "b": time.Second, "c": 0, "d": 2 * time.Second}
+	sjf, err := lighttaskscheduler.MakeShortestJobFirstPolicy(func(task lighttaskscheduler.Task) time.Duration {
+		return durations[task.TaskId]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name   string
+		policy lighttaskscheduler.SchedulingPolicy
+		window int32
+		limit  int32
+		want   []string
+	}{
+		{"default", nil, 4, 1, []string{"a", "b", "c", "d"}},
+		{"fifo", lighttaskscheduler.MakeFIFOPolicy(), 4, 1, []string{"a", "b", "c", "d"}},
+		{"priority", lighttaskscheduler.MakePriorityPolicy(), 4, 1, []string{"b", "d", "c", "a"}},
+		{"priority with limit 2", lighttaskscheduler.MakePriorityPolicy(), 4, 2, []string{"b", "d", "c", "a"}},
+		// 没有配置调度窗口的时候只取空闲的并发数，优先级只在取出的任务中比较
+		{"priority without window", lighttaskscheduler.MakePriorityPolicy(), 0, 1, []string{"a", "b", "c", "d"}},
+		{"priority with window 2", lighttaskscheduler.MakePriorityPolicy(), 2, 1, []string{"b", "c", "d", "a"}},
+		{"shortest job first", sjf, 4, 1, []string{"b", "d", "a", "c"}},
+		{"random", lighttaskscheduler.MakeRandomPolicy(7), 4, 1, randomOrder(7, []string{"a", "b", "c", "d"}, 1)},
+		{"ignore unknown and duplicate", reversedPolicy{}, 4, 1, []string{"d", "c", "b", "a"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := makeHarness(t, actuatortest.Script{Duration: time.Second}, func(c *lighttaskscheduler.Config) {
+				c.TaskLimit = tc.limit
+				c.SchedulingPolicy = tc.policy
+				c.SchedulingWindow = tc.window
+			})
+			for _, id := range []string{"a", "b", "c", "d"} {
+				h.add(t, lighttaskscheduler.Task{TaskId: id, TaskPriority: priorities[id]})
+			}
+			order := []string{}
+			for i := 0; i < 4 && len(order) < 4; i++ {
+				started := h.schedule(t).TaskIds(lighttaskscheduler.TASK_STATUS_RUNNING)
+				if len(started) > int(tc.limit) {
+					t.Fatalf("round %d starts %v, more than TaskLimit %d", i, started, tc.limit)
+				}
+				order = append(order, started...)
+				h.clock.Advance(time.Second)
+				h.poll(t)
+			}
+			if fmt.Sprint(order) != fmt.Sprint(tc.want) {
+				t.Fatalf("start order want %v, got %v", tc.want, order)
+			}
+		})
+	}
+}
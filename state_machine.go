@@ -0,0 +1,93 @@
+package lighttaskscheduler
+
+import (
+	"errors"
+	"fmt"
+)
+
+// String 任务状态的名称
+func (s TaskStatus) String() string {
+	switch s {
+	case TASK_STATUS_INVALID:
+		return "INVALID"
+	case TASK_STATUS_UNSTART:
+		return "UNSTART"
+	case TASK_STATUS_WAITING:
+		return "WAITING"
+	case TASK_STATUS_RUNNING:
+		return "RUNNING"
+	case TASK_STATUS_SUCCESS:
+		return "SUCCESS"
+	case TASK_STATUS_FAILED:
+		return "FAILED"
+	case TASK_STATUS_STOPED:
+		return "STOPED"
+	case TASK_STATUS_DELETE:
+		return "DELETE"
+	case TASK_STATUS_EXPORTING:
+		return "EXPORTING"
+	}
+	return fmt.Sprintf("TaskStatus(%d)", int32(s))
+}
+
+// legalTransitions 任务状态的合法转移图
+// RUNNING -> RUNNING 是任务失败以后的重试，FAILED、STOPED -> WAITING 是任务重新加入等待队列
+var legalTransitions = map[TaskStatus][]TaskStatus{
+	TASK_STATUS_UNSTART: {TASK_STATUS_WAITING, TASK_STATUS_DELETE},
+	TASK_STATUS_WAITING: {TASK_STATUS_RUNNING, TASK_STATUS_FAILED, TASK_STATUS_STOPED, TASK_STATUS_DELETE},
+	TASK_STATUS_RUNNING: {TASK_STATUS_RUNNING, TASK_STATUS_EXPORTING, TASK_STATUS_SUCCESS, TASK_STATUS_FAILED,
+		TASK_STATUS_STOPED, TASK_STATUS_DELETE},
+	TASK_STATUS_EXPORTING: {TASK_STATUS_SUCCESS, TASK_STATUS_FAILED, TASK_STATUS_STOPED, TASK_STATUS_DELETE},
+	TASK_STATUS_SUCCESS:   {TASK_STATUS_DELETE},
+	TASK_STATUS_FAILED:    {TASK_STATUS_WAITING, TASK_STATUS_DELETE},
+	TASK_STATUS_STOPED:    {TASK_STATUS_WAITING, TASK_STATUS_DELETE},
+	TASK_STATUS_DELETE:    {},
+}
+
+// ErrIllegalTransition 非法的任务状态转移，可以通过 errors.Is 判断
+var ErrIllegalTransition = errors.New("illegal task status transition")
+
+// IllegalTransitionError 非法的任务状态转移错误
+type IllegalTransitionError struct {
+	TaskId   string
+	From, To TaskStatus
+}
+
+// Error 错误信息
+func (e *IllegalTransitionError) Error() string {
+	return fmt.Sprintf("task %s can not transfer from %v to %v", e.TaskId, e.From, e.To)
+}
+
+// Is 支持 errors.Is(err, ErrIllegalTransition)
+func (e *IllegalTransitionError) Is(target error) bool {
+	return target == ErrIllegalTransition
+}
+
+// CanTransition 判断任务状态能否从 from 转移到 to
+func CanTransition(from, to TaskStatus) bool {
+	for _, status := range legalTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// LegalTransitions 返回从 from 状态可以转移到的状态
+func LegalTransitions(from TaskStatus) []TaskStatus {
+	return append([]TaskStatus{}, legalTransitions[from]...)
+}
+
+// IsTerminalStatus 是否是任务的结束状态
+func IsTerminalStatus(status TaskStatus) bool {
+	return status == TASK_STATUS_SUCCESS || status == TASK_STATUS_FAILED ||
+		status == TASK_STATUS_STOPED || status == TASK_STATUS_DELETE
+}
+
+// ValidateTransition 校验任务状态转移是否合法，非法返回 *IllegalTransitionError，任务容器可以复用
+func ValidateTransition(task *Task, to TaskStatus) error {
+	if !CanTransition(task.TaskStatus, to) {
+		return &IllegalTransitionError{TaskId: task.TaskId, From: task.TaskStatus, To: to}
+	}
+	return nil
+}
@@ -34,11 +34,25 @@ type Task struct {
 	FailedReason error
 	// 任务已经重试的次数，任务容器负责赋予值
 	TaskAttemptsTime int32
+	// 任务版本号，支持乐观锁的任务容器（VersionedContainer）负责赋予值，每次状态转移成功以后加一，
+	// 状态转移时容器中的版本和传入的版本不一致会返回 ErrTaskVersionConflict，版本为 0 表示不比较版本
+	TaskVersion int64
+	// 任务最近一次心跳时间，执行器通过运行中状态的回调上报心跳的时候赋予值
+	TaskHeartbeatTime time.Time
+	// 任务最新的检查点，任务重试或者恢复以后 Start 的时候框架赋予值，执行器也可以通过回调上报检查点
+	TaskCheckpoint []byte
 }
 
 // AsyncTaskStatus 异步任务状态
 type AsyncTaskStatus struct {
 	TaskStatus   TaskStatus
 	FailedReason error
-	Progress     interface{}
+	// Deprecated: 使用 TaskProgress 上报结构化的进度，为了兼容已有的执行器保留，数值类型视为完成百分比
+	Progress interface{}
+	// 任务执行进度，任务容器通过 GetProgress 获取，同时设置 Progress 的时候以 TaskProgress 为准
+	TaskProgress TaskProgress
+	// 执行器最近一次收到任务心跳的时间，零值表示执行器不支持心跳
+	LastHeartbeat time.Time
+	// 任务最新的检查点，不为 nil 的时候调度器会保存，任务重试的时候通过 Task.TaskCheckpoint 传给执行器
+	Checkpoint []byte
 }
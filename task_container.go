@@ -38,3 +38,41 @@ type TaskContainer interface {
 	// UpdateRunningTaskStatus 更新执行中的任务执行进度状态
 	UpdateRunningTaskStatus(ctx context.Context, task *Task, status AsyncTaskStatus) error
 }
+
+// VersionedContainer 支持乐观锁的任务容器，所有 ToXXXStatus 状态转移方法都需要比较 Task.TaskVersion，
+// 和容器中的版本一致才能转移成功，成功以后版本加一并且更新到传入的 task，不一致返回 ErrTaskVersionConflict，
+// 重复或者过期的状态转移由容器拒绝，调度器不再需要在内存中对回调和轮询做去重
+type VersionedContainer interface {
+	TaskContainer
+
+	// SupportTaskVersion 是否支持任务版本的比较
+	SupportTaskVersion() bool
+}
+
+// TaskGetter 可以按照任务 id 查询任务当前状态和版本的任务容器，任务容器可以选择实现该接口，
+// 支持乐观锁的容器实现以后，回调带回来的版本和容器冲突的时候，调度器从容器读取任务这次执行当前的版本重新处理
+type TaskGetter interface {
+	// GetTask 查询任务当前的状态和版本，容器中没有该任务的时候 ok 为 false
+	GetTask(ctx context.Context, taskId string) (task Task, ok bool, err error)
+}
+
+// ContainerWrapper 包装了其他任务容器的容器，比如状态机校验容器，
+// 调度器查找 CheckpointStore、FinishedOutbox 等可选接口的时候，会通过 Unwrap 查找被包装的容器
+type ContainerWrapper interface {
+	Unwrap() TaskContainer
+}
+
+// ContainerAs 从任务容器以及被它包装的容器中查找实现了 T 接口的容器
+func ContainerAs[T any](container TaskContainer) (t T, ok bool) {
+	for container != nil {
+		if t, ok = container.(T); ok {
+			return t, true
+		}
+		wrapper, isWrapper := container.(ContainerWrapper)
+		if !isWrapper {
+			break
+		}
+		container = wrapper.Unwrap()
+	}
+	return t, false
+}
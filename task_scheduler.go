@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"reflect"
 	"runtime/debug"
 	"sync"
 	"time"
@@ -19,9 +20,27 @@ type Config struct {
 	// 任务并发限制
 	TaskLimit int32
 
+	// 自适应并发配置，不为 nil 的时候开启自适应并发，
+	// 实际并发限制从 TaskLimit 开始，根据执行器的反馈在 [MinTaskLimit, MaxTaskLimit] 之间调整
+	AdaptiveConcurrency *AdaptiveConcurrencyConfig
+
 	// 任务失败最大尝试次数
 	MaxFailedAttempts int32
 
+	// 调度策略，从等待任务的候选窗口中选择需要启动的任务以及启动的顺序，为 nil 的时候按照任务容器返回的顺序先进先出
+	SchedulingPolicy SchedulingPolicy
+
+	// 调度策略的候选窗口大小，大于空闲的并发数的时候，每次从任务容器多取出一些等待任务供调度策略选择，
+	// 没有选中的任务放回等待队列，需要任务容器实现 WaitingTaskRestorer，否则窗口大小等于空闲的并发数
+	SchedulingWindow int32
+
+	// 任务执行时间的估计器，不为 nil 的时候调度器在任务成功以后学习任务的执行时间，
+	// 可以通过 EstimateDuration、GetWaitingTaskETA 查询，也可以传给短作业优先的调度策略
+	DurationEstimator DurationEstimator
+
+	// AddTask 的准入控制，不为 nil 的时候按照等待中的任务数限制添加任务，拒绝的时候返回 *AdmissionError
+	Admission *AdmissionConfig
+
 	// 任务调度固定使用轮询，会定期使用任务容器的接口获取执行中的任务数和任务等待队列中的任务
 	// SchedulingPollInterval 用来配置该定期轮询的时间周期
 	// 根据任务容器配置合理的值，比如 db 任务容器，配置合理的轮询间隔，避免对 db 压力过大
@@ -50,12 +69,67 @@ type Config struct {
 	// for finishedTask := range TaskScheduler.FinshedTasks() {
 	//   ...
 	// }
-	// 及时取走 channel 中的数据，否则可能造成 channel 满了，任务调度阻塞
+	// 及时取走 channel 中的数据，否则可能造成 channel 满了，任务调度阻塞，channel 满了以后会丢弃最早的任务，
+	// 需要可靠投递请使用 EnableFinishedOutbox
 	// 默认不开启，为 false
 	EnableFinshedTaskList bool
+
+	// 是否开启已完成任务的发件箱，开启以后任务完成记录保存在任务容器中，需要任务容器实现 FinishedOutbox
+	// 通过 TaskScheduler.NextFinished 获取完成记录，处理完成以后通过 TaskScheduler.Ack 确认，
+	// 保证消费者重启也不会丢失完成记录，每条记录至少投递一次
+	EnableFinishedOutbox bool
+
+	// 发件箱中已经投递的完成记录超过该时间没有确认，会被重新投递，默认 1 分钟
+	FinishedAckTimeout time.Duration
+
+	// 任务心跳超时时间，大于 0 的时候开启心跳检测，执行器通过 AsyncTaskStatus.LastHeartbeat 或者
+	// 运行中状态的回调 Task.TaskHeartbeatTime 上报心跳，任务超过该时间没有心跳，视为执行任务的 worker 已经丢失，
+	// 停止任务以后按照失败重试，和 TaskTimeout 的强制超时相互独立，心跳检测依赖轮询，需要 DisableStatePoll 为 false，
+	// 每次执行只有收到第一次心跳以后才开始检测，不上报心跳的执行器不受影响
+	HeartbeatTimeout time.Duration
+
+	// DeadLetterStore 死信存储，不为 nil 的时候，重试次数耗尽仍然失败的任务会连同每一次的执行记录保存到死信存储，
+	// 可以通过 ListDeadLetters 查询，RedriveDeadLetters 重新加入等待队列
+	DeadLetterStore DeadLetterStore
+
+	// HistoryStore 任务状态变化历史存储，不为 nil 的时候调度器记录任务的每一次状态变化，
+	// 可以通过 TaskHistory 查询，用于问题排查
+	HistoryStore HistoryStore
+
+	// 已结束任务的保留策略，不为 nil 的时候调度器定期清理过期的任务以及任务的持久化结果，需要任务容器实现 FinishedTaskLister
+	Retention *RetentionConfig
+
+	// 调度器使用的时钟，为 nil 的时候使用系统时间，测试的时候可以配置 fakeclock 手动推进时间，调度器启动以后不允许修改
+	Clock Clock
+
+	// 手动模式，为 true 的时候 MakeScheduler 不启动任何后台 goroutine，需要通过 ScheduleOnce、PollOnce、
+	// DrainCallbacks 手动驱动调度器，每一步都同步完成并且返回结果，用于确定性地测试容器和执行器，启动以后不允许修改
+	ManualStep bool
 }
 
 func (c *Config) check() error {
+	if c.TaskLimit < 0 || c.MaxFailedAttempts < 0 || c.SchedulingWindow < 0 {
+		return fmt.Errorf("unreasonable config, TaskLimit, MaxFailedAttempts and SchedulingWindow must not be negative")
+	}
+	if c.TaskTimeout < 0 || c.SchedulingPollInterval < 0 || c.StatePollInterval < 0 || c.HeartbeatTimeout < 0 ||
+		c.FinishedAckTimeout < 0 {
+		return fmt.Errorf("unreasonable config, TaskTimeout and poll intervals must not be negative")
+	}
+	if c.AdaptiveConcurrency != nil {
+		if err := c.AdaptiveConcurrency.check(); err != nil {
+			return err
+		}
+	}
+	if c.Retention != nil {
+		if err := c.Retention.check(); err != nil {
+			return err
+		}
+	}
+	if c.Admission != nil {
+		if err := c.Admission.check(); err != nil {
+			return err
+		}
+	}
 	if c.DisableStatePoll && !c.EnableStateCallback {
 		return fmt.Errorf("unreasonable config, DisableStatePoll must with set EnableStateCallback true")
 	}
@@ -66,6 +140,36 @@ func (c *Config) check() error {
 	return nil
 }
 
+// checkContainer 检查配置，并且检查配置需要的可选接口任务容器是否都实现了
+func (c *Config) checkContainer(container TaskContainer) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	if _, ok := ContainerAs[FinishedOutbox](container); c.EnableFinishedOutbox && !ok {
+		return fmt.Errorf("unreasonable config, if set EnableFinishedOutbox true, container must implement FinishedOutbox")
+	}
+	if _, ok := finishedTaskLister(container); c.Retention != nil && !ok {
+		return fmt.Errorf("unreasonable config, if set Retention, container must implement FinishedTaskLister")
+	}
+	return nil
+}
+
+// sameInstance 判断两个接口类型的配置项是否是同一个实例，动态类型可以比较的时候使用 ==，
+// 否则（比如包含 map、slice 的结构体）使用 reflect.DeepEqual，避免直接比较不可比较的类型导致 panic
+func sameInstance(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+	if va.Comparable() {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
+
 type processTime struct {
 	t      time.Time
 	taskId string
@@ -82,6 +186,7 @@ type TaskScheduler struct {
 
 	finshedTask chan *Task // 回调给用户已完成的任务
 	config      Config
+	configLock  sync.RWMutex
 	ctx         context.Context
 	cancel      context.CancelFunc
 
@@ -93,47 +198,88 @@ type TaskScheduler struct {
 	lock                       sync.Mutex
 
 	wg *stlextension.LimitWaitGroup
+
+	concurrency concurrencyController // 自适应并发控制
+	attempts    attemptRecorder       // 任务失败的执行记录，用于死信
+	heartbeats  sync.Map              // 运行中的任务最近一次心跳时间，taskId -> time.Time
+	failing     sync.Map              // 转移到失败状态出错，等待重试的任务，taskId -> failingTask
+	futures     futureSet             // 等待任务结束的 Future
+	retention   retentionRecorder     // 过期任务清理的统计
+	admission   admissionController   // 添加任务的准入控制
+
+	finishedNotify chan struct{} // 有新的任务完成记录的通知
+
+	clock Clock // 调度器使用的时钟
+
+	callbackOnce    sync.Once
+	callbackChannel chan Task // 手动模式下的回调 channel
+}
+
+// SchedulerStats 调度器运行时统计
+type SchedulerStats struct {
+	// 当前实际生效的并发限制，开启自适应并发的时候为动态调整后的值，否则为 TaskLimit
+	EffectiveTaskLimit int32
+	// 过期任务清理的累计统计
+	Retention RetentionStats
+	// 准入控制的统计
+	Admission AdmissionStats
 }
 
 // MakeScheduler 新建任务调度器
 // 如果不需要对任务数据此久化，persistencer 可以设置为 nil
-// 调度器构建以后，自动开始任务调度
+// 调度器构建以后，自动开始任务调度，Config.ManualStep 为 true 的时候需要手动驱动
 func MakeScheduler(
 	container TaskContainer,
 	actuator TaskActuator,
 	persistencer TaskdataPersistencer,
 	config Config) (*TaskScheduler, error) {
-	if err := config.check(); err != nil {
+	if err := config.checkContainer(container); err != nil {
 		return nil, err
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	scheduler := &TaskScheduler{
-		Container:    container,
-		Actuator:     actuator,
-		Persistencer: persistencer,
-		config:       config,
-		ctx:          ctx,
-		cancel:       cancel,
-		wg:           stlextension.NewLimitWaitGroup(20),
-		head:         0,
-		tail:         0,
-		count:        0,
+		Container:      container,
+		Actuator:       actuator,
+		Persistencer:   persistencer,
+		config:         config,
+		ctx:            ctx,
+		cancel:         cancel,
+		wg:             stlextension.NewLimitWaitGroup(20),
+		head:           0,
+		tail:           0,
+		count:          0,
+		finishedNotify: make(chan struct{}, 1),
+		clock:          ClockOrReal(config.Clock),
 	}
 	if config.EnableFinshedTaskList {
 		scheduler.finshedTask = make(chan *Task, 10000)
 	}
-	go scheduler.start()
+	if !config.ManualStep {
+		go scheduler.start()
+	}
 	return scheduler, nil
 }
 
 // AddTask 添加一个任务，需要把任务转换成 lighttaskscheduler.Task 的通用形式
 // 注意一定要配置一个唯一的任务 id 标识
+// 配置了 Config.Admission 的时候，超过限制的任务返回 *AdmissionError，可以通过 IsAdmissionRejected 判断
 func (s *TaskScheduler) AddTask(ctx context.Context, task Task) error {
+	config := s.Config()
+	if err := s.admission.admit(ctx, config, s.concurrency.limitOf(config), s.Container, task); err != nil {
+		return err
+	}
 	newTask, err := s.Actuator.Init(ctx, &task) // 初始化任务
 	if err != nil {
+		s.admission.settle(task.TaskId, false)
 		return fmt.Errorf("task init failed: %v", err)
 	}
-	return s.Container.AddTask(ctx, *newTask)
+	err = s.Container.AddTask(ctx, *newTask)
+	s.admission.settle(task.TaskId, err == nil)
+	if err != nil {
+		return err
+	}
+	s.recordHistory(ctx, newTask, TASK_STATUS_WAITING, nil)
+	return nil
 }
 
 // FinshedTasks 返回的完成的任务的 channel
@@ -142,8 +288,25 @@ func (s *TaskScheduler) FinshedTasks() chan *Task {
 }
 
 // StopTask 停止一个任务
+// 任务容器实现 TaskGetter 的时候使用容器中任务当前的状态，否则使用 ftask.TaskStatus
 func (s *TaskScheduler) StopTask(ctx context.Context, ftask *Task) error {
 	oldStaus := ftask.TaskStatus
+	if getter, ok := ContainerAs[TaskGetter](s.Container); ok {
+		current, found, err := getter.GetTask(ctx, ftask.TaskId)
+		if err != nil {
+			return fmt.Errorf("get task %s error: %v", ftask.TaskId, err)
+		}
+		if found {
+			oldStaus = current.TaskStatus
+		}
+	}
+	if oldStaus != TASK_STATUS_INVALID {
+		task := *ftask
+		task.TaskStatus = oldStaus
+		if err := ValidateTransition(&task, TASK_STATUS_STOPED); err != nil {
+			return err
+		}
+	}
 	ftask, err := s.Container.ToStopStatus(ctx, ftask)
 	if err != nil {
 		return err
@@ -151,13 +314,61 @@ func (s *TaskScheduler) StopTask(ctx context.Context, ftask *Task) error {
 	if oldStaus == TASK_STATUS_RUNNING {
 		s.Actuator.Stop(ctx, ftask)
 	}
+	s.recordHistory(ctx, ftask, TASK_STATUS_STOPED, nil)
+	s.attempts.take(ftask.TaskId)
+	s.heartbeats.Delete(ftask.TaskId)
+	s.failing.Delete(ftask.TaskId)
+	s.admission.release(ftask.TaskId)
+	s.resolveFutures(ctx, ftask)
+	return nil
+
+}
+
+// Config 返回调度器当前配置的副本
+func (s *TaskScheduler) Config() Config {
+	s.configLock.RLock()
+	defer s.configLock.RUnlock()
+	return s.config
+}
+
+// UpdateConfig 运行时修改调度器配置，修改在下一次调度或者轮询的周期生效
+// TaskTimeout、TaskLimit、MaxFailedAttempts、SchedulingPolicy、SchedulingWindow、SchedulingPollInterval、StatePollInterval 可以在运行时修改，
+// Retention、Admission、DeadLetterStore、HistoryStore 修改的时候和 MakeScheduler 一样检查任务容器是否支持，只对之后的任务生效，
+// DisableStatePoll、EnableStateCallback、CallbackReceiver、EnableFinshedTaskList、EnableFinishedOutbox、Clock、ManualStep 决定了调度器的运行方式，不允许修改
+func (s *TaskScheduler) UpdateConfig(update func(c *Config)) error {
+	s.configLock.Lock()
+	defer s.configLock.Unlock()
+	newConfig := s.config
+	update(&newConfig)
+	if newConfig.DisableStatePoll != s.config.DisableStatePoll ||
+		newConfig.EnableStateCallback != s.config.EnableStateCallback ||
+		!sameInstance(newConfig.CallbackReceiver, s.config.CallbackReceiver) ||
+		newConfig.EnableFinshedTaskList != s.config.EnableFinshedTaskList ||
+		newConfig.EnableFinishedOutbox != s.config.EnableFinishedOutbox ||
+		!sameInstance(newConfig.Clock, s.config.Clock) ||
+		newConfig.ManualStep != s.config.ManualStep {
+		return fmt.Errorf("DisableStatePoll, EnableStateCallback, CallbackReceiver, EnableFinshedTaskList, " +
+			"EnableFinishedOutbox, Clock and ManualStep can not be changed after scheduler started")
+	}
+	if err := newConfig.checkContainer(s.Container); err != nil {
+		return err
+	}
+	s.config = newConfig
 	return nil
+}
 
+// Stats 获取调度器运行时统计
+func (s *TaskScheduler) Stats() SchedulerStats {
+	return SchedulerStats{
+		EffectiveTaskLimit: s.concurrency.limitOf(s.Config()),
+		Retention:          s.retention.snapshot(),
+		Admission:          s.admission.snapshot(),
+	}
 }
 
 // Close 停止调度
 func (s *TaskScheduler) Close() {
-	if s.config.EnableFinshedTaskList {
+	if s.Config().EnableFinshedTaskList {
 		close(s.finshedTask)
 	}
 	s.cancel()
@@ -185,7 +396,7 @@ func (s *TaskScheduler) checkProcessed(t *Task) bool {
 		}
 		s.processedTask[t.TaskId] = true
 		s.taskProcessedTime[s.tail] = processTime{
-			t:      time.Now(),
+			t:      s.clock.Now(),
 			taskId: t.TaskId,
 		}
 		s.tail++
@@ -200,13 +411,14 @@ func (s *TaskScheduler) checkProcessed(t *Task) bool {
 }
 
 func (s *TaskScheduler) cleanProcessTask() {
-	ticker := time.NewTicker(time.Second)
+	ticker := s.clock.NewTicker(time.Second)
+	defer ticker.Stop()
 	for {
 
 		select {
 		case <-s.ctx.Done():
 			return
-		case <-ticker.C:
+		case <-ticker.C():
 			func() {
 				s.lock.Lock()
 				defer func() {
@@ -217,7 +429,7 @@ func (s *TaskScheduler) cleanProcessTask() {
 				}()
 
 				for s.count > 0 {
-					if s.taskProcessedTime[s.head].t.Before(time.Now().Add(-time.Second)) {
+					if s.taskProcessedTime[s.head].t.Before(s.clock.Now().Add(-time.Second)) {
 						delete(s.processedTask, s.taskProcessedTime[s.head].taskId)
 						s.head++
 						s.count--
@@ -236,18 +448,21 @@ func (s *TaskScheduler) cleanProcessTask() {
 }
 
 func (s *TaskScheduler) start() {
+	config := s.Config()
 	go s.schedulerTask()
 
-	if s.config.EnableStateCallback {
+	if config.EnableStateCallback {
 		go s.updateCallbackTask()
 	}
 
-	if !s.config.DisableStatePoll {
+	if !config.DisableStatePoll {
 		go s.updateTaskStatus()
 	}
 
-	if s.config.EnableStateCallback && !s.config.DisableStatePoll {
-		// 如果同时开启轮询和回调，必须要开启重复处理检测
+	go s.purgeLoop()
+
+	if config.EnableStateCallback && !config.DisableStatePoll && !s.versioned() {
+		// 如果同时开启轮询和回调，必须要开启重复处理检测，支持乐观锁的容器由容器拒绝重复的状态转移
 		s.enableProcessedCheck = true
 		s.processedTask = make(map[string]bool)
 		s.bufflen = 10000
@@ -257,64 +472,117 @@ func (s *TaskScheduler) start() {
 }
 
 func (s *TaskScheduler) schedulerTask() {
-	if s.config.SchedulingPollInterval == 0 {
-		for {
+	s.pollLoop(func(c Config) time.Duration { return c.SchedulingPollInterval }, s.scheduleOnce)
+}
+
+// pollLoop 按照配置的间隔周期执行 f，间隔为 0 时不间断执行，每个周期都会重新读取配置，支持运行时修改间隔
+func (s *TaskScheduler) pollLoop(getInterval func(c Config) time.Duration, f func(ctx context.Context)) {
+	interval := getInterval(s.Config())
+	var ticker Ticker
+	if interval > 0 {
+		ticker = s.clock.NewTicker(interval)
+	}
+	for {
+		if ticker == nil {
 			select {
 			case <-s.ctx.Done():
 				return
 			default:
-				s.scheduleOnce(s.ctx)
+				f(s.ctx)
 			}
-		}
-	} else {
-		ticker := time.NewTicker(s.config.SchedulingPollInterval)
-		for {
+		} else {
 			select {
 			case <-s.ctx.Done():
+				ticker.Stop()
 				return
-			case <-ticker.C:
-				s.scheduleOnce(s.ctx)
+			case <-ticker.C():
+				f(s.ctx)
+			}
+		}
+		if newInterval := getInterval(s.Config()); newInterval != interval {
+			interval = newInterval
+			if interval == 0 && ticker != nil {
+				ticker.Stop()
+				ticker = nil
+			} else if interval > 0 && ticker == nil {
+				ticker = s.clock.NewTicker(interval)
+			} else if interval > 0 {
+				ticker.Reset(interval)
 			}
 		}
 	}
 }
 
 func (s *TaskScheduler) scheduleOnce(ctx context.Context) {
+	s.retryFailing(ctx)
+	config := s.Config()
+	taskLimit := s.concurrency.limitOf(config)
+	defer s.concurrency.adjust(config)
 	runningCount, err := s.Container.GetRunningTaskCount(ctx)
 	if err != nil {
+		s.stepError(ctx, fmt.Errorf("GetRunningTaskCount error: %v", err))
 		return
 	}
-	if runningCount >= s.config.TaskLimit {
+	if runningCount >= taskLimit {
 		return
 	}
-	waitTasks, err := s.Container.GetWaitingTask(ctx, s.config.TaskLimit-runningCount)
+	slots := taskLimit - runningCount
+	if config.ManualStep && !s.hasWaitingTask(ctx) {
+		return
+	}
+	waitTasks, err := s.Container.GetWaitingTask(ctx, s.schedulingWindow(config, slots))
 	if err != nil {
+		s.stepError(ctx, fmt.Errorf("GetWaitingTask error: %v", err))
 		return
 	}
-	wg := stlextension.NewLimitWaitGroup(20)
+	waitTasks = s.selectTasks(ctx, config, waitTasks, int(slots))
+	for i := range waitTasks {
+		s.admission.release(waitTasks[i].TaskId)
+	}
+	parallel := uint(20)
+	if config.ManualStep {
+		// 手动模式按照顺序依次启动，保证结果确定
+		parallel = 1
+	}
+	wg := stlextension.NewLimitWaitGroup(parallel)
 	for i := range waitTasks {
-		task := waitTasks[i]
+		task, waiting := waitTasks[i], waitTasks[i]
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			s.loadCheckpoint(ctx, &task)
+			startTime := s.clock.Now()
 			newTask, ignore, err := s.Actuator.Start(ctx, &task)
+			s.concurrency.record(s.clock.Now().Sub(startTime), err != nil)
 			if err != nil {
 				if !ignore {
-					s.failed(s.ctx, newTask, fmt.Errorf("start task error: %v", err))
+					s.failed(ctx, newTask, fmt.Errorf("start task error: %v", err))
 				}
 				return
 			}
-			if count, err := s.Container.GetRunningTaskCount(ctx); err == nil && count >= s.config.TaskLimit {
-				// 多调度器可能出现的问题，超过任务数量限制，取消当前任务调度
+			if count, err := s.Container.GetRunningTaskCount(ctx); err == nil && count >= taskLimit {
+				// 多调度器可能出现的问题，超过任务数量限制，取消当前任务调度，任务容器支持的时候放回等待队列
 				s.Actuator.Stop(ctx, newTask)
+				if restorer, ok := ContainerAs[WaitingTaskRestorer](s.Container); ok {
+					if err := restorer.RestoreWaitingTask(ctx, []Task{waiting}); err != nil {
+						log.Printf("restore waiting task %s error: %v\n", waiting.TaskId, err)
+						s.stepError(ctx, fmt.Errorf("RestoreWaitingTask error: %v", err))
+					}
+				}
 				return
 			}
 			_, err = s.Container.ToRunningStatus(ctx, newTask)
 			if err != nil {
 				s.Actuator.Stop(ctx, newTask)
-				s.failed(s.ctx, newTask, fmt.Errorf("taskl ToRunningStatus error: %v", err))
+				if IsVersionConflict(err) {
+					// 取出以后任务已经被停止或者删除，不能再转移到失败状态
+					return
+				}
+				s.failed(ctx, newTask, fmt.Errorf("taskl ToRunningStatus error: %v", err))
 				return
 			}
+			s.recordHistory(ctx, newTask, TASK_STATUS_RUNNING, nil)
+			s.resetHeartbeat(newTask)
 
 		}()
 	}
@@ -322,77 +590,147 @@ func (s *TaskScheduler) scheduleOnce(ctx context.Context) {
 }
 
 func (s *TaskScheduler) updateTaskStatus() {
-	if s.config.StatePollInterval == 0 {
-		for {
-			select {
-			case <-s.ctx.Done():
-				return
-			default:
-				s.updateOnce(s.ctx)
-			}
+	s.pollLoop(func(c Config) time.Duration { return c.StatePollInterval }, s.updateOnce)
+}
+
+func (s *TaskScheduler) updateCallbackTask() {
+	for t := range s.Config().CallbackReceiver.GetCallbackChannel(s.ctx) {
+		s.handleCallback(s.ctx, t)
+	}
+}
+
+// handleCallback 处理一个任务状态回调
+func (s *TaskScheduler) handleCallback(ctx context.Context, t Task) {
+	if t.TaskStatus == TASK_STATUS_RUNNING || t.TaskStatus == TASK_STATUS_FAILED {
+		s.saveCheckpoint(ctx, &t, t.TaskCheckpoint)
+	}
+	if t.TaskStatus == TASK_STATUS_RUNNING {
+		// 运行中的任务回调，只更新心跳和检查点，没有带心跳时间的以收到回调的时间作为心跳
+		heartbeatTime := t.TaskHeartbeatTime
+		if heartbeatTime.IsZero() {
+			heartbeatTime = s.clock.Now()
 		}
-	} else {
-		ticker := time.NewTicker(s.config.StatePollInterval)
-		for {
-			select {
-			case <-s.ctx.Done():
-				return
-			case <-ticker.C:
-				s.updateOnce(s.ctx)
-			}
+		s.heartbeat(&t, heartbeatTime)
+		return
+	}
+	// 可能是轮询已经处理过，或者重复回调
+	if !s.checkProcessed(&t) {
+		return
+	}
+	s.wg.Add(1)
+	config := s.Config()
+	s.async(config, func() {
+		defer s.wg.Done()
+		err := s.finishCallback(ctx, &t, config)
+		if IsVersionConflict(err) && s.refreshVersion(ctx, &t) {
+			s.finishCallback(ctx, &t, config)
 		}
+	})
+}
+
+// finishCallback 处理任务失败或者成功的回调，返回第一次状态转移的版本冲突
+func (s *TaskScheduler) finishCallback(ctx context.Context, t *Task, config Config) error {
+	if t.TaskStatus == TASK_STATUS_FAILED {
+		// 失败可以重试
+		return s.retryOrFail(ctx, t, t.FailedReason, config)
+	} else if t.TaskStatus == TASK_STATUS_SUCCESS {
+		return s.export(ctx, t)
 	}
+	return nil
 }
 
-func (s *TaskScheduler) updateCallbackTask() {
-	for t := range s.config.CallbackReceiver.GetCallbackChannel(s.ctx) {
-		// 可能是轮询已经处理过，或者重复回调
-		if !s.checkProcessed(&t) {
-			continue
-		}
-		s.wg.Add(1)
-		task := t
-		go func() {
-			defer s.wg.Done()
-			if task.TaskStatus == TASK_STATUS_FAILED {
-				// 失败可以重试
-				if task.TaskAttemptsTime < s.config.MaxFailedAttempts {
-					task.TaskAttemptsTime++
-					newTask, _, err := s.Actuator.Start(s.ctx, &task)
-					// 尝试重启失败
-					if err != nil {
-						resaon := fmt.Errorf("任务执行失败：%v, 并且尝试重启也失败 %v", task.FailedReason, err)
-						s.failed(s.ctx, &task, resaon)
-					} else {
-						_, err = s.Container.ToRunningStatus(s.ctx, newTask) // 更新状态
-						if err != nil {
-							s.Actuator.Stop(s.ctx, newTask)
-							resaon := fmt.Errorf("任务执行失败：%v, 并且尝试重启也失败 %v", task.FailedReason, err)
-							s.failed(s.ctx, &task, resaon)
-							return
-						}
-					}
-				} else {
-					s.failed(s.ctx, &task, task.FailedReason)
-				}
-			} else if task.TaskStatus == TASK_STATUS_SUCCESS {
-				s.export(s.ctx, &task)
+// async 异步执行 f，手动模式下直接同步执行，保证调用返回的时候处理已经完成
+func (s *TaskScheduler) async(config Config, f func()) {
+	if config.ManualStep {
+		f()
+		return
+	}
+	go f()
+}
+
+// versioned 任务容器是否支持乐观锁
+func (s *TaskScheduler) versioned() bool {
+	c, ok := ContainerAs[VersionedContainer](s.Container)
+	return ok && c.SupportTaskVersion()
+}
+
+// refreshVersion 执行器启动任务的时候还没有转移到运行中，回调带回来的可能是转移之前的版本，
+// 版本冲突的时候从容器读取任务当前的版本，任务这次执行还在运行中的时候使用容器中的版本，返回是否更新了版本，
+// 之前执行的过期回调和任务结束以后的重复回调保留原来的版本，由容器通过版本冲突拒绝
+func (s *TaskScheduler) refreshVersion(ctx context.Context, t *Task) bool {
+	getter, ok := ContainerAs[TaskGetter](s.Container)
+	if !ok {
+		return false
+	}
+	current, ok, err := getter.GetTask(ctx, t.TaskId)
+	if err != nil || !ok {
+		return false
+	}
+	if current.TaskStatus != TASK_STATUS_RUNNING || current.TaskAttemptsTime != t.TaskAttemptsTime ||
+		current.TaskVersion <= t.TaskVersion {
+		return false
+	}
+	t.TaskVersion = current.TaskVersion
+	return true
+}
+
+// retryOrFail 任务执行失败，没有达到最大重试次数的时候重新启动任务，否则任务失败，
+// 第一次状态转移版本冲突的时候没有做任何处理，返回冲突的错误
+func (s *TaskScheduler) retryOrFail(ctx context.Context, task *Task, reason error, config Config) error {
+	if task.TaskAttemptsTime >= config.MaxFailedAttempts {
+		_, err := s.exhausted(ctx, task, reason)
+		return err
+	}
+	if s.versioned() {
+		// 重启之前先通过乐观锁抢占任务，防止回调和轮询重复重启任务
+		if _, err := s.Container.ToRunningStatus(ctx, task); err != nil {
+			if IsVersionConflict(err) {
+				return err
 			}
-		}()
+			s.failed(ctx, task, fmt.Errorf("任务执行失败：%v, 并且尝试重启也失败 %v", reason, err))
+			return nil
+		}
+	}
+	s.recordAttempt(task, reason)
+	task.TaskAttemptsTime++
+	s.loadCheckpoint(ctx, task)
+	startTime := s.clock.Now()
+	newTask, _, err := s.Actuator.Start(ctx, task)
+	s.concurrency.record(s.clock.Now().Sub(startTime), err != nil)
+	// 尝试重启失败
+	if err != nil {
+		resaon := fmt.Errorf("任务执行失败：%v, 并且尝试重启也失败 %v", reason, err)
+		s.failed(ctx, task, resaon)
+		return nil
+	}
+	_, err = s.Container.ToRunningStatus(ctx, newTask) // 更新状态
+	if err != nil {
+		s.Actuator.Stop(ctx, newTask)
+		resaon := fmt.Errorf("任务执行失败：%v, 并且尝试重启也失败 %v", reason, err)
+		s.failed(ctx, task, resaon)
+		return nil
 	}
+	s.recordHistory(ctx, newTask, TASK_STATUS_RUNNING, reason)
+	s.resetHeartbeat(newTask)
+	return nil
 }
 
 func (s *TaskScheduler) updateOnce(ctx context.Context) {
+	config := s.Config()
 	runingTasks, err := s.Container.GetRunningTask(ctx)
 	if err != nil {
+		s.stepError(ctx, fmt.Errorf("GetRunningTask error: %v", err))
 		return
 	}
 	status, err := s.Actuator.GetAsyncTaskStatus(ctx, runingTasks)
 	if err != nil {
+		s.stepError(ctx, fmt.Errorf("GetAsyncTaskStatus error: %v", err))
 		return
 	}
 	if len(status) != len(runingTasks) {
 		log.Printf("get async task status result lentgh(%d) not euqal input length(%d)\n", len(status), len(runingTasks))
+		s.stepError(ctx, fmt.Errorf("get async task status result lentgh(%d) not euqal input length(%d)",
+			len(status), len(runingTasks)))
 		return
 	}
 	wg := sync.WaitGroup{}
@@ -401,34 +739,17 @@ func (s *TaskScheduler) updateOnce(ctx context.Context) {
 		st := status[i]
 		s.wg.Add(1)
 		wg.Add(1)
-		go func() {
+		s.async(config, func() {
 			defer wg.Done()
 			defer s.wg.Done()
+			s.saveCheckpoint(ctx, &task, st.Checkpoint)
 			if st.TaskStatus == TASK_STATUS_FAILED {
 				// 已经回调处理过
 				if !s.checkProcessed(&task) {
 					return
 				}
 				// 失败可以重试
-				if task.TaskAttemptsTime < s.config.MaxFailedAttempts {
-					task.TaskAttemptsTime++
-					newTask, _, err := s.Actuator.Start(ctx, &task)
-					// 尝试重启失败
-					if err != nil {
-						resaon := fmt.Errorf("任务执行失败：%v, 并且尝试重启也失败 %v", st.FailedReason, err)
-						s.failed(ctx, &task, resaon)
-					} else {
-						_, err = s.Container.ToRunningStatus(ctx, newTask) // 更新状态
-						if err != nil {
-							s.Actuator.Stop(ctx, newTask)
-							resaon := fmt.Errorf("任务执行失败：%v, 并且尝试重启也失败 %v", st.FailedReason, err)
-							s.failed(ctx, &task, resaon)
-							return
-						}
-					}
-				} else {
-					s.failed(ctx, &task, st.FailedReason)
-				}
+				s.retryOrFail(ctx, &task, st.FailedReason, config)
 			} else if st.TaskStatus == TASK_STATUS_SUCCESS {
 				// 已经回调处理过
 				if !s.checkProcessed(&task) {
@@ -436,58 +757,73 @@ func (s *TaskScheduler) updateOnce(ctx context.Context) {
 				}
 				s.export(ctx, &task)
 			} else if st.TaskStatus == TASK_STATUS_RUNNING {
-				if s.config.TaskTimeout > 0 && task.TaskStartTime.Add(s.config.TaskTimeout).Before(time.Now()) {
+				if config.TaskTimeout > 0 && task.TaskStartTime.Add(config.TaskTimeout).Before(s.clock.Now()) {
 					// 任务超时
-					newTask, err := s.failed(ctx, &task, fmt.Errorf("任务%v超时", s.config.TaskTimeout))
+					newTask, err := s.failed(ctx, &task, fmt.Errorf("任务%v超时", config.TaskTimeout))
 					if err == nil {
 						s.Actuator.Stop(ctx, newTask)
 					}
 					return
 				}
+				s.heartbeat(&task, st.LastHeartbeat)
+				if s.lost(&task, config) {
+					// 任务心跳超时，视为执行任务的 worker 已经丢失，停止以后重试
+					s.Actuator.Stop(ctx, &task)
+					s.retryOrFail(ctx, &task, fmt.Errorf("任务心跳超过%v未更新，任务丢失", config.HeartbeatTimeout), config)
+					return
+				}
 				s.Container.UpdateRunningTaskStatus(ctx, &task, st)
 			}
-		}()
+		})
 	}
 	wg.Wait()
 }
 
 func (s *TaskScheduler) finshed(ctx context.Context, task *Task) {
 	// 添加到完成的任务 channel
-	task.TaskEnbTime = time.Now()
+	task.TaskEnbTime = s.clock.Now()
+	s.observeDuration(task)
+	s.attempts.take(task.TaskId) // 任务已经结束，清理执行记录
+	s.heartbeats.Delete(task.TaskId)
+	s.resolveFutures(ctx, task)
+
+	// 保存到发件箱，保证至少投递一次
+	if err := s.pushFinished(ctx, task); err != nil {
+		log.Printf("push finished task %s to outbox error: %v\n", task.TaskId, err)
+	}
 
-	if s.config.EnableFinshedTaskList {
-		c := time.NewTimer(50 * time.Millisecond)
-		retryCount := 0
-		select {
-		case s.finshedTask <- task:
-			return
-		case <-c.C:
-			// 因为缓存满了，导致加入不进去，chan 弹出一个元素
-			// 最多超时三次
-			if retryCount >= 3 {
-				return
-			}
-			c.Reset(0)
+	if s.Config().EnableFinshedTaskList {
+		// 缓存满了导致加入不进去的时候，chan 弹出一个最早的元素，最多重试三次，
+		// 不能等待 Clock 的定时器，假时钟和手动模式下没有人推进时间会一直阻塞
+		for retryCount := 0; retryCount <= 3; retryCount++ {
 			select {
-			case <-s.finshedTask:
-				break
-			case <-c.C:
-				break
+			case s.finshedTask <- task:
+				return
+			default:
+				select {
+				case <-s.finshedTask:
+				default:
+				}
 			}
-			c.Reset(0)
-			retryCount++
 		}
+		log.Printf("finished task channel is full, drop task %s\n", task.TaskId)
 	}
 }
 
-func (s *TaskScheduler) export(ctx context.Context, task *Task) {
+// export 导出任务结果，转移到导出状态版本冲突的时候没有做任何处理，返回冲突的错误
+func (s *TaskScheduler) export(ctx context.Context, task *Task) error {
 	if s.Persistencer != nil {
 		newtask, err := s.Container.ToExportStatus(ctx, task)
 		if err != nil {
+			if IsVersionConflict(err) {
+				// 版本冲突说明任务已经被其他流程处理
+				return err
+			}
 			s.failed(ctx, newtask, err)
-			return
+			return nil
 		}
-		go func() {
+		s.recordHistory(ctx, newtask, TASK_STATUS_EXPORTING, nil)
+		s.async(s.Config(), func() {
 			// 先从执行器获取任务执行结果
 			data, err := s.Actuator.GetOutput(ctx, newtask)
 			if err != nil {
@@ -500,30 +836,75 @@ func (s *TaskScheduler) export(ctx context.Context, task *Task) {
 				return
 			}
 			s.success(ctx, newtask)
-		}()
-	} else {
-		s.success(ctx, task)
+		})
+		return nil
 	}
+	_, err := s.success(ctx, task)
+	if IsVersionConflict(err) {
+		return err
+	}
+	return nil
 }
 
-func (s *TaskScheduler) failed(ctx context.Context, task *Task, err error) (*Task, error) {
+func (s *TaskScheduler) failed(ctx context.Context, task *Task, reason error) (*Task, error) {
 	// 任务失败
-	newtask, err := s.Container.ToFailedStatus(ctx, task, err)
-	if err == nil {
-		s.finshed(ctx, newtask)
+	return s.failWith(ctx, task, reason, nil)
+}
+
+// failingTask 转移到失败状态出错的任务，在下一个调度周期重试
+type failingTask struct {
+	task     Task
+	reason   error
+	onFailed func(ctx context.Context, task *Task) // 转移到失败状态以后的处理，比如加入死信
+}
+
+// failWith 把任务转移到失败状态，成功以后调用 onFailed
+// 任务容器出错的时候任务可能已经不在等待队列中，也不会再被轮询到，记录下来在下一个调度周期重试，避免任务丢失；
+// 版本冲突说明任务已经被其他流程处理，不再重试
+func (s *TaskScheduler) failWith(ctx context.Context, task *Task, reason error,
+	onFailed func(ctx context.Context, task *Task)) (*Task, error) {
+	newtask, err := s.Container.ToFailedStatus(ctx, task, reason)
+	if err != nil {
+		if !IsVersionConflict(err) {
+			log.Printf("task %s ToFailedStatus error: %v, retry in next scheduling\n", task.TaskId, err)
+			s.failing.Store(task.TaskId, failingTask{task: *task, reason: reason, onFailed: onFailed})
+		}
+		return newtask, err
 	}
-	return newtask, err
+	s.recordHistory(ctx, newtask, TASK_STATUS_FAILED, reason)
+	s.finshed(ctx, newtask)
+	if onFailed != nil {
+		onFailed(ctx, newtask)
+	}
+	return newtask, nil
+}
+
+// retryFailing 重试转移到失败状态出错的任务
+func (s *TaskScheduler) retryFailing(ctx context.Context) {
+	s.failing.Range(func(key, value interface{}) bool {
+		if _, ok := s.failing.LoadAndDelete(key); ok {
+			f := value.(failingTask)
+			s.failWith(ctx, &f.task, f.reason, f.onFailed)
+		}
+		return ctx.Err() == nil
+	})
 }
 
 func (s *TaskScheduler) success(ctx context.Context, task *Task) (*Task, error) {
 	// 任务成功
 	newtask, err := s.Container.ToSuccessStatus(ctx, task)
+	if IsVersionConflict(err) {
+		// 任务已经被其他流程处理，不能删除持久化的数据
+		return newtask, err
+	}
 	if err != nil {
 		if s.Persistencer != nil {
 			s.Persistencer.DeletePersistenceData(ctx, task)
 		}
 		newtask, err = s.failed(ctx, newtask, err)
 	} else {
+		s.deleteCheckpoint(ctx, newtask)
+		s.recordHistory(ctx, newtask, TASK_STATUS_SUCCESS, nil)
 		s.finshed(ctx, newtask)
 	}
 	return newtask, err
@@ -0,0 +1,98 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+)
+
+// makeBenchScheduler 构造手动模式的调度器，每一步调度同步完成，基准测试只测量调度器本身的开销
+func makeBenchScheduler(b *testing.B, size int, taskLimit int32, script actuatortest.Script) *lighttaskscheduler.TaskScheduler {
+	b.Helper()
+	container := memeorycontainer.MakeQueueContainer(uint32(size), time.Hour)
+	sch, err := lighttaskscheduler.MakeScheduler(container, actuatortest.MakeFakeActuator(script), nil,
+		lighttaskscheduler.Config{
+			TaskLimit:         taskLimit,
+			MaxFailedAttempts: 3,
+			ManualStep:        true,
+		})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(sch.Close)
+	return sch
+}
+
+// BenchmarkAddTask 添加任务的吞吐
+func BenchmarkAddTask(b *testing.B) {
+	ctx := context.Background()
+	sch := makeBenchScheduler(b, b.N, 1, actuatortest.Script{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := sch.AddTask(ctx, lighttaskscheduler.Task{TaskId: strconv.Itoa(i)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSchedulingLoop 一个完整的调度周期：启动 TaskLimit 个等待中的任务，再通过一次轮询结束这些任务
+func BenchmarkSchedulingLoop(b *testing.B) {
+	for _, taskLimit := range []int32{10, 100, 1000} {
+		b.Run(fmt.Sprintf("limit-%d", taskLimit), func(b *testing.B) {
+			ctx := context.Background()
+			sch := makeBenchScheduler(b, int(taskLimit), taskLimit, actuatortest.Script{})
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				for j := 0; j < int(taskLimit); j++ {
+					task := lighttaskscheduler.Task{TaskId: fmt.Sprintf("%d-%d", i, j)}
+					if err := sch.AddTask(ctx, task); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.StartTimer()
+				report, err := sch.ScheduleOnce(ctx)
+				if err != nil || len(report.Errors) > 0 {
+					b.Fatalf("ScheduleOnce error: %v %v", err, report.Errors)
+				}
+				if report, err = sch.PollOnce(ctx); err != nil || len(report.Errors) > 0 {
+					b.Fatalf("PollOnce error: %v %v", err, report.Errors)
+				}
+			}
+			b.ReportMetric(float64(b.N)*float64(taskLimit)/b.Elapsed().Seconds(), "tasks/s")
+		})
+	}
+}
+
+// BenchmarkPollOnce 运行中的任务数对一次状态轮询耗时的影响，任务一直处于运行中
+func BenchmarkPollOnce(b *testing.B) {
+	for _, running := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("running-%d", running), func(b *testing.B) {
+			ctx := context.Background()
+			sch := makeBenchScheduler(b, running, int32(running), actuatortest.Script{Duration: time.Hour})
+			for i := 0; i < running; i++ {
+				if err := sch.AddTask(ctx, lighttaskscheduler.Task{TaskId: strconv.Itoa(i)}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if _, err := sch.ScheduleOnce(ctx); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := sch.PollOnce(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuator"
+	"github.com/memory-overflow/light-task-scheduler/chaos"
+	memeorycontainer "github.com/memory-overflow/light-task-scheduler/container/memory_container"
+)
+
+// chaosPersistencer 内存中的数据持久化
+type chaosPersistencer struct {
+	data sync.Map
+}
+
+func (p *chaosPersistencer) DataPersistence(ctx context.Context, task *lighttaskscheduler.Task,
+	data interface{}) (err error) {
+	p.data.Store(task.TaskId, data)
+	return nil
+}
+
+func (p *chaosPersistencer) GetPersistenceData(ctx context.Context, task *lighttaskscheduler.Task) (
+	data interface{}, err error) {
+	data, ok := p.data.Load(task.TaskId)
+	if !ok {
+		return nil, fmt.Errorf("not found data of task %s", task.TaskId)
+	}
+	return data, nil
+}
+
+func (p *chaosPersistencer) DeletePersistenceData(ctx context.Context, task *lighttaskscheduler.Task) (err error) {
+	p.data.Delete(task.TaskId)
+	return nil
+}
+
+type chaosCallbackReceiver struct {
+	taskChannel chan lighttaskscheduler.Task
+}
+
+func (rec chaosCallbackReceiver) GetCallbackChannel(ctx context.Context) chan lighttaskscheduler.Task {
+	return rec.taskChannel
+}
+
+// TestSchedulerChaos 在故障注入的任务容器、执行器、数据持久化和回调接收器上运行调度器，校验调度器的不变量：
+// 1. 添加成功的任务不会丢失，最终都会以成功或者失败结束，并且只结束一次
+// 2. 任务容器中运行中的任务数不会超过 TaskLimit
+func TestSchedulerChaos(t *testing.T) {
+	seeds, taskCount := 5, 100
+	if testing.Short() {
+		seeds, taskCount = 1, 50
+	}
+	for seed := int64(1); seed <= int64(seeds); seed++ {
+		seed := seed
+		t.Run(fmt.Sprintf("seed-%d", seed), func(t *testing.T) {
+			runChaos(t, seed, taskCount, 5)
+		})
+	}
+}
+
+func runChaos(t *testing.T, seed int64, taskCount int, taskLimit int32) {
+	// 执行函数，10% 的概率失败
+	var runLock sync.Mutex
+	r := rand.New(rand.NewSource(seed))
+	run := func(ctx context.Context, task *lighttaskscheduler.Task) (data interface{}, err error) {
+		runLock.Lock()
+		d := time.Duration(5+r.Intn(20)) * time.Millisecond
+		fail := r.Intn(10) == 0
+		runLock.Unlock()
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if fail {
+			return nil, fmt.Errorf("run task %s failed", task.TaskId)
+		}
+		return task.TaskId, nil
+	}
+	taskChannel := make(chan lighttaskscheduler.Task, 10000)
+	fucntionActuator, err := actuator.MakeFucntionActuator(run, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fucntionActuator.SetCallbackChannel(taskChannel)
+	queueContainer := memeorycontainer.MakeQueueContainer(uint32(taskCount), 10*time.Millisecond)
+
+	// 容器所有状态转移方法 5% 的概率出错，执行器查询状态变慢，导出的过程中崩溃，回调丢失和重复
+	container, err := chaos.MakeContainer(queueContainer, chaos.Config{
+		Seed: seed, ErrorRate: 0.05, LatencyRate: 0.1, Latency: 10 * time.Millisecond,
+		Methods: []string{"AddTask", "GetRunningTask", "GetRunningTaskCount", "GetWaitingTask", "ToRunningStatus",
+			"ToExportStatus", "ToSuccessStatus", "ToFailedStatus", "UpdateRunningTaskStatus"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chaosActuator, err := chaos.MakeActuator(fucntionActuator, chaos.Config{
+		Seed: seed + 1, ErrorRate: 0.05, LatencyRate: 0.3, Latency: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	persistencer, err := chaos.MakePersistencer(&chaosPersistencer{}, chaos.Config{
+		Seed: seed + 2, ErrorRate: 0.05, LostResponseRate: 0.05,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err := chaos.MakeCallbackReceiver(chaosCallbackReceiver{taskChannel: taskChannel}, chaos.Config{
+		Seed: seed + 3, DropRate: 0.2, DuplicateRate: 0.2, LatencyRate: 0.1, Latency: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sch, err := lighttaskscheduler.MakeScheduler(container, chaosActuator, persistencer,
+		lighttaskscheduler.Config{
+			TaskLimit:              taskLimit,
+			TaskTimeout:            5 * time.Second,
+			MaxFailedAttempts:      3,
+			SchedulingPollInterval: 5 * time.Millisecond,
+			StatePollInterval:      20 * time.Millisecond,
+			EnableStateCallback:    true,
+			CallbackReceiver:       receiver,
+			EnableFinshedTaskList:  true,
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sch.Close()
+
+	// 采样运行中的任务数，直接读取被包装的容器，不受故障注入影响
+	var maxRunning int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for ctx.Err() == nil {
+			if count, _ := queueContainer.GetRunningTaskCount(ctx); count > atomic.LoadInt32(&maxRunning) {
+				atomic.StoreInt32(&maxRunning, count)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	added := map[string]bool{}
+	for i := 0; i < taskCount; i++ {
+		taskId := strconv.Itoa(i)
+		// 添加任务的错误会返回给调用方，调用方负责重试
+		for retry := 0; retry < 10; retry++ {
+			if err = sch.AddTask(context.Background(), lighttaskscheduler.Task{TaskId: taskId}); err == nil {
+				added[taskId] = true
+				break
+			}
+		}
+	}
+
+	finished := map[string]lighttaskscheduler.TaskStatus{}
+	deadline := time.After(time.Minute)
+	for len(finished) < len(added) {
+		select {
+		case task := <-sch.FinshedTasks():
+			if status, ok := finished[task.TaskId]; ok {
+				t.Errorf("task %s finished twice, %v and %v", task.TaskId, status, task.TaskStatus)
+				continue
+			}
+			finished[task.TaskId] = task.TaskStatus
+		case <-deadline:
+			for taskId := range added {
+				if _, ok := finished[taskId]; !ok {
+					t.Errorf("task %s lost", taskId)
+				}
+			}
+			t.FailNow()
+		}
+	}
+	if max := atomic.LoadInt32(&maxRunning); max > taskLimit {
+		t.Errorf("running task count %d exceeds limit %d", max, taskLimit)
+	}
+	t.Logf("container chaos %+v", container.Stats())
+}
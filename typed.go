@@ -0,0 +1,112 @@
+package lighttaskscheduler
+
+import (
+	"context"
+	"fmt"
+)
+
+// ItemOf 取出任务对象并且转换成 T 类型，兼容 TaskItem 配置成 T 或者 *T 两种形式
+func ItemOf[T any](task *Task) (item T, err error) {
+	if v, ok := task.TaskItem.(T); ok {
+		return v, nil
+	}
+	if v, ok := task.TaskItem.(*T); ok && v != nil {
+		return *v, nil
+	}
+	return item, fmt.Errorf("TaskItem is not configured as %T", item)
+}
+
+// TypedPersistencer 类型安全的任务数据持久化接口，Out 为执行器 GetOutput 返回的数据类型
+// 通过 AdaptPersistencer 转换成 TaskdataPersistencer 以后配置到调度器
+type TypedPersistencer[Out any] interface {
+	// DataPersistence 持久化任务执行结果
+	DataPersistence(ctx context.Context, task *Task, data Out) (err error)
+
+	// GetPersistenceData 查询任务持久化结果
+	GetPersistenceData(ctx context.Context, task *Task) (data Out, err error)
+
+	// DeletePersistenceData 删除任务的此久化结果
+	DeletePersistenceData(ctx context.Context, task *Task) (err error)
+}
+
+// typedPersistencerAdapter 把 TypedPersistencer 适配成 TaskdataPersistencer
+type typedPersistencerAdapter[Out any] struct {
+	persistencer TypedPersistencer[Out]
+}
+
+// AdaptPersistencer 把类型安全的 TypedPersistencer 转换成调度器使用的 TaskdataPersistencer
+func AdaptPersistencer[Out any](persistencer TypedPersistencer[Out]) TaskdataPersistencer {
+	return typedPersistencerAdapter[Out]{persistencer: persistencer}
+}
+
+// DataPersistence 持久化任务执行结果
+func (a typedPersistencerAdapter[Out]) DataPersistence(ctx context.Context, task *Task, data interface{}) (err error) {
+	out, ok := data.(Out)
+	if !ok {
+		return fmt.Errorf("output data is %T, not %T", data, out)
+	}
+	return a.persistencer.DataPersistence(ctx, task, out)
+}
+
+// GetPersistenceData 查询任务持久化结果
+func (a typedPersistencerAdapter[Out]) GetPersistenceData(ctx context.Context, task *Task) (data interface{}, err error) {
+	return a.persistencer.GetPersistenceData(ctx, task)
+}
+
+// DeletePersistenceData 删除任务的此久化结果
+func (a typedPersistencerAdapter[Out]) DeletePersistenceData(ctx context.Context, task *Task) (err error) {
+	return a.persistencer.DeletePersistenceData(ctx, task)
+}
+
+// TypedScheduler 类型安全的调度器，In 为任务对象 TaskItem 的类型，Out 为任务执行结果的类型
+// 底层仍然是 TaskScheduler，可以和非泛型的接口混合使用
+type TypedScheduler[In, Out any] struct {
+	*TaskScheduler
+}
+
+// MakeTypedScheduler 新建类型安全的任务调度器，参数和 MakeScheduler 一致，persistencer 可以为 nil
+func MakeTypedScheduler[In, Out any](
+	container TaskContainer,
+	actuator TaskActuator,
+	persistencer TypedPersistencer[Out],
+	config Config) (*TypedScheduler[In, Out], error) {
+	var p TaskdataPersistencer
+	if persistencer != nil {
+		p = AdaptPersistencer[Out](persistencer)
+	}
+	scheduler, err := MakeScheduler(container, actuator, p, config)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedScheduler[In, Out]{TaskScheduler: scheduler}, nil
+}
+
+// AddTypedTask 添加一个类型为 In 的任务，taskId 为任务的唯一标识
+func (s *TypedScheduler[In, Out]) AddTypedTask(ctx context.Context, taskId string, priority int, item In) error {
+	return s.AddTask(ctx, Task{
+		TaskId:       taskId,
+		TaskPriority: priority,
+		TaskItem:     item,
+	})
+}
+
+// Item 取出任务的 In 类型任务对象
+func (s *TypedScheduler[In, Out]) Item(task *Task) (In, error) {
+	return ItemOf[In](task)
+}
+
+// GetOutput 通过数据持久化查询任务的 Out 类型执行结果
+func (s *TypedScheduler[In, Out]) GetOutput(ctx context.Context, task *Task) (out Out, err error) {
+	if s.Persistencer == nil {
+		return out, fmt.Errorf("persistencer is not configured")
+	}
+	data, err := s.Persistencer.GetPersistenceData(ctx, task)
+	if err != nil {
+		return out, err
+	}
+	out, ok := data.(Out)
+	if !ok {
+		return out, fmt.Errorf("persistence data is %T, not %T", data, out)
+	}
+	return out, nil
+}
@@ -0,0 +1,116 @@
+package lighttaskscheduler
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"time"
+)
+
+// WaitingTaskLister 可以按照调度顺序列出等待中任务的任务容器，实现以后可以查询等待中任务的排队位置和预计时间
+type WaitingTaskLister interface {
+	// ListWaitingTask 按照 GetWaitingTask 取出的顺序返回所有等待中的任务，不会从等待队列中删除
+	ListWaitingTask(ctx context.Context) (tasks []Task, err error)
+}
+
+// WaitingTaskETA 等待中任务的排队位置和预计时间
+type WaitingTaskETA struct {
+	Position  int       // 排在前面的等待中任务数
+	StartTime time.Time // 预计开始执行的时间
+	EndTime   time.Time // 预计执行完成的时间
+}
+
+// EstimateDuration 通过 Config.DurationEstimator 预计任务的执行时间，没有配置的时候返回 0
+func (s *TaskScheduler) EstimateDuration(task Task) time.Duration {
+	if estimator := s.Config().DurationEstimator; estimator != nil {
+		return estimator.Estimate(task)
+	}
+	return 0
+}
+
+// observeDuration 任务执行成功以后学习任务的执行时间
+func (s *TaskScheduler) observeDuration(task *Task) {
+	if estimator := s.Config().DurationEstimator; estimator != nil && task.TaskStatus == TASK_STATUS_SUCCESS {
+		estimator.Observe(*task)
+	}
+}
+
+// timeHeap 并发槽位空闲时间的小顶堆
+type timeHeap []time.Time
+
+func (h timeHeap) Len() int            { return len(h) }
+func (h timeHeap) Less(i, j int) bool  { return h[i].Before(h[j]) }
+func (h timeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *timeHeap) Push(x interface{}) { *h = append(*h, x.(time.Time)) }
+func (h *timeHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return t
+}
+
+// GetWaitingTaskETA 查询等待中任务的排队位置，以及根据历史执行时间预计的开始和完成时间，
+// 需要配置 Config.DurationEstimator 并且任务容器实现 WaitingTaskLister，
+// 按照任务容器的等待顺序和当前的并发限制模拟调度，配置了调度策略的时候只是近似
+func (s *TaskScheduler) GetWaitingTaskETA(ctx context.Context, taskId string) (eta WaitingTaskETA, err error) {
+	config := s.Config()
+	if config.DurationEstimator == nil {
+		return eta, fmt.Errorf("DurationEstimator is not configured")
+	}
+	lister, ok := ContainerAs[WaitingTaskLister](s.Container)
+	if !ok {
+		return eta, fmt.Errorf("container does not implement WaitingTaskLister")
+	}
+	waitingTasks, err := lister.ListWaitingTask(ctx)
+	if err != nil {
+		return eta, fmt.Errorf("ListWaitingTask error: %v", err)
+	}
+	eta.Position = -1
+	for i := range waitingTasks {
+		if waitingTasks[i].TaskId == taskId {
+			eta.Position = i
+			break
+		}
+	}
+	if eta.Position < 0 {
+		return eta, fmt.Errorf("task %s is not waiting", taskId)
+	}
+	runningTasks, err := s.Container.GetRunningTask(ctx)
+	if err != nil {
+		return eta, fmt.Errorf("GetRunningTask error: %v", err)
+	}
+
+	// 每个并发槽位空闲的时间，运行中的任务按照已经执行的时间计算剩余时间
+	now := s.clock.Now()
+	limit := int(s.concurrency.limitOf(config))
+	if limit <= 0 {
+		limit = 1
+	}
+	slots := make(timeHeap, 0, limit)
+	for _, task := range runningTasks {
+		free := now
+		if !task.TaskStartTime.IsZero() {
+			if end := task.TaskStartTime.Add(config.DurationEstimator.Estimate(task)); end.After(now) {
+				free = end
+			}
+		}
+		slots = append(slots, free)
+	}
+	for len(slots) < limit {
+		slots = append(slots, now)
+	}
+	heap.Init(&slots)
+	for len(slots) > limit {
+		// 并发限制调小以后，运行中的任务比槽位多，最早空闲的槽位不能调度新的任务
+		heap.Pop(&slots)
+	}
+	for i := 0; i <= eta.Position; i++ {
+		start := heap.Pop(&slots).(time.Time)
+		end := start.Add(config.DurationEstimator.Estimate(waitingTasks[i]))
+		heap.Push(&slots, end)
+		if i == eta.Position {
+			eta.StartTime, eta.EndTime = start, end
+		}
+	}
+	return eta, nil
+}
@@ -0,0 +1,167 @@
+package lighttaskscheduler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lighttaskscheduler "github.com/memory-overflow/light-task-scheduler"
+	"github.com/memory-overflow/light-task-scheduler/actuatortest"
+)
+
+// mapEstimator 按照任务 id 返回预计执行时间
+type mapEstimator map[string]time.Duration
+
+func (e mapEstimator) Observe(task lighttaskscheduler.Task) {}
+
+func (e mapEstimator) Estimate(task lighttaskscheduler.Task) time.Duration { return e[task.TaskId] }
+
+// TestWaitingTaskETA 按照等待顺序和并发限制模拟调度，运行中的任务按照已经执行的时间计算剩余时间
+func TestWaitingTaskETA(t *testing.T) {
+	estimator := mapEstimator{
+		"a": time.Minute, "b": 2 * time.Minute, "c": 3 * time.Minute, "d": 4 * time.Minute,
+	}
+	for _, tc := range []struct {
+		name      string
+		running   []string      // 先调度的任务，从 0 时刻开始执行
+		advance   time.Duration // 调度以后推进的时间
+		taskLimit int32         // 调度以后修改的 TaskLimit，0 表示不修改
+		waiting   []string
+		query     string
+		position  int
+		start     time.Duration // 预计开始时间相对 0 时刻的偏移
+		end       time.Duration
+	}{
+		{
+			name:     "idle slots",
+			waiting:  []string{"a", "b", "c"},
+			query:    "c",
+			position: 2,
+			start:    time.Minute,
+			end:      4 * time.Minute,
+		},
+		{
+			name:     "head of queue",
+			waiting:  []string{"a", "b", "c"},
+			query:    "a",
+			position: 0,
+			end:      time.Minute,
+		},
+		{
+			name:     "running tasks",
+			running:  []string{"a", "b"},
+			advance:  30 * time.Second,
+			waiting:  []string{"c", "d"},
+			query:    "d",
+			position: 1,
+			start:    2 * time.Minute,
+			end:      6 * time.Minute,
+		},
+		{
+			// 超过预计时间还在运行的任务，视为马上结束
+			name:     "overdue running task",
+			running:  []string{"a", "b"},
+			advance:  90 * time.Second,
+			waiting:  []string{"c"},
+			query:    "c",
+			position: 0,
+			start:    90 * time.Second,
+			end:      90*time.Second + 3*time.Minute,
+		},
+		{
+			// 并发限制调小以后，运行中的任务比槽位多，最早空闲的槽位不能调度新的任务
+			name:      "lowered limit",
+			running:   []string{"a", "b"},
+			taskLimit: 1,
+			waiting:   []string{"c"},
+			query:     "c",
+			position:  0,
+			start:     2 * time.Minute,
+			end:       5 * time.Minute,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := makeHarness(t, actuatortest.Script{Duration: time.Hour}, func(c *lighttaskscheduler.Config) {
+				c.DurationEstimator = estimator
+			})
+			begin := h.clock.Now()
+			for _, id := range tc.running {
+				h.add(t, lighttaskscheduler.Task{TaskId: id})
+			}
+			h.schedule(t)
+			h.clock.Advance(tc.advance)
+			if tc.taskLimit > 0 {
+				if err := h.sch.UpdateConfig(func(c *lighttaskscheduler.Config) { c.TaskLimit = tc.taskLimit }); err != nil {
+					t.Fatalf("UpdateConfig error: %v", err)
+				}
+			}
+			for _, id := range tc.waiting {
+				h.add(t, lighttaskscheduler.Task{TaskId: id})
+			}
+			eta, err := h.sch.GetWaitingTaskETA(context.Background(), tc.query)
+			if err != nil {
+				t.Fatalf("GetWaitingTaskETA error: %v", err)
+			}
+			want := lighttaskscheduler.WaitingTaskETA{
+				Position:  tc.position,
+				StartTime: begin.Add(tc.start),
+				EndTime:   begin.Add(tc.end),
+			}
+			if eta != want {
+				t.Fatalf("ETA want %+v, got %+v", want, eta)
+			}
+		})
+	}
+}
+
+// TestWaitingTaskETALearned 使用成功任务学习到的执行时间预计等待中任务的时间
+func TestWaitingTaskETALearned(t *testing.T) {
+	estimator, err := lighttaskscheduler.MakeDurationEstimator(nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := makeHarness(t, actuatortest.Script{Duration: 2 * time.Minute}, func(c *lighttaskscheduler.Config) {
+		c.DurationEstimator = estimator
+		c.TaskLimit = 1
+	})
+	h.add(t, lighttaskscheduler.Task{TaskId: "learn", TaskItem: "video"})
+	h.schedule(t)
+	h.clock.Advance(2 * time.Minute)
+	h.poll(t)
+	if d := h.sch.EstimateDuration(lighttaskscheduler.Task{TaskItem: "video"}); d != 2*time.Minute {
+		t.Fatalf("learned duration want 2m, got %v", d)
+	}
+	h.add(t, lighttaskscheduler.Task{TaskId: "first", TaskItem: "video"},
+		lighttaskscheduler.Task{TaskId: "second", TaskItem: "video"})
+	eta, err := h.sch.GetWaitingTaskETA(context.Background(), "second")
+	if err != nil {
+		t.Fatalf("GetWaitingTaskETA error: %v", err)
+	}
+	now := h.clock.Now()
+	want := lighttaskscheduler.WaitingTaskETA{Position: 1, StartTime: now.Add(2 * time.Minute),
+		EndTime: now.Add(4 * time.Minute)}
+	if eta != want {
+		t.Fatalf("ETA want %+v, got %+v", want, eta)
+	}
+}
+
+// TestWaitingTaskETAErrors 没有配置执行时间估计器或者任务不在等待中的时候返回错误
+func TestWaitingTaskETAErrors(t *testing.T) {
+	ctx := context.Background()
+	h := makeHarness(t, actuatortest.Script{Duration: time.Hour}, nil)
+	h.add(t, lighttaskscheduler.Task{TaskId: "task"})
+	if _, err := h.sch.GetWaitingTaskETA(ctx, "task"); err == nil {
+		t.Fatal("GetWaitingTaskETA without DurationEstimator returns no error")
+	}
+	if err := h.sch.UpdateConfig(func(c *lighttaskscheduler.Config) {
+		c.DurationEstimator = mapEstimator{}
+	}); err != nil {
+		t.Fatalf("UpdateConfig error: %v", err)
+	}
+	h.schedule(t)
+	for _, taskId := range []string{"task", "unknown"} {
+		if _, err := h.sch.GetWaitingTaskETA(ctx, taskId); err == nil {
+			t.Fatalf("GetWaitingTaskETA of not waiting task %s returns no error", taskId)
+		}
+	}
+}